@@ -6,8 +6,10 @@
 //      - subcommands: ls, put, get
 //
 //        => subCMD 1: ls
-//             - desc: list artifacts within a release
-//            - usage: holo artifacts ls RELEASE
+//             - desc: list artifacts within a release, optionally only
+//                     those of a device, board or kind
+//            - usage: holo artifacts ls RELEASE [--device DEVICE]
+//                       [--board BOARD] [--kind KIND]
 //          - example: holo artifacts ls Thundercat1A176
 //   - example output: Cake1,1_DVT_1.0_Thundercat1A176_RestoreImage.swpkg
 //                     Cake1,1_DVT2_1.0_Thundercat1A176_RestoreImage.swpkg
@@ -16,29 +18,92 @@
 //                     Cake1,1_DVT_1.0_Thundercat1A176_rootfs.img
 //                     Cake1,1_DVT2_1.0_Thundercat1A176_rootfs.img
 //                     Thundercat1A176_SDKRoot.txz
+//    - output fields: name, release, created, size, sha256,
+//                     device, board, variant, kind
+//
+//                     artifact names are DEVICE_BOARD_VARIANT_RELEASE_KIND.EXT
+//                     for device images and RELEASE_KIND.EXT otherwise
 //
 //        => subCMD 2: put
-//             - desc: upload artifacts for a release
+//             - desc: upload artifacts for a release (the file name must
+//                     follow the artifact naming convention)
 //            - usage: holo artifacts put FILENAME --release RELEASE [-f/--force]
-//          - example: holo artifacts put ./foo.tar.gz --release Thundercat1A176
+//          - example: holo artifacts put ./Cake1,1_DVT2_1.0_Thundercat1A176_USBImage.img --release Thundercat1A176
 //   - example output: Uploading...
 //                     [============================>                          ] 50% (30MB/s; ETA: 1s)
 //
+//                     interrupted uploads resume where they stopped; the
+//                     SHA-256 is verified before the artifact is published.
+//                     -f/--force replaces an existing artifact, unless other
+//                     releases link to it
+//
 //        => subCMD 3: get
-//             - desc: download artifacts for a release
+//             - desc: download artifacts for a release, by name or by the
+//                     device, board and kind of the one artifact wanted
 //            - usage: holo artifacts get FILENAME DESTINATION --release RELEASE
-//          - example: holo artifacts get foo.tar.gz /tmp/Thundercat1A176_foo.tar.gz --release Thundercat1A176
+//                     holo artifacts get DESTINATION --release RELEASE
+//                       [--device DEVICE] [--board BOARD] [--kind KIND]
+//          - example: holo artifacts get Thundercat1A176_SDKRoot.txz /tmp/sdk.txz --release Thundercat1A176
+//                     holo artifacts get --release Thundercat1A176 --board DVT2 --kind USBImage /tmp
 //   - example output: Downloading...
 //                     [============================>                          ] 50% (30MB/s; ETA: 1s)
+//
+//                     artifacts are kept in the cache-dir setting (default
+//                     ~/.cache/holo/RELEASE/FILENAME); an interrupted download
+//                     is resumed by the next get and only lands in the cache
+//                     once the SHA-256 matches; a DESTINATION directory
+//                     receives the artifact under its own name
 
 package main
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
 
 	"github.com/urfave/cli"
 )
 
+// artifactFilterFlags pick artifacts by device, board or kind
+var artifactFilterFlags = []flagSpec{
+	{Name: "device", Value: "DEVICE", Usage: "only artifacts of a device, e.g. Cake1,1"},
+	{Name: "board", Value: "BOARD", Usage: "only artifacts of a board, e.g. DVT2"},
+	{Name: "kind", Value: "KIND", Usage: "only artifacts of a kind, e.g. USBImage"},
+}
+
+var lsArtifactsArgs = argSchema{
+	Args: []argSpec{
+		{Name: "RELEASE", Kind: "release"},
+	},
+	Flags: artifactFilterFlags,
+}
+
+var putArtifactsArgs = argSchema{
+	Args: []argSpec{
+		{Name: "FILENAME"},
+	},
+	Flags: []flagSpec{
+		{Name: "release", Value: "RELEASE", Kind: "release", Required: true, Usage: "the name of a release within a build train"},
+		forceFlag,
+	},
+}
+
+var getArtifactsArgs = argSchema{
+	Args: []argSpec{
+		{Name: "FILENAME", Kind: "artifact", Optional: true},
+		{Name: "DESTINATION"},
+	},
+	Flags: append([]flagSpec{
+		{Name: "release", Value: "RELEASE", Kind: "release", Required: true, Usage: "the name of a release within a build train"},
+	}, artifactFilterFlags...),
+}
+
 func setArtifactsCmd() cli.Command {
 
 	return cli.Command{
@@ -47,37 +112,24 @@ func setArtifactsCmd() cli.Command {
 		Subcommands: []cli.Command{
 			{
 				Name:      "ls",
-				ArgsUsage: "RELEASE",
+				ArgsUsage: lsArtifactsArgs.usage(),
 				Usage:     "list artifacts within a release",
+				Flags:     lsArtifactsArgs.flags(),
 				Action:    lsArtifactsAction,
 			},
 			{
 				Name:      "put",
-				ArgsUsage: "FILENAME --release RELEASE [-f/--force]",
+				ArgsUsage: putArtifactsArgs.usage(),
 				Usage:     "upload artifacts for a release",
-				Flags: []cli.Flag{
-					cli.StringFlag{
-						Name:  "release",
-						Usage: "the name of a release within a build train",
-					},
-					cli.BoolFlag{
-						Name:  "force, f",
-						Usage: "suppress prompts",
-					},
-				},
-				Action: putArtifactsAction,
+				Flags:     putArtifactsArgs.flags(),
+				Action:    audited(putArtifactsAction),
 			},
 			{
 				Name:      "get",
-				ArgsUsage: "FILENAME DESTINATION --release RELEASE",
+				ArgsUsage: getArtifactsArgs.usage(),
 				Usage:     "download artifacts for a release",
-				Flags: []cli.Flag{
-					cli.StringFlag{
-						Name:  "release",
-						Usage: "the name of a release within a build train",
-					},
-				},
-				Action: getArtifactsAction,
+				Flags:     getArtifactsArgs.flags(),
+				Action:    getArtifactsAction,
 			},
 		},
 	}
@@ -86,28 +138,59 @@ func setArtifactsCmd() cli.Command {
 // Subcommand: ls
 func lsArtifactsAction(c *cli.Context) error {
 	// check arguments
-	err := lsArtifactsCheckArg(c)
+	err := lsArtifactsArgs.check(c)
 	if err != nil {
 		return err
 	}
-	fmt.Println("In the function of artifacts ls:")
-	fmt.Println("### cmd: holo artifacts ls RELEASE ###")
-	fmt.Print("RELEASE: ", c.Args().Get(0), "\n\n")
-	return nil
+
+	store, err := openStore(c)
+	if err != nil {
+		return sendCmdErr(err)
+	}
+	artifacts, err := store.ListArtifacts(c.Args().Get(0))
+	if err != nil {
+		return sendCmdErr(err)
+	}
+	artifacts = filterArtifacts(artifacts, c.String("device"), c.String("board"), c.String("kind"))
+	return printOutput(c, artifacts, func() {
+		for _, a := range artifacts {
+			fmt.Println(a.Name)
+		}
+	})
 }
 
 // Subcommand: put
 func putArtifactsAction(c *cli.Context) error {
 	// check arguments
-	err := putArtifactsCheckArg(c)
+	err := putArtifactsArgs.check(c)
 	if err != nil {
 		return err
 	}
-	fmt.Println("In the function of artifacts put:")
-	fmt.Println("### cmd: holo artifacts put FILENAME --release RELEASE [-f/--force] ###")
-	fmt.Println("FILENAME:", c.Args().Get(0))
-	fmt.Println("RELEASE: ", c.String("release"))
-	fmt.Println("force:", c.Bool("force"))
+
+	store, err := openStore(c)
+	if err != nil {
+		return sendCmdErr(err)
+	}
+	a := &Artifact{Name: filepath.Base(c.Args().Get(0)), Release: c.String("release")}
+	auditRelease(c, store, a.Release)
+	_, err = parseArtifactName(a.Release, a.Name)
+	if err != nil {
+		return sendCmdErr(err)
+	}
+	_, err = store.StatArtifact(a.Release, a.Name)
+	if err == nil && !c.Bool("force") {
+		return sendCmdErr(errors.New("artifact " + a.Name + " already exists in release " + a.Release + ", use -f/--force to overwrite"))
+	}
+	if err != nil && !errors.Is(err, errNotFound) {
+		return sendCmdErr(err)
+	}
+
+	fmt.Println("Uploading...")
+	err = uploadArtifact(store, a, c.Args().Get(0), c.Bool("force"))
+	if err != nil {
+		return sendCmdErr(err)
+	}
+	fmt.Println("Done!")
 	return nil
 }
 
@@ -119,43 +202,272 @@ func getArtifactsAction(c *cli.Context) error {
 		return err
 	}
 
-	fmt.Println("In the function of artifacts get:")
-	fmt.Println("### cmd: holo artifacts get FILENAME DESTINATION --release RELEASE ###")
-	fmt.Println("FILENAME: ", c.Args().Get(0))
-	fmt.Println("DESTINATION: ", c.Args().Get(1))
-	fmt.Println("RELEASE: ", c.String("release"))
+	store, err := openStore(c)
+	if err != nil {
+		return sendCmdErr(err)
+	}
+	var a *Artifact
+	dest := c.Args().Get(c.NArg() - 1)
+	if c.NArg() == 2 {
+		a, err = store.StatArtifact(c.String("release"), c.Args().Get(0))
+	} else {
+		a, err = findArtifact(store, c.String("release"), c.String("device"), c.String("board"), c.String("kind"))
+	}
+	if err != nil {
+		return sendCmdErr(err)
+	}
+	info, err := os.Stat(dest)
+	if err == nil && info.IsDir() {
+		dest = filepath.Join(dest, a.Name)
+	}
+
+	fmt.Println("Downloading...")
+	err = fetchArtifact(c, store, a, dest)
+	if err != nil {
+		return sendCmdErr(err)
+	}
+	fmt.Println("Done!")
 	return nil
 }
 
-// check input arguments:
-func lsArtifactsCheckArg(c *cli.Context) error {
-	err := checkNumArg(c, 1)
+// findArtifact returns the one artifact of a release that passes the
+// --device, --board and --kind filters
+func findArtifact(store Store, release, device, board, kind string) (*Artifact, error) {
+	artifacts, err := store.ListArtifacts(release)
+	if err != nil {
+		return nil, err
+	}
+	artifacts = filterArtifacts(artifacts, device, board, kind)
+	switch len(artifacts) {
+	case 0:
+		return nil, errors.New("no artifact of release " + release + " matches")
+	case 1:
+		return &artifacts[0], nil
+	}
+	names := []string{}
+	for _, a := range artifacts {
+		names = append(names, a.Name)
+	}
+	return nil, errors.New("several artifacts of release " + release + " match, narrow down with --device, --board or --kind: " + strings.Join(names, ", "))
+}
+
+// chunkSize is the amount of data sent per upload request
+const chunkSize = 8 << 20
+
+// uploadArtifact sends a file in chunks, resuming an interrupted upload of
+// the same artifact, and lets the backend verify the SHA-256 at the end
+func uploadArtifact(store Store, a *Artifact, filename string, force bool) error {
+	f, err := os.Open(filename)
 	if err != nil {
 		return err
 	}
-	return nil
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	a.Size = info.Size()
+
+	offset, err := store.UploadOffset(a.Release, a.Name)
+	if err != nil {
+		return err
+	}
+	if offset > a.Size {
+		return errors.New("an upload of " + a.Name + " larger than " + filename + " is in progress")
+	}
+
+	// the part uploaded earlier still counts towards the checksum
+	hash := sha256.New()
+	_, err = io.CopyN(hash, f, offset)
+	if err != nil {
+		return err
+	}
+	bar := newProgress(a.Size, offset)
+	for offset < a.Size {
+		chunk := io.TeeReader(io.LimitReader(f, chunkSize), io.MultiWriter(hash, bar))
+		next, err := store.UploadChunk(a.Release, a.Name, offset, chunk)
+		if err != nil {
+			return err
+		}
+		if next <= offset {
+			return errors.New("upload of " + a.Name + " made no progress")
+		}
+		offset = next
+	}
+	bar.finish()
+
+	a.SHA256 = hex.EncodeToString(hash.Sum(nil))
+	return store.CommitUpload(a, force)
+}
+
+// fetchArtifact downloads an artifact into the cache, unless a verified copy
+// is already there, and copies it to dest
+func fetchArtifact(c *cli.Context, store Store, a *Artifact, dest string) error {
+	dir, err := cacheDir(c)
+	if err != nil {
+		return err
+	}
+	cached := filepath.Join(dir, a.Release, a.Name)
+	if !cacheValid(cached, a) {
+		err = os.MkdirAll(filepath.Dir(cached), 0755)
+		if err != nil {
+			return err
+		}
+		err = downloadArtifact(store, a, cached)
+		if err != nil {
+			return err
+		}
+		err = ioutil.WriteFile(cached+".sha256", []byte(a.SHA256), 0644)
+		if err != nil {
+			return err
+		}
+	}
+	return copyFile(cached, dest)
 }
 
-func putArtifactsCheckArg(c *cli.Context) error {
-	err := checkNumArg(c, 1)
+// cacheValid reports whether the cache holds a verified copy of an artifact:
+// the .sha256 sidecar, written once the download was checked, matches the
+// artifact, and the file has its size and was not changed after that
+func cacheValid(cached string, a *Artifact) bool {
+	sum, err := ioutil.ReadFile(cached + ".sha256")
+	if err != nil || string(sum) != a.SHA256 {
+		return false
+	}
+	info, err := os.Stat(cached)
+	if err != nil || info.Size() != a.Size {
+		return false
+	}
+	verified, err := os.Stat(cached + ".sha256")
+	return err == nil && !info.ModTime().After(verified.ModTime())
+}
+
+// copyArtifact copies the data of an artifact into another one, going
+// through the cache
+func copyArtifact(c *cli.Context, store Store, a *Artifact, clone *Artifact) error {
+	dir, err := ioutil.TempDir("", "holo-")
 	if err != nil {
 		return err
 	}
-	err = checkFlagVal(c, "release")
+	defer os.RemoveAll(dir)
+	tmp := filepath.Join(dir, clone.Name)
+	err = fetchArtifact(c, store, a, tmp)
 	if err != nil {
 		return err
 	}
-	return nil
+	return uploadArtifact(store, clone, tmp, false)
 }
 
-func getArtifactsCheckArg(c *cli.Context) error {
-	err := checkNumArg(c, 2)
+// cacheDir returns the directory downloaded artifacts are kept in
+func cacheDir(c *cli.Context) (string, error) {
+	if setting(c, "cache-dir") != "" {
+		return setting(c, "cache-dir"), nil
+	}
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "holo"), nil
+}
+
+// copyFile copies src to dest; dest is never linked to src, so that
+// changing it leaves the cache alone
+func copyFile(src, dest string) error {
+	// dest may still be a link to the cache made by an older holo
+	os.Remove(dest)
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dest)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, in)
+	if err == nil {
+		err = out.Close()
+	} else {
+		out.Close()
+	}
+	return err
+}
+
+// downloadArtifact fetches an artifact into DESTINATION.part, resuming a
+// previous attempt when the server supports ranges, and only renames it to
+// DESTINATION once the SHA-256 matches
+func downloadArtifact(store Store, a *Artifact, dest string) error {
+	part := dest + ".part"
+	f, err := os.OpenFile(part, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	offset, err := io.Copy(hash, f)
+	if err != nil {
+		return err
+	}
+	restart := func() error {
+		offset = 0
+		hash.Reset()
+		err := f.Truncate(0)
+		if err != nil {
+			return err
+		}
+		_, err = f.Seek(0, io.SeekStart)
+		return err
+	}
+	if offset > a.Size {
+		err = restart()
+		if err != nil {
+			return err
+		}
+	}
+
+	if offset < a.Size {
+		rc, err := store.OpenArtifact(a.Release, a.Name, offset)
+		if err == errNoRange {
+			err = restart()
+			if err != nil {
+				return err
+			}
+			rc, err = store.OpenArtifact(a.Release, a.Name, 0)
+		}
+		if err != nil {
+			return err
+		}
+		defer rc.Close()
+		bar := newProgress(a.Size, offset)
+		_, err = io.Copy(io.MultiWriter(f, hash, bar), rc)
+		if err != nil {
+			return err
+		}
+		bar.finish()
+	}
+
+	sum := hex.EncodeToString(hash.Sum(nil))
+	if sum != a.SHA256 {
+		os.Remove(part)
+		return errors.New("checksum mismatch for " + a.Name + ": expected " + a.SHA256 + ", got " + sum)
+	}
+	err = f.Close()
 	if err != nil {
 		return err
 	}
-	err = checkFlagVal(c, "release")
+	return os.Rename(part, dest)
+}
+
+// check input arguments:
+func getArtifactsCheckArg(c *cli.Context) error {
+	err := getArtifactsArgs.check(c)
 	if err != nil {
 		return err
 	}
+	// FILENAME is left out only when the filters pick the artifact
+	filtered := c.String("device") != "" || c.String("board") != "" || c.String("kind") != ""
+	if filtered != (c.NArg() == 1) {
+		return sendArgErr(c, "incorrect number of arguments")
+	}
 	return nil
 }
@@ -0,0 +1,423 @@
+// file: cmdBisect.go
+// purpose: holo subCMD - bisect
+//
+//      - description: find the submission that introduced a regression,
+//                     binary searching the releases between a good and a
+//                     bad one, then the submissions that changed between
+//                     the last good and the first bad release
+//            - usage: holo bisect subcommand
+//      - subcommands: start, good, bad, skip, status, run, reset
+//
+//        => subCMD 1: start
+//             - desc: start a bisect between two releases of a train
+//            - usage: holo bisect start --good RELEASE --bad RELEASE
+//          - example: holo bisect start --good Thundercat1A170 --bad Thundercat1A176
+//   - example output: Bisecting Thundercat1A170..Thundercat1A176: 5 releases left to test
+//                     Test: Thundercat1A173
+//    - output fields: release, applied, submissions, remaining
+//
+//        => subCMD 2: good / bad / skip
+//             - desc: mark the step under test and move on to the next one
+//            - usage: holo bisect good
+//                     holo bisect bad
+//                     holo bisect skip
+//          - example: holo bisect bad
+//   - example output: Bisecting Thundercat1A170..Thundercat1A173: 2 releases left to test
+//                     Test: Thundercat1A171
+//
+//                     once two adjacent releases are marked good and bad,
+//                     the submissions in between are tested one at a time:
+//                     Bisecting Thundercat1A171..Thundercat1A172: 3 submissions left to test
+//                     Test: Thundercat1A171 + zlib-12, osd-37
+//                     Submissions:
+//                     - bash-4
+//                     - osd-37
+//                     - zlib-12
+//
+//                     and at the end (or "First bad release: RELEASE" when
+//                     no submission changed between the two):
+//                     Culprit: osd-37
+//                     Submitted by: steve@bowerswilkins.com
+//                     Summary: Reworked pairing
+//    - output fields: release, applied, submissions, remaining
+//
+//        => subCMD 3: status
+//             - desc: show the step under test and the marks so far
+//            - usage: holo bisect status
+//          - example: holo bisect status
+//   - example output: Bisecting Thundercat1A170..Thundercat1A173: 2 releases left to test
+//                     Test: Thundercat1A171
+//                     Marks:
+//                     - Thundercat1A173: bad
+//    - output fields: train, good, bad, phase, steps, lo, hi, skipped,
+//                     current, culprit, marks
+//
+//        => subCMD 4: run
+//             - desc: mark every step by running a script until the culprit
+//                     is found; exit code 0 marks good, 125 skip and any
+//                     other bad
+//            - usage: holo bisect run SCRIPT [ARGS...] [--device DEVICE]
+//                       [--board BOARD] [--kind KIND]
+//          - example: holo bisect run --board DVT2 --kind USBImage ./flash-and-test.sh
+//   - example output: Thundercat1A173: bad
+//                     Thundercat1A171: good
+//                     ...
+//                     Culprit: osd-37
+//
+//                     the script gets the step in HOLO_BISECT_RELEASE and
+//                     HOLO_BISECT_SUBMISSIONS (space separated); with a
+//                     filter, the matching artifacts of a release step are
+//                     downloaded into the directory in HOLO_BISECT_ARTIFACTS
+//
+//        => subCMD 5: reset
+//             - desc: forget the bisect in progress
+//            - usage: holo bisect reset
+//          - example: holo bisect reset
+//   - example output: Done!
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/urfave/cli"
+)
+
+var startBisectArgs = argSchema{
+	Flags: []flagSpec{
+		{Name: "good", Value: "RELEASE", Kind: "release", Required: true, Usage: "a release without the regression"},
+		{Name: "bad", Value: "RELEASE", Kind: "release", Required: true, Usage: "a later release with the regression"},
+	},
+}
+
+var runBisectArgs = argSchema{
+	Args: []argSpec{
+		{Name: "SCRIPT"},
+		{Name: "ARGS", Optional: true, Variadic: true, Kind: "text"},
+	},
+	Flags: artifactFilterFlags,
+}
+
+func setBisectCmd() cli.Command {
+
+	return cli.Command{
+		Name:  "bisect",
+		Usage: "find the submission that introduced a regression",
+		Subcommands: []cli.Command{
+			{
+				Name:      "start",
+				ArgsUsage: startBisectArgs.usage(),
+				Usage:     "start a bisect between two releases",
+				Flags:     startBisectArgs.flags(),
+				Action:    startBisectAction,
+			},
+			{
+				Name:      "good",
+				ArgsUsage: noArgs.usage(),
+				Usage:     "mark the step under test good",
+				Action:    markBisectAction,
+			},
+			{
+				Name:      "bad",
+				ArgsUsage: noArgs.usage(),
+				Usage:     "mark the step under test bad",
+				Action:    markBisectAction,
+			},
+			{
+				Name:      "skip",
+				ArgsUsage: noArgs.usage(),
+				Usage:     "skip the step under test",
+				Action:    markBisectAction,
+			},
+			{
+				Name:      "status",
+				ArgsUsage: noArgs.usage(),
+				Usage:     "show the step under test",
+				Action:    statusBisectAction,
+			},
+			{
+				Name:      "run",
+				ArgsUsage: runBisectArgs.usage(),
+				Usage:     "mark every step by running a script",
+				Flags:     runBisectArgs.flags(),
+				Action:    runBisectAction,
+			},
+			{
+				Name:      "reset",
+				ArgsUsage: noArgs.usage(),
+				Usage:     "forget the bisect in progress",
+				Action:    resetBisectAction,
+			},
+		},
+	}
+}
+
+// Subcommand: start
+func startBisectAction(c *cli.Context) error {
+	// check arguments
+	err := startBisectArgs.check(c)
+	if err != nil {
+		return err
+	}
+
+	store, err := openStore(c)
+	if err != nil {
+		return sendCmdErr(err)
+	}
+	bs, err := startBisect(store, c.String("good"), c.String("bad"))
+	if err != nil {
+		return sendCmdErr(err)
+	}
+	err = saveBisect(c, bs)
+	if err != nil {
+		return sendCmdErr(err)
+	}
+	return printBisect(c, store, bs)
+}
+
+// Subcommand: good, bad, skip
+func markBisectAction(c *cli.Context) error {
+	// check arguments
+	err := noArgs.check(c)
+	if err != nil {
+		return err
+	}
+
+	store, err := openStore(c)
+	if err != nil {
+		return sendCmdErr(err)
+	}
+	bs, err := loadBisect(c)
+	if err != nil {
+		return sendCmdErr(err)
+	}
+	err = markBisect(store, bs, c.Command.Name)
+	if err != nil {
+		return sendCmdErr(err)
+	}
+	err = saveBisect(c, bs)
+	if err != nil {
+		return sendCmdErr(err)
+	}
+	return printBisect(c, store, bs)
+}
+
+// Subcommand: status
+func statusBisectAction(c *cli.Context) error {
+	// check arguments
+	err := noArgs.check(c)
+	if err != nil {
+		return err
+	}
+
+	store, err := openStore(c)
+	if err != nil {
+		return sendCmdErr(err)
+	}
+	bs, err := loadBisect(c)
+	if err != nil {
+		return sendCmdErr(err)
+	}
+	var werr error
+	err = printOutput(c, bs, func() {
+		werr = printBisectText(store, bs)
+		if werr != nil {
+			return
+		}
+		marks := []string{}
+		for _, m := range bs.Marks {
+			marks = append(marks, m.Step+": "+m.Mark)
+		}
+		if len(marks) > 0 {
+			printList("Marks", marks)
+		}
+	})
+	if err != nil {
+		return err
+	}
+	if werr != nil {
+		return sendCmdErr(werr)
+	}
+	return nil
+}
+
+// Subcommand: run
+func runBisectAction(c *cli.Context) error {
+	// check arguments
+	err := runBisectArgs.check(c)
+	if err != nil {
+		return err
+	}
+
+	store, err := openStore(c)
+	if err != nil {
+		return sendCmdErr(err)
+	}
+	bs, err := loadBisect(c)
+	if err != nil {
+		return sendCmdErr(err)
+	}
+	for bs.Culprit == "" && bs.Current != "" {
+		mark, err := runBisectStep(c, store, bs)
+		if err != nil {
+			return sendCmdErr(err)
+		}
+		fmt.Println(bs.Current + ": " + mark)
+		err = markBisect(store, bs, mark)
+		if err != nil {
+			return sendCmdErr(err)
+		}
+		err = saveBisect(c, bs)
+		if err != nil {
+			return sendCmdErr(err)
+		}
+	}
+	err = printBisectText(store, bs)
+	if err != nil {
+		return sendCmdErr(err)
+	}
+	return nil
+}
+
+// runBisectStep runs the script against the step under test and turns its
+// exit code into a mark
+func runBisectStep(c *cli.Context, store Store, bs *bisectState) (string, error) {
+	st, err := bs.step(store)
+	if err != nil {
+		return "", err
+	}
+	dir, err := ioutil.TempDir("", "holo-bisect-")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(dir)
+
+	filtered := c.String("device") != "" || c.String("board") != "" || c.String("kind") != ""
+	if filtered && len(st.Applied) == 0 {
+		artifacts, err := store.ListArtifacts(st.Release)
+		if err != nil {
+			return "", err
+		}
+		artifacts = filterArtifacts(artifacts, c.String("device"), c.String("board"), c.String("kind"))
+		if len(artifacts) == 0 {
+			return "", errors.New("release " + st.Release + " has no artifacts matching the filters")
+		}
+		for i := range artifacts {
+			err = fetchArtifact(c, store, &artifacts[i], filepath.Join(dir, artifacts[i].Name))
+			if err != nil {
+				return "", err
+			}
+		}
+	}
+
+	args := c.Args()
+	cmd := exec.Command(args.First(), args.Tail()...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	cmd.Env = append(os.Environ(),
+		"HOLO_BISECT_RELEASE="+st.Release,
+		"HOLO_BISECT_SUBMISSIONS="+strings.Join(st.Submissions, " "),
+		"HOLO_BISECT_ARTIFACTS="+dir,
+	)
+	err = cmd.Run()
+	var exit *exec.ExitError
+	switch {
+	case err == nil:
+		return "good", nil
+	case errors.As(err, &exit) && exit.ExitCode() == 125:
+		return "skip", nil
+	case errors.As(err, &exit) && exit.ExitCode() > 0:
+		return "bad", nil
+	}
+	return "", err
+}
+
+// Subcommand: reset
+func resetBisectAction(c *cli.Context) error {
+	// check arguments
+	err := noArgs.check(c)
+	if err != nil {
+		return err
+	}
+
+	err = resetBisect(c)
+	if err != nil {
+		return sendCmdErr(err)
+	}
+	fmt.Println("Done!")
+	return nil
+}
+
+// printBisect prints the step under test, or the outcome once there is
+// none left
+func printBisect(c *cli.Context, store Store, bs *bisectState) error {
+	var v interface{} = bs
+	if bs.Current != "" {
+		st, err := bs.step(store)
+		if err != nil {
+			return sendCmdErr(err)
+		}
+		v = st
+	}
+	var werr error
+	err := printOutput(c, v, func() {
+		werr = printBisectText(store, bs)
+	})
+	if err != nil {
+		return err
+	}
+	if werr != nil {
+		return sendCmdErr(werr)
+	}
+	return nil
+}
+
+func printBisectText(store Store, bs *bisectState) error {
+	if bs.Culprit != "" && bs.Phase == "releases" {
+		fmt.Println("First bad release:", bs.Culprit)
+		fmt.Println("No submission changed since", bs.Steps[bs.Lo]+", the regression comes from the release build itself")
+		return nil
+	}
+	if bs.Culprit != "" {
+		sub, err := store.GetSubmission(bs.Culprit)
+		if err != nil {
+			return err
+		}
+		fmt.Println("Culprit:", sub.Name)
+		if sub.Submitter != "" {
+			fmt.Println("Submitted by:", sub.Submitter)
+		}
+		if sub.Notes.Summary != "" {
+			fmt.Println("Summary:", sub.Notes.Summary)
+		}
+		return nil
+	}
+	if bs.Current == "" {
+		printList("Every step left was skipped, the culprit is one of", bs.suspects())
+		return nil
+	}
+
+	st, err := bs.step(store)
+	if err != nil {
+		return err
+	}
+	from, to := bs.Steps[bs.Lo], bs.Steps[bs.Hi]
+	if bs.Phase == "submissions" {
+		from, to = bs.Good, bs.Bad
+	}
+	left := bs.Phase
+	if st.Remaining == 1 {
+		left = strings.TrimSuffix(left, "s")
+	}
+	fmt.Printf("Bisecting %s..%s: %d %s left to test\n", from, to, st.Remaining, left)
+	fmt.Println("Test:", st)
+	if len(st.Applied) > 0 {
+		printList("Submissions", st.Submissions)
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+// file: cmdCompletion.go
+// purpose: holo subCMD - completion
+//
+//      - description: print the completion script of a shell, completing
+//                     subcommands, flags and the names of trains, releases,
+//                     components, submissions and artifacts
+//            - usage: holo completion bash|zsh|fish
+//          - example: source <(holo completion bash)                # ~/.bashrc
+//                     source <(holo completion zsh)                 # ~/.zshrc
+//                     holo completion fish > ~/.config/fish/completions/holo.fish
+//   - example output: $ holo artifacts get --release Thundercat1A176 Cake<TAB>
+//                     Cake1,1_DVT_1.0_Thundercat1A176_USBImage.img
+//                     Cake1,1_DVT2_1.0_Thundercat1A176_USBImage.img
+//                     ...
+
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/urfave/cli"
+)
+
+var completionShells = []string{"bash", "zsh", "fish"}
+
+// completionScripts call holo __complete with the words before the cursor
+// followed by the word under it, which may be empty
+var completionScripts = map[string]string{
+	"bash": `# bash completion for holo
+_holo() {
+	local IFS=$'\n'
+	COMPREPLY=($(holo __complete "${COMP_WORDS[@]:1:COMP_CWORD-1}" "${COMP_WORDS[COMP_CWORD]}" 2>/dev/null))
+}
+complete -o default -F _holo holo
+`,
+	"zsh": `#compdef holo
+# zsh completion for holo
+_holo() {
+	local -a candidates
+	candidates=("${(@f)$(holo __complete "${(@)words[2,CURRENT-1]}" "${words[CURRENT]}" 2>/dev/null)}")
+	if [[ -n "${candidates[1]}" ]]; then
+		compadd -- "${candidates[@]}"
+	else
+		_files
+	fi
+}
+if [[ "${funcstack[1]}" == "_holo" ]]; then
+	_holo "$@"
+else
+	compdef _holo holo
+fi
+`,
+	"fish": `# fish completion for holo
+function __holo_complete
+	set -l words (commandline -opc)
+	holo __complete $words[2..-1] (commandline -ct | string collect -a) 2>/dev/null
+end
+complete -c holo -a '(__holo_complete)'
+`,
+}
+
+var completionArgs = argSchema{
+	Args: []argSpec{
+		{Name: "SHELL", Choices: completionShells},
+	},
+}
+
+func setCompletionCmd() cli.Command {
+
+	return cli.Command{
+		Name:      "completion",
+		Usage:     "print the shell completion script",
+		ArgsUsage: completionArgs.usage(),
+		Action:    completionAction,
+	}
+}
+
+// setCompleteCmd is called by the completion scripts, not by users
+func setCompleteCmd() cli.Command {
+
+	return cli.Command{
+		Name:            "__complete",
+		Hidden:          true,
+		SkipFlagParsing: true,
+		Action:          completeAction,
+	}
+}
+
+func completionAction(c *cli.Context) error {
+	// check arguments
+	err := completionArgs.check(c)
+	if err != nil {
+		return err
+	}
+	fmt.Print(completionScripts[c.Args().First()])
+	return nil
+}
+
+func completeAction(c *cli.Context) error {
+	words := []string(c.Args())
+	if len(words) == 0 {
+		return nil
+	}
+
+	// global options in front of the command pick the store to complete
+	// from, so they are given back to the app
+	globals := []string{}
+	for len(words) > 2 && strings.HasPrefix(words[0], "-") {
+		f := findFlag(c.App.Flags, strings.TrimLeft(words[0], "-"))
+		if f == nil || !takesValue(f) || strings.Contains(words[0], "=") {
+			break
+		}
+		globals = append(globals, words[0], words[1])
+		words = words[2:]
+	}
+	if len(globals) > 0 {
+		args := append([]string{c.App.Name}, globals...)
+		return c.App.Run(append(append(args, c.Command.Name), words...))
+	}
+
+	cl := parseCompletionLine(c.App, words[:len(words)-1])
+	for _, candidate := range cl.candidates(c, words[len(words)-1]) {
+		fmt.Println(candidate)
+	}
+	return nil
+}
@@ -3,7 +3,7 @@
 //
 //      - description: manage components
 //            - usage: holo components subcommand
-//      - subcommands: ls, show, create, link, unlink
+//      - subcommands: ls, show, create, link, unlink, deps, graph
 //
 //        => subCMD 1: ls
 //             - desc: list all known components
@@ -16,6 +16,7 @@
 //                     osd
 //                     zlib
 //                     ...
+//    - output fields: name, created, owner, repo, deps, trains
 //
 //        => subCMD 2: show
 //             - desc: describe a given component
@@ -25,22 +26,30 @@
 //                     Created: 2015-03-09 09:00AM PST
 //                     Owner: steve@bowerswilkins.com
 //                     Repo: git@github.com:bowerswilkins/app.git
+//                     Depends on:
+//                     - zlib
 //                     Train membership:
 //                     - Avalanche
 //                     - Thundercat
 //                     - ThundercatDeuce
+//    - output fields: name, created, owner, repo, deps, trains
 //
 //        => subCMD 3: create
 //             - desc: create a new component
-//            - usage: holo components create COMPONENT --owner EMAIL [--repo URL]
+//            - usage: holo components create COMPONENT --owner EMAIL [--repo URL] [--deps COMPONENT1,COMPONENT2...]
+//                     (--owner defaults to the owner setting)
 //          - example: holo components create bash --owner cody@bowerswilkins.com --repo git@github.com:bw-oss/bash.git
+//                     holo components create osd --owner steve@bowerswilkins.com --deps zlib
 //   - example output: Success!
 //
 //        => subCMD 4: link
-//             - desc: make a component a member of a train
+//             - desc: make a component a member of a train (warns when the
+//                     train lacks some of its dependencies)
 //            - usage: holo components link COMPONENT --train TRAIN
-//          - example: holo components link bash --train Thundercat
-//   - example output: Success!
+//                     (--train defaults to the train setting)
+//          - example: holo components link jetpack --train Thundercat
+//   - example output: Warning: train Thundercat carries jetpack without its dependencies: bash
+//                     Success!
 //
 //        => subCMD 5: unlink
 //             - desc: remove a component from a train
@@ -48,15 +57,91 @@
 //          - example: holo components unlink bash --train Thundercat
 //   - example output: Are you sure? (y/n)
 //
+//        => subCMD 6: deps
+//             - desc: list the dependencies of a component, direct or not,
+//                     or with --reverse the components that depend on it;
+//                     --set replaces its declared dependencies
+//            - usage: holo components deps COMPONENT [--reverse]
+//                     holo components deps COMPONENT --set COMPONENT1,COMPONENT2...
+//          - example: holo components deps jetpack
+//   - example output: bash
+//                       readline
+//    - output fields: component, depth, via
+//
+//        => subCMD 7: graph
+//             - desc: export the dependency graph in DOT (use -o json or
+//                     -o yaml for data), optionally only the members of a
+//                     train
+//            - usage: holo components graph [--train TRAIN]
+//          - example: holo components graph | dot -Tpng > deps.png
+//   - example output: digraph holo {
+//                       "bash";
+//                       "jetpack";
+//                       "jetpack" -> "bash";
+//                     }
+//    - output fields: components, deps (from, to)
+//
 
 package main
 
 import (
 	"fmt"
+	"os"
+	"strings"
 
 	"github.com/urfave/cli"
 )
 
+var showComponentsArgs = argSchema{
+	Args: []argSpec{
+		{Name: "COMPONENT", Kind: "component"},
+	},
+}
+
+var createComponentsArgs = argSchema{
+	Args: []argSpec{
+		{Name: "COMPONENT", Kind: "component"},
+	},
+	Flags: []flagSpec{
+		{Name: "owner", Value: "EMAIL", Kind: "email", Required: true, Setting: "owner", Usage: "the email of a owner"},
+		{Name: "repo", Value: "URL", Usage: "URL for the repo"},
+		{Name: "deps", Value: "COMPONENT", Kind: "component", List: true, Usage: "components it depends on"},
+	},
+}
+
+var linkComponentsArgs = argSchema{
+	Args: []argSpec{
+		{Name: "COMPONENT", Kind: "component"},
+	},
+	Flags: []flagSpec{
+		{Name: "train", Value: "TRAIN", Kind: "train", Required: true, Setting: "train", Usage: "name of a build train"},
+	},
+}
+
+var unlinkComponentsArgs = argSchema{
+	Args: linkComponentsArgs.Args,
+	Flags: []flagSpec{
+		linkComponentsArgs.Flags[0],
+		forceFlag,
+	},
+}
+
+var depsComponentsArgs = argSchema{
+	Args: []argSpec{
+		{Name: "COMPONENT", Kind: "component"},
+	},
+	Flags: []flagSpec{
+		{Name: "reverse", Usage: "list the components that depend on it instead"},
+		{Name: "set", Value: "COMPONENT", Kind: "component", List: true, Usage: "replace its dependencies (\"none\" clears them)"},
+	},
+}
+
+var graphComponentsArgs = argSchema{
+	Flags: []flagSpec{
+		{Name: "train", Value: "TRAIN", Kind: "train", Usage: "only the members of a build train"},
+	},
+}
+
 func setComponentsCmd() cli.Command {
 
 	return cli.Command{
@@ -65,59 +150,50 @@ func setComponentsCmd() cli.Command {
 		Subcommands: []cli.Command{
 			{
 				Name:      "ls",
-				ArgsUsage: " ",
+				ArgsUsage: noArgs.usage(),
 				Usage:     "list all known components",
 				Action:    lsComponentsAction,
 			},
 			{
 				Name:      "show",
-				ArgsUsage: "COMPONENT",
+				ArgsUsage: showComponentsArgs.usage(),
 				Usage:     "describe a given component",
 				Action:    showComponentsAction,
 			},
 			{
 				Name:      "create",
-				ArgsUsage: "COMPONENT --owner EMAIL [--repo URL]",
+				ArgsUsage: createComponentsArgs.usage(),
 				Usage:     "create a new component",
-				Flags: []cli.Flag{
-					cli.StringFlag{
-						Name:  "owner",
-						Usage: "the email of a owner",
-					},
-					cli.StringFlag{
-						Name:  "repo",
-						Usage: "URL for the repo",
-					},
-				},
-				Action: createComponentsAction,
+				Flags:     createComponentsArgs.flags(),
+				Action:    audited(createComponentsAction),
 			},
 			{
 				Name:      "link",
-				ArgsUsage: "COMPONENT --train TRAIN",
+				ArgsUsage: linkComponentsArgs.usage(),
 				Usage:     "make a component a member of a train",
-				Flags: []cli.Flag{
-					cli.StringFlag{
-						Name:  "train",
-						Usage: "name of a build train",
-					},
-				},
-				Action: linkComponentsAction,
+				Flags:     linkComponentsArgs.flags(),
+				Action:    audited(linkComponentsAction),
 			},
 			{
 				Name:      "unlink",
-				ArgsUsage: "COMPONENT --train TRAIN [-f/--force]",
+				ArgsUsage: unlinkComponentsArgs.usage(),
 				Usage:     "remove a component from a train",
-				Flags: []cli.Flag{
-					cli.StringFlag{
-						Name:  "train",
-						Usage: "name of a build train",
-					},
-					cli.BoolFlag{
-						Name:  "force, f",
-						Usage: "suppress prompts",
-					},
-				},
-				Action: unlinkComponentsAction,
+				Flags:     unlinkComponentsArgs.flags(),
+				Action:    audited(unlinkComponentsAction),
+			},
+			{
+				Name:      "deps",
+				ArgsUsage: depsComponentsArgs.usage(),
+				Usage:     "list or set the dependencies of a component",
+				Flags:     depsComponentsArgs.flags(),
+				Action:    audited(depsComponentsAction),
+			},
+			{
+				Name:      "graph",
+				ArgsUsage: graphComponentsArgs.usage(),
+				Usage:     "export the dependency graph",
+				Flags:     graphComponentsArgs.flags(),
+				Action:    graphComponentsAction,
 			},
 		},
 	}
@@ -126,103 +202,238 @@ func setComponentsCmd() cli.Command {
 // Subcommand: ls
 func lsComponentsAction(c *cli.Context) error {
 	// check arguments
-	err := checkNumArg(c, 0)
+	err := noArgs.check(c)
 	if err != nil {
 		return err
 	}
-	fmt.Println("In the function of components ls:")
-	fmt.Println("### cmd: holo components ls ###")
 
-	return nil
+	store, err := openStore(c)
+	if err != nil {
+		return sendCmdErr(err)
+	}
+	comps, err := store.ListComponents()
+	if err != nil {
+		return sendCmdErr(err)
+	}
+	return printOutput(c, comps, func() {
+		for _, comp := range comps {
+			fmt.Println(comp.Name)
+		}
+	})
 }
 
 // Subcommand: show
 func showComponentsAction(c *cli.Context) error {
 	// check arguments
-	err := checkNumArg(c, 1)
+	err := showComponentsArgs.check(c)
 	if err != nil {
 		return err
 	}
 
-	fmt.Println("In the function of components show:")
-	fmt.Println("### cmd: holo components show COMPONENT ###")
-	fmt.Println("COMPONENT: ", c.Args().Get(0))
-
-	return nil
+	store, err := openStore(c)
+	if err != nil {
+		return sendCmdErr(err)
+	}
+	comp, err := store.GetComponent(c.Args().Get(0))
+	if err != nil {
+		return sendCmdErr(err)
+	}
+	return printOutput(c, comp, func() {
+		fmt.Println("Component:", comp.Name)
+		fmt.Println("Created:", comp.Created.Format(timeLayout))
+		fmt.Println("Owner:", comp.Owner)
+		fmt.Println("Repo:", comp.Repo)
+		if len(comp.Deps) > 0 {
+			printList("Depends on", comp.Deps)
+		}
+		printList("Train membership", comp.Trains)
+	})
 }
 
 // Subcommand: create
 func createComponentsAction(c *cli.Context) error {
 	// check arguments
-	err := createComponentsCheckArg(c)
+	err := createComponentsArgs.check(c)
 	if err != nil {
 		return err
 	}
 
-	fmt.Println("In the function of components create:")
-	fmt.Println("### cmd: holo components create COMPONENT --owner EMAIL [--repo URL] ###")
-	fmt.Println("COMPONENT: ", c.Args().Get(0))
-	fmt.Println("owner-EMAIL: ", c.String("owner"))
-	fmt.Println("repo-URL: ", c.String("repo"))
+	store, err := openStore(c)
+	if err != nil {
+		return sendCmdErr(err)
+	}
+	comp := &Component{
+		Name:  c.Args().Get(0),
+		Owner: flagOrSetting(c, "owner", "owner"),
+		Repo:  c.String("repo"),
+		Deps:  splitList(c.String("deps")),
+	}
+	auditTargets(c, nil, nil, []string{comp.Name})
+	g, err := loadDepGraph(store)
+	if err != nil {
+		return sendCmdErr(err)
+	}
+	err = g.checkDeps(comp.Name, comp.Deps)
+	if err != nil {
+		return sendCmdErr(err)
+	}
+	err = store.CreateComponent(comp)
+	if err != nil {
+		return sendCmdErr(err)
+	}
+	fmt.Println("Success!")
 	return nil
 }
 
 // Subcommand: link
 func linkComponentsAction(c *cli.Context) error {
 	// check arguments
-	err := linkComponentsCheckArg(c)
+	err := linkComponentsArgs.check(c)
 	if err != nil {
 		return err
 	}
 
-	fmt.Println("In the function of components link:")
-	fmt.Println("### cmd: holo components link COMPONENT --train TRAIN ###")
-	fmt.Println("COMPONENT: ", c.Args().Get(0))
-	fmt.Println("train: ", c.String("train"))
+	store, err := openStore(c)
+	if err != nil {
+		return sendCmdErr(err)
+	}
+	train := flagOrSetting(c, "train", "train")
+	auditTargets(c, []string{train}, nil, []string{c.Args().Get(0)})
+	err = store.LinkComponent(c.Args().Get(0), train)
+	if err != nil {
+		return sendCmdErr(err)
+	}
+	err = warnMissingDeps(store, c.Args().Get(0), []string{train})
+	if err != nil {
+		return sendCmdErr(err)
+	}
+	fmt.Println("Success!")
 	return nil
 }
 
 // Subcommand: unlink
 func unlinkComponentsAction(c *cli.Context) error {
-	// check arguments (use the same checkArg function as linkComponentsAction)
-	err := linkComponentsCheckArg(c)
+	// check arguments
+	err := unlinkComponentsArgs.check(c)
 	if err != nil {
 		return err
 	}
 
-	fmt.Println("In the function of components unlink:")
-	fmt.Println("### cmd: holo components unlink COMPONENT --train TRAIN [-f/--force] ###")
-	fmt.Println("COMPONENT: ", c.Args().Get(0))
-	fmt.Println("train: ", c.String("train"))
-	fmt.Println("force:", c.Bool("force"))
+	store, err := openStore(c)
+	if err != nil {
+		return sendCmdErr(err)
+	}
+	train := flagOrSetting(c, "train", "train")
+	auditTargets(c, []string{train}, nil, []string{c.Args().Get(0)})
+	im, err := trainsImpact(store, []string{train}, c.Args().Get(0))
+	if err != nil {
+		return sendCmdErr(err)
+	}
+	im.header = "This operation will remove " + c.Args().Get(0) + " from the following build trains:"
+	err = confirm(c, im, "Are you sure?")
+	if err != nil {
+		return sendCmdErr(err)
+	}
+	err = store.UnlinkComponent(c.Args().Get(0), train)
+	if err != nil {
+		return sendCmdErr(err)
+	}
+	fmt.Println("Success!")
 	return nil
 }
 
-// check input arguments:
-func createComponentsCheckArg(c *cli.Context) error {
-	err := checkNumArg(c, 1)
+// Subcommand: deps
+func depsComponentsAction(c *cli.Context) error {
+	// check arguments
+	err := depsComponentsCheckArg(c)
 	if err != nil {
 		return err
 	}
-	err = checkFlagVal(c, "owner")
+
+	store, err := openStore(c)
 	if err != nil {
-		return err
+		return sendCmdErr(err)
 	}
-	err = checkOptionalFlagVal(c, "repo")
+	comp, err := store.GetComponent(c.Args().Get(0))
 	if err != nil {
-		return err
+		return sendCmdErr(err)
 	}
-	return nil
+	g, err := loadDepGraph(store)
+	if err != nil {
+		return sendCmdErr(err)
+	}
+
+	if c.String("set") != "" {
+		auditTargets(c, comp.Trains, nil, []string{comp.Name})
+		comp.Deps = splitList(c.String("set"))
+		if c.String("set") == "none" {
+			comp.Deps = nil
+		}
+		err = g.checkDeps(comp.Name, comp.Deps)
+		if err != nil {
+			return sendCmdErr(err)
+		}
+		err = store.UpdateComponent(comp)
+		if err != nil {
+			return sendCmdErr(err)
+		}
+		err = warnMissingDeps(store, comp.Name, comp.Trains)
+		if err != nil {
+			return sendCmdErr(err)
+		}
+		fmt.Println("Success!")
+		return nil
+	}
+
+	if c.Bool("reverse") {
+		g = g.reverse()
+	}
+	records := g.walk(comp.Name)
+	return printOutput(c, records, func() {
+		for _, rec := range records {
+			fmt.Println(strings.Repeat("  ", rec.Depth-1) + rec.Component)
+		}
+	})
 }
 
-func linkComponentsCheckArg(c *cli.Context) error {
-	err := checkNumArg(c, 1)
+// Subcommand: graph
+func graphComponentsAction(c *cli.Context) error {
+	// check arguments
+	err := graphComponentsArgs.check(c)
 	if err != nil {
 		return err
 	}
-	err = checkFlagVal(c, "train")
+
+	store, err := openStore(c)
+	if err != nil {
+		return sendCmdErr(err)
+	}
+	g, err := loadDepGraph(store)
+	if err != nil {
+		return sendCmdErr(err)
+	}
+	var members []string
+	if c.String("train") != "" {
+		t, err := store.GetTrain(c.String("train"))
+		if err != nil {
+			return sendCmdErr(err)
+		}
+		members = t.Components
+	}
+	ex := g.export(members)
+	return printOutput(c, ex, func() {
+		writeDOT(os.Stdout, ex)
+	})
+}
+
+// check input arguments:
+func depsComponentsCheckArg(c *cli.Context) error {
+	err := depsComponentsArgs.check(c)
 	if err != nil {
 		return err
 	}
+	if c.Bool("reverse") && c.String("set") != "" {
+		return sendArgErr(c, "--reverse and --set cannot be combined")
+	}
 	return nil
 }
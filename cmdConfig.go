@@ -0,0 +1,190 @@
+// file: cmdConfig.go
+// purpose: holo subCMD - config
+//
+//      - description: manage the holo configuration file
+//            - usage: holo config subcommand
+//      - subcommands: get, set, list, use
+//
+//        => subCMD 1: get
+//             - desc: show the effective value of a setting
+//            - usage: holo config get KEY
+//          - example: holo config get server
+//   - example output: http://holo.bowerswilkins.com
+//
+//        => subCMD 2: set
+//             - desc: change a setting of the selected profile
+//                     (an empty VALUE removes the setting)
+//            - usage: holo config set KEY VALUE
+//          - example: holo --profile laptop config set store /home/steve/holo-test
+//   - example output: Success!
+//
+//        => subCMD 3: list
+//             - desc: list the effective settings and where they come from
+//            - usage: holo config list
+//          - example: holo config list
+//   - example output: Profile: default (available: default, laptop)
+//                     server = http://holo.bowerswilkins.com (profile default)
+//                     store =
+//                     train = Thundercat ($HOLO_TRAIN)
+//                     ...
+//    - output fields: key, value, source
+//
+//        => subCMD 4: use
+//             - desc: make a profile the default one
+//            - usage: holo config use PROFILE
+//          - example: holo config use laptop
+//   - example output: Success!
+
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/urfave/cli"
+)
+
+type settingRecord struct {
+	Key    string `json:"key" yaml:"key"`
+	Value  string `json:"value" yaml:"value"`
+	Source string `json:"source" yaml:"source"`
+}
+
+var getConfigArgs = argSchema{
+	Args: []argSpec{
+		{Name: "KEY", Kind: "key"},
+	},
+}
+
+var setConfigArgs = argSchema{
+	Args: []argSpec{
+		{Name: "KEY", Kind: "key"},
+		{Name: "VALUE", Kind: "text"},
+	},
+}
+
+var useConfigArgs = argSchema{
+	Args: []argSpec{
+		{Name: "PROFILE", Kind: "profile"},
+	},
+}
+
+func setConfigCmd() cli.Command {
+
+	return cli.Command{
+		Name:  "config",
+		Usage: "manage the holo configuration",
+		Subcommands: []cli.Command{
+			{
+				Name:      "get",
+				ArgsUsage: getConfigArgs.usage(),
+				Usage:     "show the effective value of a setting",
+				Action:    getConfigAction,
+			},
+			{
+				Name:      "set",
+				ArgsUsage: setConfigArgs.usage(),
+				Usage:     "change a setting of the selected profile",
+				Action:    setConfigAction,
+			},
+			{
+				Name:      "list",
+				ArgsUsage: noArgs.usage(),
+				Usage:     "list the effective settings and where they come from",
+				Action:    listConfigAction,
+			},
+			{
+				Name:      "use",
+				ArgsUsage: useConfigArgs.usage(),
+				Usage:     "make a profile the default one",
+				Action:    useConfigAction,
+			},
+		},
+	}
+}
+
+// Subcommand: get
+func getConfigAction(c *cli.Context) error {
+	// check arguments
+	err := getConfigArgs.check(c)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(setting(c, c.Args().Get(0)))
+	return nil
+}
+
+// Subcommand: set
+func setConfigAction(c *cli.Context) error {
+	// check arguments
+	err := setConfigArgs.check(c)
+	if err != nil {
+		return err
+	}
+	key := c.Args().Get(0)
+
+	name := profileName(c)
+	prof := holoConfig.Profiles[name]
+	if prof == nil {
+		prof = map[string]string{}
+		holoConfig.Profiles[name] = prof
+	}
+	if c.Args().Get(1) == "" {
+		delete(prof, key)
+	} else {
+		prof[key] = c.Args().Get(1)
+	}
+	err = holoConfig.save(configPath(c))
+	if err != nil {
+		return sendCmdErr(err)
+	}
+	fmt.Println("Success!")
+	return nil
+}
+
+// Subcommand: list
+func listConfigAction(c *cli.Context) error {
+	// check arguments
+	err := noArgs.check(c)
+	if err != nil {
+		return err
+	}
+
+	records := []settingRecord{}
+	for _, key := range settingKeys {
+		value, source := settingSource(c, key)
+		records = append(records, settingRecord{Key: key, Value: value, Source: source})
+	}
+	return printOutput(c, records, func() {
+		fmt.Printf("Profile: %s (available: %s)\n", profileName(c), strings.Join(profileNames(), ", "))
+		for _, r := range records {
+			if r.Source == "" {
+				fmt.Printf("%s = %s\n", r.Key, r.Value)
+			} else {
+				fmt.Printf("%s = %s (%s)\n", r.Key, r.Value, r.Source)
+			}
+		}
+	})
+}
+
+// Subcommand: use
+func useConfigAction(c *cli.Context) error {
+	// check arguments
+	err := useConfigArgs.check(c)
+	if err != nil {
+		return err
+	}
+
+	name := c.Args().Get(0)
+	if holoConfig.Profiles[name] == nil {
+		holoConfig.Profiles[name] = map[string]string{}
+	}
+	holoConfig.Profile = name
+	err = holoConfig.save(configPath(c))
+	if err != nil {
+		return sendCmdErr(err)
+	}
+	fmt.Println("Success!")
+	return nil
+}
@@ -0,0 +1,139 @@
+// file: cmdIssues.go
+// purpose: holo subCMD - issues
+//
+//      - description: list the issues a release ships, with their title and
+//                     status when a tracker is set, and optionally comment
+//                     on each of them in the tracker
+//            - usage: holo issues RELEASE [--since RELEASE] [--comment] [-f/--force]
+//          - example: holo issues Thundercat1A177
+//   - example output: VHAP-27 (osd-39) [open]: Better tiling
+//                     VHAP-89 (osd-39) [open]: Some crash again
+//
+//                     issues come from the submissions the release brings in
+//                     since --since (default: the base of RELEASE)
+//    - output fields: key, release, component, submission, title, status
+//
+//          - example: holo issues Thundercat1A177 --comment
+//   - example output: This operation will comment "fixed in Thundercat1A177" on the following issues:
+//
+//                     - VHAP-27
+//                     - VHAP-89
+//
+//                     Really comment on 2 issues? (y/n) y
+//                     Done!
+//
+//                     issues that already carry the comment are skipped, so
+//                     commenting again after a failure is safe
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/urfave/cli"
+)
+
+var issuesArgs = argSchema{
+	Args: []argSpec{
+		{Name: "RELEASE", Kind: "release"},
+	},
+	Flags: []flagSpec{
+		{Name: "since", Value: "RELEASE", Kind: "release", Usage: "release to start from (default: the base of RELEASE)"},
+		{Name: "comment", Usage: "comment \"fixed in RELEASE\" on each issue in the tracker"},
+		forceFlag,
+	},
+}
+
+func setIssuesCmd() cli.Command {
+
+	return cli.Command{
+		Name:      "issues",
+		Usage:     "list the issues shipped in a release",
+		ArgsUsage: issuesArgs.usage(),
+		Flags:     issuesArgs.flags(),
+		Action:    audited(cmdIssuesAction),
+	}
+}
+
+func cmdIssuesAction(c *cli.Context) error {
+	// check input arguments
+	err := issuesArgs.check(c)
+	if err != nil {
+		return err
+	}
+
+	store, err := openStore(c)
+	if err != nil {
+		return sendCmdErr(err)
+	}
+	tracker, err := openTracker(c)
+	if err != nil {
+		return sendCmdErr(err)
+	}
+	release := c.Args().Get(0)
+	issues, err := releaseIssues(store, release, c.String("since"))
+	if err != nil {
+		return sendCmdErr(err)
+	}
+	if c.Bool("comment") {
+		auditRelease(c, store, release)
+		for _, is := range issues {
+			auditTargets(c, nil, nil, []string{is.Component})
+		}
+		return commentIssues(c, tracker, issues, "fixed in "+release)
+	}
+	err = lookupIssues(tracker, issues)
+	if err != nil {
+		return sendCmdErr(err)
+	}
+	return printOutput(c, issues, func() {
+		printIssues(issues)
+	})
+}
+
+// commentIssues posts a comment on every issue the tracker knows, once:
+// issues already carrying the comment are left alone
+func commentIssues(c *cli.Context, tracker Tracker, issues []shippedIssue, comment string) error {
+	if tracker == nil {
+		return sendCmdErr(errors.New("no tracker to comment in, set the tracker setting"))
+	}
+	var keys []string
+	for _, is := range issues {
+		if containsStr(keys, is.Key) {
+			continue
+		}
+		issue, err := tracker.GetIssue(is.Key)
+		if errors.Is(err, errNotFound) {
+			fmt.Fprintln(os.Stderr, "Warning: the tracker has no issue "+is.Key+", skipped")
+			continue
+		}
+		if err != nil {
+			return sendCmdErr(err)
+		}
+		if containsStr(issue.Comments, comment) {
+			fmt.Fprintln(os.Stderr, "Warning: "+is.Key+" already says \""+comment+"\", skipped")
+			continue
+		}
+		keys = append(keys, is.Key)
+	}
+	if len(keys) == 0 {
+		fmt.Println("No issues to comment on")
+		return nil
+	}
+	im := &impact{header: "This operation will comment \"" + comment + "\" on the following issues:", items: keys}
+	err := confirm(c, im, "Really comment on "+strconv.Itoa(len(keys))+" issues?")
+	if err != nil {
+		return sendCmdErr(err)
+	}
+	for _, key := range keys {
+		err = tracker.AddComment(key, comment)
+		if err != nil {
+			return sendCmdErr(err)
+		}
+	}
+	fmt.Println("Done!")
+	return nil
+}
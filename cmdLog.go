@@ -0,0 +1,75 @@
+// file: cmdLog.go
+// purpose: holo subCMD - log
+//
+//      - description: show the audit log of the commands that changed
+//                     trains, releases, components and submissions
+//            - usage: holo log [--train TRAIN] [--component COMPONENT] [--since DATE]
+//          - example: holo log --train Thundercat --since 2017-06-01
+//   - example output: 2017-06-01 09:12AM PST steve@bowerswilkins.com ok: holo submit osd-38 .
+//                     2017-06-01 12:34PM PST ann@bowerswilkins.com aborted: holo releases cut Thundercat1A177
+//                     2017-06-01 12:35PM PST ann@bowerswilkins.com failed: holo releases cut Thundercat1A177 -f
+//                       release Thundercat1A177 is locked
+//
+//                     DATE is 2017-06-01, "2017-06-01 12:34" or an RFC 3339
+//                     time; entries are listed oldest first
+//    - output fields: time, actor, command, trains, releases, components,
+//                     outcome, error
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/urfave/cli"
+)
+
+var logArgs = argSchema{
+	Flags: []flagSpec{
+		{Name: "train", Value: "TRAIN", Kind: "train", Usage: "only the commands that touched a train"},
+		{Name: "component", Value: "COMPONENT", Kind: "component", Usage: "only the commands that touched a component"},
+		{Name: "since", Value: "DATE", Kind: "date", Usage: "only the commands run since a date"},
+	},
+}
+
+func setLogCmd() cli.Command {
+
+	return cli.Command{
+		Name:      "log",
+		Usage:     "show the audit log of changes",
+		ArgsUsage: logArgs.usage(),
+		Flags:     logArgs.flags(),
+		Action:    cmdLogAction,
+	}
+}
+
+func cmdLogAction(c *cli.Context) error {
+	// check input arguments
+	err := logArgs.check(c)
+	if err != nil {
+		return err
+	}
+
+	q := auditQuery{Train: c.String("train"), Component: c.String("component")}
+	if c.String("since") != "" {
+		q.Since, err = parseAuditDate(c.String("since"))
+		if err != nil {
+			return sendCmdErr(err)
+		}
+	}
+	store, err := openStore(c)
+	if err != nil {
+		return sendCmdErr(err)
+	}
+	entries, err := store.ListAudit(q)
+	if err != nil {
+		return sendCmdErr(err)
+	}
+	return printOutput(c, entries, func() {
+		for _, e := range entries {
+			fmt.Printf("%s %s %s: %s\n", e.Time.Local().Format(timeLayout), e.Actor, e.Outcome, e.Command)
+			if e.Error != "" {
+				fmt.Println("  " + e.Error)
+			}
+		}
+	})
+}
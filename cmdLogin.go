@@ -0,0 +1,212 @@
+// file: cmdLogin.go
+// purpose: holo subCMDs - login, logout, whoami
+//
+//      - description: authenticate against the build service of the selected profile
+//
+//        => CMD 1: login
+//             - desc: obtain and store an API token
+//            - usage: holo login EMAIL [--password-stdin]
+//          - example: holo --server http://localhost:8080 login steve@bowerswilkins.com
+//   - example output: Password:
+//                     Logged in as steve@bowerswilkins.com (expires 2017-06-03 11:42AM PST)
+//
+//                     the password is asked for on the terminal, without
+//                     echo, unless --password-stdin is given
+//
+//        => CMD 2: logout
+//             - desc: revoke and forget the stored API token
+//            - usage: holo logout
+//          - example: holo logout
+//   - example output: Logged out.
+//
+//        => CMD 3: whoami
+//             - desc: show the identity holo acts as
+//            - usage: holo whoami
+//          - example: holo whoami
+//   - example output: steve@bowerswilkins.com (http://localhost:8080, profile default, expires 2017-06-03 11:42AM PST)
+//    - output fields: email, server, profile, expires
+
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/urfave/cli"
+)
+
+type whoamiRecord struct {
+	Email   string     `json:"email" yaml:"email"`
+	Server  string     `json:"server,omitempty" yaml:"server,omitempty"`
+	Profile string     `json:"profile" yaml:"profile"`
+	Expires *time.Time `json:"expires,omitempty" yaml:"expires,omitempty"`
+}
+
+var loginArgs = argSchema{
+	Args: []argSpec{
+		{Name: "EMAIL", Kind: "email"},
+	},
+	Flags: []flagSpec{
+		{Name: "password-stdin", Usage: "read the password from stdin"},
+	},
+}
+
+func setLoginCmd() cli.Command {
+
+	return cli.Command{
+		Name:      "login",
+		Usage:     "obtain and store an API token",
+		ArgsUsage: loginArgs.usage(),
+		Flags:     loginArgs.flags(),
+		Action:    cmdLoginAction,
+	}
+}
+
+func setLogoutCmd() cli.Command {
+
+	return cli.Command{
+		Name:      "logout",
+		Usage:     "revoke and forget the stored API token",
+		ArgsUsage: noArgs.usage(),
+		Action:    cmdLogoutAction,
+	}
+}
+
+func setWhoamiCmd() cli.Command {
+
+	return cli.Command{
+		Name:      "whoami",
+		Usage:     "show the identity holo acts as",
+		ArgsUsage: noArgs.usage(),
+		Action:    cmdWhoamiAction,
+	}
+}
+
+func cmdLoginAction(c *cli.Context) error {
+	// check input arguments
+	err := loginArgs.check(c)
+	if err != nil {
+		return err
+	}
+	email := c.Args().Get(0)
+	if setting(c, "server") == "" {
+		return sendCmdErr(errors.New("no server to log in to, set --server or the server setting"))
+	}
+
+	var password string
+	if c.Bool("password-stdin") {
+		password, err = bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && password == "" {
+			return sendCmdErr(errors.New("no password on stdin"))
+		}
+		password = strings.TrimRight(password, "\r\n")
+	} else {
+		password, err = promptPassword()
+		if err != nil {
+			return sendCmdErr(err)
+		}
+	}
+
+	cred, err := newAPIClient(setting(c, "server")).login(email, password)
+	if err != nil {
+		return sendCmdErr(err)
+	}
+	cred.Server = setting(c, "server")
+	err = saveCredentials(c, cred)
+	if err != nil {
+		return sendCmdErr(err)
+	}
+	fmt.Printf("Logged in as %s (expires %s)\n", cred.Email, cred.Expires.Format(timeLayout))
+	return nil
+}
+
+// promptPassword asks for a password on the terminal with echo turned off
+func promptPassword() (string, error) {
+	if !isTerminal(os.Stdin) {
+		return "", errors.New("stdin is not a terminal to ask for the password on, use --password-stdin")
+	}
+	stty := func(arg string) error {
+		cmd := exec.Command("stty", arg)
+		cmd.Stdin = os.Stdin
+		return cmd.Run()
+	}
+	err := stty("-echo")
+	if err != nil {
+		return "", errors.New("cannot turn off echo, use --password-stdin: " + err.Error())
+	}
+	defer stty("echo")
+	fmt.Fprint(os.Stderr, "Password: ")
+	password, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	fmt.Fprintln(os.Stderr)
+	if err != nil && password == "" {
+		return "", errors.New("no password given")
+	}
+	return strings.TrimRight(password, "\r\n"), nil
+}
+
+func cmdLogoutAction(c *cli.Context) error {
+	// check input arguments
+	err := noArgs.check(c)
+	if err != nil {
+		return err
+	}
+
+	cred, err := loadCredentials(c)
+	if err != nil {
+		return sendCmdErr(err)
+	}
+	if cred == nil {
+		fmt.Println("Not logged in.")
+		return nil
+	}
+	// revoking is best effort, the token is forgotten either way
+	client := newAPIClient(cred.Server)
+	if time.Now().Before(cred.Expires) {
+		client.cred = cred
+		client.logout()
+	}
+	err = removeCredentials(c)
+	if err != nil {
+		return sendCmdErr(err)
+	}
+	fmt.Println("Logged out.")
+	return nil
+}
+
+func cmdWhoamiAction(c *cli.Context) error {
+	// check input arguments
+	err := noArgs.check(c)
+	if err != nil {
+		return err
+	}
+
+	rec := &whoamiRecord{Profile: profileName(c), Server: setting(c, "server")}
+	if rec.Server == "" {
+		rec.Email = actor(c)
+		return printOutput(c, rec, func() {
+			fmt.Printf("%s (local store, profile %s)\n", rec.Email, rec.Profile)
+		})
+	}
+
+	client, err := newAuthAPIClient(c)
+	if err != nil {
+		return sendCmdErr(err)
+	}
+	if client.cred == nil {
+		return sendCmdErr(errors.New("not logged in to " + rec.Server + ", run holo login"))
+	}
+	cred, err := client.whoami()
+	if err != nil {
+		return sendCmdErr(err)
+	}
+	rec.Email = cred.Email
+	rec.Expires = &cred.Expires
+	return printOutput(c, rec, func() {
+		fmt.Printf("%s (%s, profile %s, expires %s)\n", rec.Email, rec.Server, rec.Profile, rec.Expires.Format(timeLayout))
+	})
+}
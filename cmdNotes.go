@@ -1,8 +1,10 @@
 // file: cmdNotes.go
 // purpose: holo subCMD - notes
 //
-//      - description: show the release notes for a submission
-//            - usage: holo notes SUBMISSION
+//      - description: show the release notes for a submission, or aggregate
+//                     the notes of every submission in a range of releases
+//            - usage: holo notes SUBMISSION [--issues]
+//                     holo notes --release RELEASE [--since RELEASE] [--format text|markdown|html] [--issues]
 //          - example: holo notes osd-37
 //   - example output: Submitted by: steve@bowerswilkins.com
 //                     Date: 2017-06-01 12:34PM PST
@@ -18,35 +20,172 @@
 //
 //                     VHAP-47: Crash when trying to tile three devices
 //                     VHAP-88: Crash when entering Settings
+//    - output fields: name, component, version, created, submitter, notes (summary,
+//                     features, bugs, other), issues, trains, commit, sha256
+//
+//          - example: holo notes --release Thundercat1A177 --since Thundercat1A170 --format markdown
+//   - example output: # Release notes for Thundercat1A177
+//
+//                     Changes since Thundercat1A170.
+//
+//                     ## osd
+//
+//                     osd-35 -> osd-39
+//
+//                     ### Summary
+//
+//                     - osd-37: osd-37 adds new features and fixes some bugs.
+//                     - osd-39: Tiling and crash fixes
+//
+//                     ### New features
+//
+//                     - VHAP-27: Better tiling
+//
+//                     ### Bugs fixed
+//
+//                     - VHAP-47: Crash when trying to tile three devices
+//
+//                     submissions are grouped by component; --since defaults to
+//                     the base of RELEASE; within a component a line naming
+//                     the same issue keys as an earlier one, or repeating a
+//                     line without keys, is listed only once
+//    - output fields: release, since,
+//                     components (component, from, to, submissions, summaries, features, bugs,
+//                     other, reverted)
+//
+//          - example: holo notes osd-39 --issues
+//   - example output: VHAP-27 (osd-39) [open]: Better tiling
+//                     VHAP-89 (osd-39) [open]: Some crash again
+//
+//                     lists the issues of a submission, or with --release
+//                     those shipped in the release, as holo issues does
+//    - output fields: key, release, component, submission, title, status
 
 package main
 
 import (
 	"fmt"
+	"os"
 
 	"github.com/urfave/cli"
 )
 
+var notesArgs = argSchema{
+	Args: []argSpec{
+		{Name: "SUBMISSION", Kind: "submission", Optional: true},
+	},
+	Flags: []flagSpec{
+		{Name: "release", Value: "RELEASE", Kind: "release", Usage: "aggregate the notes of the submissions in a release"},
+		{Name: "since", Value: "RELEASE", Kind: "release", Usage: "release to start from (default: the base of --release)"},
+		{Name: "format", Value: "FORMAT", Choices: []string{"text", "markdown", "html"}, Usage: "format of aggregated notes: text, markdown or html"},
+		{Name: "issues", Usage: "list the issue keys instead of the notes"},
+	},
+}
+
 func setNotesCmd() cli.Command {
 
 	return cli.Command{
 		Name:      "notes",
-		UsageText: "holo notes SUBMISSION",
-		Usage:     "show the release notes for a submission",
-		ArgsUsage: "SUBMISSION",
+		UsageText: "holo notes SUBMISSION [--issues] | holo notes --release RELEASE [--since RELEASE] [--format text|markdown|html] [--issues]",
+		Usage:     "show the release notes for a submission or a range of releases",
+		ArgsUsage: notesArgs.usage(),
+		Flags:     notesArgs.flags(),
 		Action:    cmdNotesAction,
 	}
 }
 
 func cmdNotesAction(c *cli.Context) error {
 	// check input arguments
-	err := checkNumArg(c, 1)
+	err := notesCheckArg(c)
 	if err != nil {
 		return err
 	}
+	if c.Bool("issues") {
+		return notesIssuesAction(c)
+	}
+	if c.String("release") != "" {
+		return releaseNotesAction(c)
+	}
+
+	store, err := openStore(c)
+	if err != nil {
+		return sendCmdErr(err)
+	}
+	sub, err := store.GetSubmission(c.Args().Get(0))
+	if err != nil {
+		return sendCmdErr(err)
+	}
+	return printOutput(c, sub, func() {
+		fmt.Println("Submitted by:", sub.Submitter)
+		fmt.Println("Date:", sub.Created.Format(timeLayout))
+		fmt.Println()
+		for _, line := range sub.Notes.lines() {
+			fmt.Println(line)
+		}
+	})
+}
 
-	fmt.Println("In the function of notes:")
-	fmt.Println("### cmd: holo notes SUBMISSION ###")
-	fmt.Println("SUBMISSION: ", c.Args().Get(0))
+func releaseNotesAction(c *cli.Context) error {
+	store, err := openStore(c)
+	if err != nil {
+		return sendCmdErr(err)
+	}
+	rn, err := collectNotes(store, c.String("release"), c.String("since"))
+	if err != nil {
+		return sendCmdErr(err)
+	}
+	var werr error
+	err = printOutput(c, rn, func() {
+		werr = writeNotes(os.Stdout, rn, c.String("format"))
+	})
+	if werr != nil {
+		return sendCmdErr(werr)
+	}
+	return err
+}
+
+func notesIssuesAction(c *cli.Context) error {
+	store, err := openStore(c)
+	if err != nil {
+		return sendCmdErr(err)
+	}
+	tracker, err := openTracker(c)
+	if err != nil {
+		return sendCmdErr(err)
+	}
+	var issues []shippedIssue
+	if c.String("release") != "" {
+		issues, err = releaseIssues(store, c.String("release"), c.String("since"))
+	} else {
+		var sub *Submission
+		sub, err = store.GetSubmission(c.Args().Get(0))
+		if err == nil {
+			issues = []shippedIssue{}
+			for _, key := range submissionIssues(sub) {
+				issues = append(issues, shippedIssue{Key: key, Component: sub.Component, Submission: sub.Name})
+			}
+		}
+	}
+	if err != nil {
+		return sendCmdErr(err)
+	}
+	err = lookupIssues(tracker, issues)
+	if err != nil {
+		return sendCmdErr(err)
+	}
+	return printOutput(c, issues, func() {
+		printIssues(issues)
+	})
+}
+
+// notesCheckArg makes sure notes is given either a SUBMISSION or --release
+func notesCheckArg(c *cli.Context) error {
+	err := notesArgs.check(c)
+	if err != nil {
+		return err
+	}
+	if (c.String("release") != "") == (c.NArg() == 1) {
+		return sendArgErr(c, "incorrect number of arguments")
+	}
 	return nil
 }
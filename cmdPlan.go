@@ -0,0 +1,147 @@
+// file: cmdPlan.go
+// purpose: holo subCMD - plan, apply
+//
+//      - description: converge trains, their components and component
+//                     owners onto a manifest kept in version control
+//                     (see holoManifest.go for the format)
+//
+//        => subCMD 1: plan
+//             - desc: show the operations needed to match a manifest
+//            - usage: holo plan -f/--file MANIFEST
+//          - example: holo plan -f trains.yaml
+//   - example output: + create component jetpack (owner cody@bowerswilkins.com)
+//                     + link jetpack to Thundercat
+//                     - unlink bash from ThundercatDeuce
+//    - output fields: action, train, component, owner, repo, base
+//
+//        => subCMD 2: apply
+//             - desc: carry out the operations needed to match a manifest
+//            - usage: holo apply -m/--file MANIFEST [-f/--force]
+//          - example: holo apply -m trains.yaml
+//                     (-f is --force here, as for every command that asks
+//                     for confirmation, so the manifest is -m)
+//   - example output: This operation will make the following changes:
+//
+//                     - + create component jetpack (owner cody@bowerswilkins.com)
+//                     - + link jetpack to Thundercat
+//                     - - unlink bash from ThundercatDeuce
+//
+//                     Really apply trains.yaml? (y/n)
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/urfave/cli"
+)
+
+// manifestFlag is -f/--file; apply takes -f for --force like every command
+// that asks for confirmation, so its manifest is -m/--file
+var manifestFlag = flagSpec{Name: "file, f", Value: "MANIFEST", Required: true, Usage: "manifest describing trains, components and owners"}
+
+var planArgs = argSchema{
+	Flags: []flagSpec{manifestFlag},
+}
+
+var applyArgs = argSchema{
+	Flags: []flagSpec{
+		{Name: "file, m", Value: manifestFlag.Value, Required: true, Usage: manifestFlag.Usage},
+		forceFlag,
+	},
+}
+
+func setPlanCmd() cli.Command {
+
+	return cli.Command{
+		Name:      "plan",
+		Usage:     "show the changes needed to match a train manifest",
+		ArgsUsage: planArgs.usage(),
+		Flags:     planArgs.flags(),
+		Action:    cmdPlanAction,
+	}
+}
+
+func setApplyCmd() cli.Command {
+
+	return cli.Command{
+		Name:      "apply",
+		Usage:     "make the changes needed to match a train manifest",
+		ArgsUsage: applyArgs.usage(),
+		Flags:     applyArgs.flags(),
+		Action:    audited(cmdApplyAction),
+	}
+}
+
+func cmdPlanAction(c *cli.Context) error {
+	// check input arguments
+	err := planArgs.check(c)
+	if err != nil {
+		return err
+	}
+
+	_, ops, err := planFile(c)
+	if err != nil {
+		return sendCmdErr(err)
+	}
+	return printOutput(c, ops, func() {
+		if len(ops) == 0 {
+			fmt.Println("No changes.")
+		}
+		for _, op := range ops {
+			fmt.Println(op)
+		}
+	})
+}
+
+func cmdApplyAction(c *cli.Context) error {
+	// check input arguments
+	err := applyArgs.check(c)
+	if err != nil {
+		return err
+	}
+
+	store, ops, err := planFile(c)
+	if err != nil {
+		return sendCmdErr(err)
+	}
+	if len(ops) == 0 {
+		fmt.Println("No changes.")
+		return nil
+	}
+	im := &impact{header: "This operation will make the following changes:"}
+	for _, op := range ops {
+		im.items = append(im.items, op.String())
+		auditTargets(c, []string{op.Train}, nil, []string{op.Component})
+	}
+	err = confirm(c, im, "Really apply "+c.String("file")+"?")
+	if err != nil {
+		return sendCmdErr(err)
+	}
+
+	for _, op := range ops {
+		err = applyOp(store, op)
+		if err != nil {
+			return sendCmdErr(fmt.Errorf("%s: %w", op, err))
+		}
+	}
+	fmt.Println("Success!")
+	return nil
+}
+
+// planFile loads the manifest given with --file and plans it
+func planFile(c *cli.Context) (Store, []planOp, error) {
+	m, err := loadManifest(c.String("file"))
+	if err != nil {
+		return nil, nil, err
+	}
+	store, err := openStore(c)
+	if err != nil {
+		return nil, nil, err
+	}
+	ops, err := planManifest(store, m)
+	if err != nil {
+		return nil, nil, err
+	}
+	return store, ops, nil
+}
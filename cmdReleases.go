@@ -3,20 +3,23 @@
 //
 //      - description: manage releases
 //            - usage: holo releases subcommand
-//      - subcommands: ls, show, cut, clone
+//      - subcommands: ls, show, cut, clone, diff, promote
 //
 //        => subCMD 1: ls
 //             - desc: list releases within a build train
-//            - usage: holo releases ls TRAIN
-//          - example: holo releases ls --train Thundercat
-//   - example output: Thundercat1A176
-//                     Thundercat1A175
-//                     Thundercat1A174
-//                     Thundercat1A173
-//                     Thundercat1A172
-//                     Thundercat1A171
-//                     Thundercat1A170
+//            - usage: holo releases ls [TRAIN] [--state STATE]
+//                     (TRAIN defaults to the train setting)
+//          - example: holo releases ls Thundercat
+//   - example output: Thundercat1A176  building
+//                     Thundercat1A175  candidate
+//                     Thundercat1A174  shipped
+//                     Thundercat1A173  withdrawn
+//                     Thundercat1A172  shipped
 //                     ...
+//          - example: holo releases ls Thundercat --state shipped
+//   - example output: Thundercat1A174  shipped
+//                     Thundercat1A172  shipped
+//    - output fields: name, train, created, base, cutBy, state, submissions, reverts
 //
 //        => subCMD 2: show
 //             - desc: describe a given release
@@ -25,39 +28,158 @@
 //   - example output: Release: Thundercat1A176
 //                     Created: 2017-06-02 11:42AM PST
 //                     Base: Thundercat1A175
+//                     Cut by: steve@bowerswilkins.com
+//                     State: shipped
 //                     Submissions:
 //                     - bash-3
 //                     - jetpack-98
 //                     - osd-38
 //                     - zlib-1
 //                     - ...
+//                     Reverts:
+//                     - osd-38 (reverts osd-39, by steve@bowerswilkins.com: crashes on boot)
+//    - output fields: name, train, created, base, cutBy, state, submissions,
+//                     reverts (submission, reverted, train, created,
+//                     revertedBy, reason)
 //
 //        => subCMD 3: cut
 //             - desc: cut a new release by flushing pending submissions,
 //                     diffed against the release specified by --base
+//                     (default: the latest release); the train is locked
+//                     while cutting, and the cut fails if the pending
+//                     submissions changed after confirming;
+//                     release names are TRAIN MAJOR LETTER BUILD and must be
+//                     newer than every release of the train; --next picks
+//                     the next build (or milestone with --bump)
 //            - usage: holo releases cut RELEASE [--base RELEASE] [-f/--force]
+//                     holo releases cut --next [--train TRAIN]
+//                       [--bump major|letter] [--base RELEASE] [-f/--force]
 //          - example: holo releases cut Thundercat1A177 --base Thundercat1A176
+//                     holo releases cut --next --train Thundercat --bump letter
 //   - example output: This operation will bring in the following submissions:
 //                     - jetpack-99
-//                     - osd-39
+//                     - osd-37 (reverts osd-39, by steve@bowerswilkins.com: crashes on boot)
 //                     Really cut release Thundercat1A177? (y/n)
 //
 //        => subCMD 4: clone
-//             - desc: create a new release based on an existing release
-//                     (typically only useful after cloning a train)
+//             - desc: create a new release with the same submissions as an
+//                     existing release (typically only useful after cloning
+//                     a train); --base defaults to the release of the same
+//                     build in the base train, e.g. Thundercat1A177 for
+//                     ThundercatDeuce1A177; --artifacts copies the artifacts
+//                     of the base release, or only references their data
 //            - usage: holo releases clone RELEASE [--base RELEASE]
-//          - example: holo releases clone ThundercatDeuce1A177 --base Thundercat1A177
-//   - example output: Success!
+//                       [--artifacts copy|ref] [-f/--force]
+//          - example: holo releases clone ThundercatDeuce1A177 --base Thundercat1A177 --artifacts ref
+//   - example output: This operation will copy the following from Thundercat1A177:
+//
+//                     - bash-3
+//                     - osd-39
+//                     - Thundercat1A177_SDKRoot.txz => ThundercatDeuce1A177_SDKRoot.txz (ref)
+//
+//                     Really clone Thundercat1A177 into ThundercatDeuce1A177? (y/n)
+//
+//        => subCMD 5: diff
+//             - desc: compare two releases
+//            - usage: holo releases diff RELEASE_A RELEASE_B
+//          - example: holo releases diff Thundercat1A175 Thundercat1A177
+//   - example output: Thundercat1A175 -> Thundercat1A177
+//
+//                     Components:
+//                     + bash-3
+//                     - zlib-1
+//                     ~ osd-37 -> osd-39
+//                         osd-38 (steve@bowerswilkins.com): osd-38 fixes a couple crashers:
+//                         osd-39 (steve@bowerswilkins.com): osd-39 adds tiling
+//
+//                     Artifacts:
+//                     + Cake1,1_DVT2_1.0_Thundercat1A177_USBImage.img
+//    - output fields: from, to,
+//                     components (component, change, from, to, submissions),
+//                     artifacts (added, removed)
+//
+//        => subCMD 6: promote
+//             - desc: move a release to the next state of its lifecycle:
+//                     cut, building, candidate (needs artifacts), qualified,
+//                     shipped; any release may be withdrawn
+//            - usage: holo releases promote RELEASE --to STATE [-f/--force]
+//          - example: holo releases promote Thundercat1A177 --to qualified
+//   - example output: This operation will change the state of Thundercat1A177:
+//
+//                     - candidate -> qualified
+//
+//                     Really promote Thundercat1A177 to qualified? (y/n) y
+//                     Success!
 //
 
 package main
 
 import (
+	"errors"
 	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
 
 	"github.com/urfave/cli"
 )
 
+var lsReleasesArgs = argSchema{
+	Args: []argSpec{
+		{Name: "TRAIN", Kind: "train", Setting: "train"},
+	},
+	Flags: []flagSpec{
+		{Name: "state", Value: "STATE", Choices: releaseStates, Usage: "only the releases in a state"},
+	},
+}
+
+var showReleasesArgs = argSchema{
+	Args: []argSpec{
+		{Name: "RELEASE", Kind: "release"},
+	},
+}
+
+var cutReleasesArgs = argSchema{
+	Args: []argSpec{
+		{Name: "RELEASE", Kind: "release", Optional: true},
+	},
+	Flags: []flagSpec{
+		{Name: "next", Usage: "name the release after the newest release of the train"},
+		{Name: "train", Value: "TRAIN", Kind: "train", Usage: "train to cut with --next (defaults to the train setting)"},
+		{Name: "bump", Value: "BUMP", Choices: []string{"major", "letter"}, Usage: "with --next, start the next milestone: major or letter"},
+		{Name: "base", Value: "RELEASE", Kind: "release", Usage: "base release"},
+		forceFlag,
+	},
+}
+
+var cloneReleasesArgs = argSchema{
+	Args: []argSpec{
+		{Name: "RELEASE", Kind: "release"},
+	},
+	Flags: []flagSpec{
+		{Name: "base", Value: "RELEASE", Kind: "release", Usage: "base release"},
+		{Name: "artifacts", Value: "MODE", Choices: []string{"copy", "ref"}, Usage: "copy the artifacts of the base release (copy) or refer to them (ref)"},
+		forceFlag,
+	},
+}
+
+var promoteReleasesArgs = argSchema{
+	Args: []argSpec{
+		{Name: "RELEASE", Kind: "release"},
+	},
+	Flags: []flagSpec{
+		{Name: "to", Value: "STATE", Choices: promoteStates, Required: true, Usage: "the state to move the release to"},
+		forceFlag,
+	},
+}
+
+var diffReleasesArgs = argSchema{
+	Args: []argSpec{
+		{Name: "RELEASE_A", Kind: "release"},
+		{Name: "RELEASE_B", Kind: "release"},
+	},
+}
+
 func setReleasesCmd() cli.Command {
 
 	return cli.Command{
@@ -66,43 +188,43 @@ func setReleasesCmd() cli.Command {
 		Subcommands: []cli.Command{
 			{
 				Name:      "ls",
-				ArgsUsage: "TRAIN",
+				ArgsUsage: lsReleasesArgs.usage(),
 				Usage:     "list releases within a build train",
+				Flags:     lsReleasesArgs.flags(),
 				Action:    lsReleasesAction,
 			},
 			{
 				Name:      "show",
-				ArgsUsage: "RELEASE",
+				ArgsUsage: showReleasesArgs.usage(),
 				Usage:     "describe a given release",
 				Action:    showReleasesAction,
 			},
 			{
 				Name:      "cut",
-				ArgsUsage: "RELEASE [--base RELEASE] [-f/--force]",
+				ArgsUsage: cutReleasesArgs.usage(),
 				Usage:     "cut a new release by flushing pending submissions",
-				Flags: []cli.Flag{
-					cli.StringFlag{
-						Name:  "base",
-						Usage: "base release",
-					},
-					cli.BoolFlag{
-						Name:  "force, f",
-						Usage: "suppress prompts",
-					},
-				},
-				Action: cutReleasesAction,
+				Flags:     cutReleasesArgs.flags(),
+				Action:    audited(cutReleasesAction),
 			},
 			{
 				Name:      "clone",
-				ArgsUsage: "RELEASE [--base RELEASE]",
+				ArgsUsage: cloneReleasesArgs.usage(),
 				Usage:     "create a new release based on an existing release",
-				Flags: []cli.Flag{
-					cli.StringFlag{
-						Name:  "base",
-						Usage: "base release",
-					},
-				},
-				Action: cloneReleasesAction,
+				Flags:     cloneReleasesArgs.flags(),
+				Action:    audited(cloneReleasesAction),
+			},
+			{
+				Name:      "diff",
+				ArgsUsage: diffReleasesArgs.usage(),
+				Usage:     "compare two releases",
+				Action:    diffReleasesAction,
+			},
+			{
+				Name:      "promote",
+				ArgsUsage: promoteReleasesArgs.usage(),
+				Usage:     "move a release to another state of its lifecycle",
+				Flags:     promoteReleasesArgs.flags(),
+				Action:    audited(promoteReleasesAction),
 			},
 		},
 	}
@@ -111,29 +233,71 @@ func setReleasesCmd() cli.Command {
 // Subcommand: ls
 func lsReleasesAction(c *cli.Context) error {
 	// check arguments
-	err := checkNumArg(c, 1)
+	err := lsReleasesArgs.check(c)
 	if err != nil {
 		return err
 	}
+	train := trainArg(c)
 
-	fmt.Println("In the function of release ls:")
-	fmt.Println("### cmd: holo releases ls TRAIN ###")
-	fmt.Println("TRAIN: ", c.Args().Get(0))
-	return nil
+	store, err := openStore(c)
+	if err != nil {
+		return sendCmdErr(err)
+	}
+	releases, err := store.ListReleases(train)
+	if err != nil {
+		return sendCmdErr(err)
+	}
+	if c.String("state") != "" {
+		matching := []Release{}
+		for _, r := range releases {
+			if releaseState(&r) == c.String("state") {
+				matching = append(matching, r)
+			}
+		}
+		releases = matching
+	}
+	return printOutput(c, releases, func() {
+		w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+		for _, r := range releases {
+			fmt.Fprintf(w, "%s\t%s\n", r.Name, releaseState(&r))
+		}
+		w.Flush()
+	})
 }
 
 // Subcommand: show
 func showReleasesAction(c *cli.Context) error {
 	// check arguments
-	err := checkNumArg(c, 1)
+	err := showReleasesArgs.check(c)
 	if err != nil {
 		return err
 	}
 
-	fmt.Println("In the function of release show:")
-	fmt.Println("### cmd: holo releases show RELEASE ###")
-	fmt.Println("RELEASE: ", c.Args().Get(0))
-	return nil
+	store, err := openStore(c)
+	if err != nil {
+		return sendCmdErr(err)
+	}
+	r, err := store.GetRelease(c.Args().Get(0))
+	if err != nil {
+		return sendCmdErr(err)
+	}
+	return printOutput(c, r, func() {
+		fmt.Println("Release:", r.Name)
+		fmt.Println("Created:", r.Created.Format(timeLayout))
+		fmt.Println("Base:", r.Base)
+		if r.CutBy != "" {
+			fmt.Println("Cut by:", r.CutBy)
+		}
+		fmt.Println("State:", releaseState(r))
+		printList("Submissions", r.Submissions)
+		if len(r.Reverts) > 0 {
+			reverts := []string{}
+			for _, rv := range r.Reverts {
+				reverts = append(reverts, rv.Submission+" ("+describeRevert(rv)+")")
+			}
+			printList("Reverts", reverts)
+		}
+	})
 }
 
 // Subcommand: cut
@@ -144,50 +308,332 @@ func cutReleasesAction(c *cli.Context) error {
 		return err
 	}
 
-	fmt.Println("In the function of release cut:")
-	fmt.Println("### cmd: holo releases cut RELEASE [--base RELEASE] [-f/--force] ###")
-	fmt.Println("RELEASE: ", c.Args().Get(0))
-	fmt.Println("base-RELEASE: ", c.String("base"))
-	fmt.Println("force:", c.Bool("force"))
+	store, err := openStore(c)
+	if err != nil {
+		return sendCmdErr(err)
+	}
+	var t *Train
+	name := c.Args().Get(0)
+	auditTargets(c, nil, []string{name}, nil)
+	if c.Bool("next") {
+		t, err = store.GetTrain(flagOrSetting(c, "train", "train"))
+		if err != nil {
+			return sendCmdErr(err)
+		}
+		name, err = nextReleaseName(store, t, c.String("bump"))
+	} else {
+		t, err = trainOfRelease(store, name)
+	}
+	if err != nil {
+		return sendCmdErr(err)
+	}
+	_, err = store.GetRelease(name)
+	if err == nil {
+		return sendCmdErr(fmt.Errorf("release %s %w", name, errExists))
+	}
+	if !errors.Is(err, errNotFound) {
+		return sendCmdErr(err)
+	}
+	err = checkNewerRelease(store, t.Name, name)
+	if err != nil {
+		return sendCmdErr(err)
+	}
+
+	r := &Release{Name: name, Train: t.Name, Base: c.String("base"), CutBy: actor(c)}
+	if r.Base == "" {
+		r.Base = t.LatestRelease
+	}
+	auditTargets(c, []string{t.Name}, []string{r.Name, r.Base}, nil)
+	baseSubmissions := []string{}
+	if r.Base != "" {
+		base, err := store.GetRelease(r.Base)
+		if err != nil {
+			return sendCmdErr(err)
+		}
+		baseSubmissions = base.Submissions
+	}
+	r.Submissions = trainSnapshot(t, baseSubmissions)
+
+	err = confirm(c, cutImpact(r, baseSubmissions, t.Reverts), "Really cut release "+r.Name+"?")
+	if err != nil {
+		return sendCmdErr(err)
+	}
+	err = store.CutRelease(r)
+	if err != nil {
+		return sendCmdErr(err)
+	}
+	if c.Bool("next") {
+		fmt.Println("Release:", r.Name)
+	}
+	fmt.Println("Success!")
+	return nil
+}
+
+// Subcommand: promote
+func promoteReleasesAction(c *cli.Context) error {
+	// check arguments
+	err := promoteReleasesArgs.check(c)
+	if err != nil {
+		return err
+	}
+
+	store, err := openStore(c)
+	if err != nil {
+		return sendCmdErr(err)
+	}
+	name, to := c.Args().Get(0), c.String("to")
+	auditRelease(c, store, name)
+	r, err := store.GetRelease(name)
+	if err != nil {
+		return sendCmdErr(err)
+	}
+	// checked here too so that a refused transition fails before the prompt
+	err = checkTransition(r, to)
+	if err != nil {
+		return sendCmdErr(err)
+	}
+	if to == "candidate" {
+		artifacts, err := store.ListArtifacts(r.Name)
+		if err != nil {
+			return sendCmdErr(err)
+		}
+		err = checkCandidate(r.Name, len(artifacts))
+		if err != nil {
+			return sendCmdErr(err)
+		}
+	}
+	im := &impact{header: "This operation will change the state of " + r.Name + ":"}
+	im.items = append(im.items, releaseState(r)+" -> "+to)
+	err = confirm(c, im, "Really promote "+r.Name+" to "+to+"?")
+	if err != nil {
+		return sendCmdErr(err)
+	}
+	_, err = store.PromoteRelease(r.Name, to)
+	if err != nil {
+		return sendCmdErr(err)
+	}
+	fmt.Println("Success!")
 	return nil
 }
 
 // Subcommand: clone
 func cloneReleasesAction(c *cli.Context) error {
 	// check arguments
-	err := cloneReleasesCheckArg(c)
+	err := cloneReleasesArgs.check(c)
 	if err != nil {
 		return err
 	}
 
-	fmt.Println("In the function of release clone:")
-	fmt.Println("### cmd: holo releases clone RELEASE [--base RELEASE] ###")
-	fmt.Println("RELEASE: ", c.Args().Get(0))
-	fmt.Println("base-RELEASE: ", c.String("base"))
+	store, err := openStore(c)
+	if err != nil {
+		return sendCmdErr(err)
+	}
+	name := c.Args().Get(0)
+	auditTargets(c, nil, []string{name, c.String("base")}, nil)
+	t, err := trainOfRelease(store, name)
+	if err != nil {
+		return sendCmdErr(err)
+	}
+	auditTargets(c, []string{t.Name}, nil, nil)
+
+	_, err = parseReleaseName(t.Name, name)
+	if err != nil {
+		return sendCmdErr(err)
+	}
+	_, err = store.GetRelease(name)
+	if err == nil {
+		return sendCmdErr(fmt.Errorf("release %s %w", name, errExists))
+	}
+	if !errors.Is(err, errNotFound) {
+		return sendCmdErr(err)
+	}
+
+	r := &Release{Name: name, Train: t.Name, Base: c.String("base"), CutBy: actor(c)}
+	if r.Base == "" {
+		r.Base = cloneBase(store, t, name)
+	}
+	if r.Base == "" {
+		return sendCmdErr(errors.New("train " + t.Name + " has no release to clone, use --base"))
+	}
+	base, err := store.GetRelease(r.Base)
+	if err != nil {
+		return sendCmdErr(err)
+	}
+	r.Submissions = base.Submissions
+
+	artifacts := []Artifact{}
+	if c.String("artifacts") != "" {
+		artifacts, err = store.ListArtifacts(base.Name)
+		if err != nil {
+			return sendCmdErr(err)
+		}
+	}
+	im := &impact{header: "This operation will copy the following from " + base.Name + ":"}
+	im.items = append(im.items, r.Submissions...)
+	for _, a := range artifacts {
+		im.items = append(im.items, a.Name+" => "+clonedArtifactName(a.Name, base.Name, r.Name)+" ("+c.String("artifacts")+")")
+	}
+	err = confirm(c, im, "Really clone "+base.Name+" into "+r.Name+"?")
+	if err != nil {
+		return sendCmdErr(err)
+	}
+
+	err = store.CloneRelease(r)
+	if err != nil {
+		return sendCmdErr(err)
+	}
+	err = cloneArtifacts(c, store, r, base, artifacts)
+	if err != nil {
+		// leave no half cloned release behind
+		derr := store.DeleteRelease(r.Name)
+		if derr != nil {
+			fmt.Fprintln(os.Stderr, "Warning: could not remove "+r.Name+": "+derr.Error())
+		}
+		return sendCmdErr(err)
+	}
+	fmt.Println("Success!")
 	return nil
 }
 
-// check input arguments:
-func cutReleasesCheckArg(c *cli.Context) error {
-	err := checkNumArg(c, 1)
+// cloneArtifacts copies or refers to the artifacts of base from r, as the
+// artifacts flag asks
+func cloneArtifacts(c *cli.Context, store Store, r, base *Release, artifacts []Artifact) error {
+	for i := range artifacts {
+		a := &artifacts[i]
+		clone := &Artifact{Name: clonedArtifactName(a.Name, base.Name, r.Name), Release: r.Name}
+		var err error
+		if c.String("artifacts") == "ref" {
+			clone.Source = a.Release + "/" + a.Name
+			err = store.LinkArtifact(clone)
+		} else {
+			fmt.Println("Copying", a.Name+"...")
+			err = copyArtifact(c, store, a, clone)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// cloneBase picks the release a clone is based on: the release of the same
+// build in the base train if there is one, or else the latest release
+func cloneBase(store Store, t *Train, name string) string {
+	if t.Base != "" {
+		same := t.Base + strings.TrimPrefix(name, t.Name)
+		_, err := store.GetRelease(same)
+		if err == nil {
+			return same
+		}
+	}
+	return t.LatestRelease
+}
+
+// clonedArtifactName renames an artifact of one release for another, e.g.
+// Thundercat1A177_SDKRoot.txz => ThundercatDeuce1A177_SDKRoot.txz
+func clonedArtifactName(name, from, to string) string {
+	return strings.Replace(name, from, to, 1)
+}
+
+// Subcommand: diff
+func diffReleasesAction(c *cli.Context) error {
+	// check arguments
+	err := diffReleasesArgs.check(c)
 	if err != nil {
 		return err
 	}
-	err = checkOptionalFlagVal(c, "base")
+
+	store, err := openStore(c)
 	if err != nil {
-		return err
+		return sendCmdErr(err)
 	}
-	return nil
+	d, err := diffReleases(store, c.Args().Get(0), c.Args().Get(1))
+	if err != nil {
+		return sendCmdErr(err)
+	}
+	return printOutput(c, d, func() {
+		fmt.Println(d.From, "->", d.To)
+		fmt.Println()
+		fmt.Println("Components:")
+		for _, ch := range d.Components {
+			switch ch.Change {
+			case "added":
+				fmt.Println("+", ch.To)
+			case "removed":
+				fmt.Println("-", ch.From)
+			default:
+				fmt.Println("~", ch.From, "->", ch.To)
+			}
+			for _, sub := range ch.Submissions {
+				fmt.Printf("    %s (%s): %s\n", sub.Name, sub.Submitter, sub.Notes.Summary)
+			}
+		}
+		fmt.Println()
+		fmt.Println("Artifacts:")
+		for _, name := range d.Artifacts.Added {
+			fmt.Println("+", name)
+		}
+		for _, name := range d.Artifacts.Removed {
+			fmt.Println("-", name)
+		}
+	})
 }
 
-func cloneReleasesCheckArg(c *cli.Context) error {
-	err := checkNumArg(c, 1)
+// trainOfRelease finds the train a release name belongs to, e.g.
+// ThundercatDeuce1A177 belongs to ThundercatDeuce rather than Thundercat
+func trainOfRelease(store Store, release string) (*Train, error) {
+	trains, err := store.ListTrains()
+	if err != nil {
+		return nil, err
+	}
+	var found *Train
+	for i, t := range trains {
+		if strings.HasPrefix(release, t.Name) && (found == nil || len(t.Name) > len(found.Name)) {
+			found = &trains[i]
+		}
+	}
+	if found == nil {
+		return nil, errors.New("release " + release + " does not belong to any known train")
+	}
+	return found, nil
+}
+
+// checkNewerRelease makes sure a release name is well formed and newer than
+// every release of its train
+func checkNewerRelease(store Store, train, name string) error {
+	rn, err := parseReleaseName(train, name)
+	if err != nil {
+		return err
+	}
+	releases, err := store.ListReleases(train)
 	if err != nil {
 		return err
 	}
-	err = checkOptionalFlagVal(c, "base")
+	if len(releases) == 0 {
+		return nil
+	}
+	latest, err := parseReleaseName(train, releases[0].Name)
+	if err == nil && rn.compare(latest) <= 0 {
+		return errors.New("release " + name + " is not newer than " + latest.String())
+	}
+	return nil
+}
+
+// check input arguments:
+func cutReleasesCheckArg(c *cli.Context) error {
+	err := cutReleasesArgs.check(c)
 	if err != nil {
 		return err
 	}
+	switch {
+	case c.Bool("next") && c.NArg() > 0:
+		return sendArgErr(c, "RELEASE and --next cannot be combined")
+	case !c.Bool("next") && c.NArg() == 0:
+		return sendArgErr(c, "RELEASE or --next is required")
+	case !c.Bool("next") && c.String("bump") != "":
+		return sendArgErr(c, "--bump requires --next")
+	case c.Bool("next") && flagOrSetting(c, "train", "train") == "":
+		return sendArgErr(c, "--train flag is required")
+	}
 	return nil
 }
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestCloneRelease(t *testing.T) {
+	const (
+		image = "Cake1,1_DVT2_1.0_Thundercat1A1_USBImage.img"
+		sdk   = "Thundercat1A1_SDKRoot.txz"
+	)
+	tests := []struct {
+		desc      string
+		args      []string
+		corrupt   bool // the data of the base artifact is damaged
+		ok        bool
+		artifacts []Artifact
+	}{
+		{"no artifacts", nil, false, true, nil},
+		{"copy", []string{"--artifacts", "copy"}, false, true, []Artifact{
+			{Name: "Avalanche1A1_SDKRoot.txz"},
+			{Name: "Cake1,1_DVT2_1.0_Avalanche1A1_USBImage.img"},
+		}},
+		{"ref", []string{"--artifacts", "ref"}, false, true, []Artifact{
+			{Name: "Avalanche1A1_SDKRoot.txz", Source: "Thundercat1A1/" + sdk},
+			{Name: "Cake1,1_DVT2_1.0_Avalanche1A1_USBImage.img", Source: "Thundercat1A1/" + image},
+		}},
+		// the image is copied before the SDK fails
+		{"failed copy", []string{"--artifacts", "copy"}, true, false, nil},
+	}
+	for _, tt := range tests {
+		store := newTestStore(t)
+		addTrain(t, store, "Thundercat", "osd")
+		addTrain(t, store, "Avalanche", "osd")
+		addSubmission(t, store, "osd-1", Notes{}, "Thundercat", "Avalanche")
+		must(t, store.CloneRelease(&Release{Name: "Thundercat1A1", Train: "Thundercat", Submissions: []string{"osd-1"}}))
+		addArtifact(t, store, "Thundercat1A1", image, "image")
+		addArtifact(t, store, "Thundercat1A1", sdk, "sdk")
+		if tt.corrupt {
+			must(t, ioutil.WriteFile(filepath.Join(store.artifactDir("Thundercat1A1", "data"), sdk), []byte("bad"), 0644))
+		}
+
+		args := append([]string{"releases", "clone", "Avalanche1A1", "--base", "Thundercat1A1", "-f"}, tt.args...)
+		err := runHolo(t, store, args...)
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: clone error = %v, want ok %v", tt.desc, err, tt.ok)
+			continue
+		}
+		if !tt.ok {
+			// nothing half cloned is left behind
+			if _, err := store.GetRelease("Avalanche1A1"); !errors.Is(err, errNotFound) {
+				t.Errorf("%s: Avalanche1A1 is left behind: %v", tt.desc, err)
+			}
+			if _, err := os.Stat(store.artifactDir("Avalanche1A1", "")); !os.IsNotExist(err) {
+				t.Errorf("%s: artifacts of Avalanche1A1 are left behind", tt.desc)
+			}
+			tr, err := store.GetTrain("Avalanche")
+			must(t, err)
+			if tr.LatestRelease != "" {
+				t.Errorf("%s: the latest release of Avalanche is %s", tt.desc, tr.LatestRelease)
+			}
+			continue
+		}
+		r, err := store.GetRelease("Avalanche1A1")
+		must(t, err)
+		if !reflect.DeepEqual(r.Submissions, []string{"osd-1"}) || r.Base != "Thundercat1A1" {
+			t.Errorf("%s: Avalanche1A1 = %+v, want osd-1 based on Thundercat1A1", tt.desc, r)
+		}
+		artifacts, err := store.ListArtifacts("Avalanche1A1")
+		must(t, err)
+		if len(artifacts) != len(tt.artifacts) {
+			t.Errorf("%s: Avalanche1A1 has %d artifacts, want %d", tt.desc, len(artifacts), len(tt.artifacts))
+			continue
+		}
+		for i, a := range artifacts {
+			if a.Name != tt.artifacts[i].Name || a.Source != tt.artifacts[i].Source {
+				t.Errorf("%s: artifact %s from %q, want %s from %q", tt.desc, a.Name, a.Source, tt.artifacts[i].Name, tt.artifacts[i].Source)
+			}
+		}
+	}
+}
+
+func TestCloneBase(t *testing.T) {
+	store := newTestStore(t)
+	addTrain(t, store, "Thundercat")
+	must(t, store.CreateTrain(&Train{Name: "ThundercatDeuce", Base: "Thundercat"}))
+	for _, name := range []string{"Thundercat1A1", "Thundercat1A2"} {
+		must(t, store.CloneRelease(&Release{Name: name, Train: "Thundercat"}))
+	}
+	deuce, err := store.GetTrain("ThundercatDeuce")
+	must(t, err)
+	deuce.LatestRelease = "Thundercat1A2"
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"ThundercatDeuce1A1", "Thundercat1A1"},
+		{"ThundercatDeuce1A2", "Thundercat1A2"},
+		{"ThundercatDeuce1A3", "Thundercat1A2"},
+	}
+	for _, tt := range tests {
+		if got := cloneBase(store, deuce, tt.name); got != tt.want {
+			t.Errorf("cloneBase(%s) = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCreateTrainBase(t *testing.T) {
+	store := newTestStore(t)
+	addTrain(t, store, "Thundercat", "osd", "bash")
+	must(t, store.CloneRelease(&Release{Name: "Thundercat1A1", Train: "Thundercat"}))
+
+	must(t, runHolo(t, store, "trains", "create", "ThundercatDeuce", "--base", "Thundercat", "-f"))
+	deuce, err := store.GetTrain("ThundercatDeuce")
+	must(t, err)
+	if deuce.Base != "Thundercat" || deuce.LatestRelease != "Thundercat1A1" || !reflect.DeepEqual(deuce.Components, []string{"bash", "osd"}) {
+		t.Errorf("ThundercatDeuce = %+v, want the components and latest release of Thundercat", deuce)
+	}
+	comp, err := store.GetComponent("osd")
+	must(t, err)
+	if !reflect.DeepEqual(comp.Trains, []string{"Thundercat", "ThundercatDeuce"}) {
+		t.Errorf("osd is a member of %q, want both trains", comp.Trains)
+	}
+}
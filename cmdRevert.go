@@ -1,56 +1,130 @@
 // file: cmdRevert.go
 // purpose: holo subCMD - revert
 //
-//      - description: roll a submission back
-//            - usage: holo revert SUBMISSION [--train TRAIN1,TRAIN2,TRAIN3...] [-f/--force]
-//          - example: holo revert osd-36
+//      - description: roll a component back to an older submission on every
+//                     train that carries a later version of it (or only on
+//                     the trains given with --train); the revert stays
+//                     pending until the next release is cut, which records
+//                     who reverted what and why
+//            - usage: holo revert SUBMISSION [--train TRAIN1,TRAIN2,TRAIN3...] [--reason REASON] [-f/--force]
+//          - example: holo revert osd-36 --reason "osd-37 breaks pairing"
 //                     holo revert --train ThundercatDeuce osd-36
-//   - example output: This operation will affect the following build trains:
+//   - example output: This operation will revert the following build trains:
 //
-//                     - Avalanche: osd-37
-//                     - Thundercat: osd-37
+//                     - Avalanche: osd-37 -> osd-36
+//                     - Thundercat: osd-37 -> osd-36
 //
 //                     Really revert to osd-36? (y/n)
 
 package main
 
 import (
+	"errors"
 	"fmt"
 
 	"github.com/urfave/cli"
 )
 
+var revertArgs = argSchema{
+	Args: []argSpec{
+		{Name: "SUBMISSION", Kind: "submission"},
+	},
+	Flags: []flagSpec{
+		{Name: "train", Value: "TRAIN", Kind: "train", List: true, Usage: "only revert the given train(s)"},
+		{Name: "reason", Value: "REASON", Kind: "text", Usage: "why the later versions are backed out"},
+		forceFlag,
+	},
+}
+
 func setRevertCmd() cli.Command {
 
 	return cli.Command{
 		Name:      "revert",
 		Usage:     "roll a submission back",
-		ArgsUsage: "SUBMISSION [--train TRAIN1,TRAIN2,TRAIN3...] [-f/--force]",
-		Flags: []cli.Flag{
-			cli.StringFlag{
-				Name:  "train",
-				Usage: "remove component from the given train(s)",
-			},
-			cli.BoolFlag{
-				Name:  "force, f",
-				Usage: "suppress prompts",
-			},
-		},
-		Action: cmdRevertAction,
+		ArgsUsage: revertArgs.usage(),
+		Flags:     revertArgs.flags(),
+		Action:    audited(cmdRevertAction),
 	}
 }
 
 func cmdRevertAction(c *cli.Context) error {
 	// check input arguments
-	err := checkNumArg(c, 1)
+	err := revertArgs.check(c)
 	if err != nil {
 		return err
 	}
 
-	fmt.Println("In the function of revert:")
-	fmt.Println("### cmd: holo revert SUBMISSION [--train TRAIN1,TRAIN2,TRAIN3...] [-f/--force] ###")
-	fmt.Println("SUBMISSION: ", c.Args().Get(0))
-	fmt.Println("train list: ", c.String("train"))
-	fmt.Println("force:", c.Bool("force"))
+	store, err := openStore(c)
+	if err != nil {
+		return sendCmdErr(err)
+	}
+	auditTargets(c, splitList(c.String("train")), nil, nil)
+	sub, err := store.GetSubmission(c.Args().Get(0))
+	if err != nil {
+		return sendCmdErr(err)
+	}
+	auditTargets(c, nil, nil, []string{sub.Component})
+	reverts, err := revertsOf(store, sub, c.String("train"))
+	if err != nil {
+		return sendCmdErr(err)
+	}
+	for _, rv := range reverts {
+		auditTargets(c, []string{rv.Train}, nil, nil)
+	}
+
+	im := &impact{header: "This operation will revert the following build trains:"}
+	for _, rv := range reverts {
+		im.items = append(im.items, rv.Train+": "+rv.Reverted+" -> "+rv.Submission)
+	}
+	err = confirm(c, im, "Really revert to "+sub.Name+"?")
+	if err != nil {
+		return sendCmdErr(err)
+	}
+
+	for _, rv := range reverts {
+		rv.Reason = c.String("reason")
+		rv.RevertedBy = actor(c)
+	}
+	err = store.RevertSubmission(reverts)
+	if err != nil {
+		return sendCmdErr(err)
+	}
+	fmt.Println("Done!")
 	return nil
 }
+
+// revertsOf finds the trains that carry a later version of the component
+// of sub than sub itself, limited to trainList when given
+func revertsOf(store Store, sub *Submission, trainList string) ([]*Revert, error) {
+	comp, err := store.GetComponent(sub.Component)
+	if err != nil {
+		return nil, err
+	}
+	trains := comp.Trains
+	if trainList != "" {
+		trains = splitList(trainList)
+	}
+
+	reverts := []*Revert{}
+	for _, name := range trains {
+		t, err := store.GetTrain(name)
+		if err != nil {
+			return nil, err
+		}
+		carried, err := carriedSubmission(store, t, sub.Component)
+		if err != nil {
+			return nil, err
+		}
+		if carried == "" || compareSubmissions(carried, sub.Name) <= 0 {
+			if trainList != "" {
+				return nil, errors.New("train " + t.Name + " does not carry a version of " + sub.Component + " later than " + sub.Name)
+			}
+			continue
+		}
+		reverts = append(reverts, &Revert{Submission: sub.Name, Reverted: carried, Train: t.Name})
+	}
+	if len(reverts) == 0 {
+		return nil, errors.New("no train carries a version of " + sub.Component + " later than " + sub.Name)
+	}
+	return reverts, nil
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRevert(t *testing.T) {
+	store := newTestStore(t)
+	addTrain(t, store, "T", "osd")
+	addTrain(t, store, "Other", "osd")
+	addTrain(t, store, "Third", "osd")
+	addSubmission(t, store, "osd-1", Notes{}, "T", "Other", "Third")
+	addSubmission(t, store, "osd-2", Notes{}, "T", "Other")
+	must(t, store.CloneRelease(&Release{Name: "T1A1", Train: "T", Submissions: []string{"osd-2"}}))
+	must(t, store.CloneRelease(&Release{Name: "Third1A1", Train: "Third", Submissions: []string{"osd-1"}}))
+	must(t, store.QueueSubmission("osd-2", []string{"Other"}))
+	sub, err := store.GetSubmission("osd-1")
+	must(t, err)
+
+	reverts, err := revertsOf(store, sub, "")
+	must(t, err)
+	want := []*Revert{
+		{Submission: "osd-1", Reverted: "osd-2", Train: "Other"},
+		{Submission: "osd-1", Reverted: "osd-2", Train: "T"},
+	}
+	if !reflect.DeepEqual(reverts, want) {
+		t.Fatalf("revertsOf = %+v, want %+v", reverts, want)
+	}
+	if _, err := revertsOf(store, sub, "Third"); err == nil {
+		t.Error("revertsOf a train that carries osd-1 already succeeded")
+	}
+
+	// all trains or none of them
+	err = store.RevertSubmission([]*Revert{
+		{Submission: "osd-1", Reverted: "osd-2", Train: "T"},
+		{Submission: "osd-1", Reverted: "osd-2", Train: "Gone"},
+	})
+	if err == nil {
+		t.Error("reverting a train that does not exist succeeded")
+	}
+	err = store.RevertSubmission([]*Revert{
+		{Submission: "osd-1", Reverted: "osd-2", Train: "T"},
+		{Submission: "osd-1", Reverted: "osd-2", Train: "T"},
+	})
+	if err == nil {
+		t.Error("reverting a train twice succeeded")
+	}
+	tr, err := store.GetTrain("T")
+	must(t, err)
+	if len(tr.Pending) != 0 || len(tr.Reverts) != 0 {
+		t.Fatalf("a failed revert changed train T: pending %q, reverts %+v", tr.Pending, tr.Reverts)
+	}
+
+	for _, rv := range reverts {
+		rv.Reason = "osd-2 breaks pairing"
+	}
+	must(t, store.RevertSubmission(reverts))
+	for _, train := range []string{"T", "Other"} {
+		tr, err := store.GetTrain(train)
+		must(t, err)
+		if !reflect.DeepEqual(tr.Pending, []string{"osd-1"}) || len(tr.Reverts) != 1 || tr.Reverts[0].Reason != "osd-2 breaks pairing" {
+			t.Errorf("train %s has pending %q and reverts %+v, want osd-1 and its revert", train, tr.Pending, tr.Reverts)
+		}
+	}
+
+	// the next cut carries the revert
+	r := &Release{Name: "T1A2", Train: "T"}
+	must(t, store.CutRelease(r))
+	if !reflect.DeepEqual(r.Submissions, []string{"osd-1"}) || len(r.Reverts) != 1 || r.Reverts[0].Reverted != "osd-2" {
+		t.Errorf("T1A2 carries %q and reverts %+v, want osd-1 reverting osd-2", r.Submissions, r.Reverts)
+	}
+	tr, err = store.GetTrain("T")
+	must(t, err)
+	if len(tr.Reverts) != 0 {
+		t.Errorf("train T still has reverts %+v pending after the cut", tr.Reverts)
+	}
+}
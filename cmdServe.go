@@ -0,0 +1,83 @@
+// file: cmdServe.go
+// purpose: holo subCMD - serve
+//
+//      - description: run a reference build service backed by the local store
+//            - usage: holo serve [--addr ADDR] [--users FILE]
+//          - example: holo serve --addr localhost:8080
+//                     holo --server http://localhost:8080 login steve@bowerswilkins.com
+//   - example output: Serving holo API on http://localhost:8080 (store: /home/steve/.holo)
+//
+//                     only the users in --users (default: STORE/users) can
+//                     log in, and every request but login needs the token
+//                     of a login; the file has one "EMAIL SHA256" line per
+//                     user, e.g. made with printf %s PASSWORD | sha256sum
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/urfave/cli"
+)
+
+var serveArgs = argSchema{
+	Flags: []flagSpec{
+		{Name: "addr", Value: "ADDR", Default: "localhost:8080", Usage: "address to listen on"},
+		{Name: "users", Value: "FILE", Usage: "users allowed to log in, one \"EMAIL SHA256\" line each (default: STORE/users)"},
+	},
+}
+
+func setServeCmd() cli.Command {
+
+	return cli.Command{
+		Name:      "serve",
+		Usage:     "run a reference build service backed by the local store",
+		ArgsUsage: serveArgs.usage(),
+		Flags:     serveArgs.flags(),
+		Action:    cmdServeAction,
+	}
+}
+
+func cmdServeAction(c *cli.Context) error {
+	// check input arguments
+	err := serveArgs.check(c)
+	if err != nil {
+		return err
+	}
+
+	dir, err := localStoreDir(c)
+	if err != nil {
+		return sendCmdErr(err)
+	}
+	store, err := newFileStore(dir)
+	if err != nil {
+		return sendCmdErr(err)
+	}
+
+	tokens, err := newTokenStore(filepath.Join(dir, "tokens.json"))
+	if err != nil {
+		return sendCmdErr(err)
+	}
+	path := c.String("users")
+	if path == "" {
+		path = filepath.Join(dir, "users")
+	}
+	users, err := loadPasswords(path)
+	if os.IsNotExist(err) {
+		return sendCmdErr(errors.New("nobody can log in without " + path + ", add a line \"EMAIL SHA256\" for each user, e.g. with printf %s PASSWORD | sha256sum"))
+	}
+	if err != nil {
+		return sendCmdErr(err)
+	}
+
+	fmt.Printf("Serving holo API on http://%s (store: %s)\n", c.String("addr"), dir)
+	err = http.ListenAndServe(c.String("addr"), newAPIHandler(store, tokens, users))
+	if err != nil {
+		return sendCmdErr(err)
+	}
+	return nil
+}
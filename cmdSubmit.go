@@ -3,6 +3,9 @@
 //
 //      - description: submit a new version of a component
 //            - usage: holo submit SUBMISSION SRCROOT [--train TRAIN1,TRAIN2,TRAIN3...] [-f/--force] [--notes NOTES|-]
+//                     without --notes on a terminal, $EDITOR opens on a notes
+//                     template pre-filled with the commits since the previous
+//                     submission (see holoEditor.go)
 //          - example: holo submit osd-38 . --notes - <<-EOF
 //                     holo submit osd-37.1 . --train ThundercatDeuce
 //                     cat notes.txt | holo submit osd-38 . -train train1,2 -notes -
@@ -28,39 +31,43 @@ package main
 
 import (
 	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"os"
-	"regexp"
+	"strconv"
 
 	"github.com/urfave/cli"
 )
 
+var submitArgs = argSchema{
+	Args: []argSpec{
+		{Name: "SUBMISSION", Kind: "submission"},
+		{Name: "SRCROOT"},
+	},
+	Flags: []flagSpec{
+		{Name: "train", Value: "TRAIN", Kind: "train", List: true, Usage: "submit to the given train(s)"},
+		forceFlag,
+		{Name: "notes", Value: "NOTES|-", Kind: "text", Usage: "custom notes for the submission (- reads them from stdin)"},
+	},
+}
+
 func setSubmitCmd() cli.Command {
 
 	return cli.Command{
 		Name:      "submit",
 		Usage:     "submit a new version of a component",
-		ArgsUsage: "SUBMISSION SRCROOT [--train TRAIN1,TRAIN2,TRAIN3...] [-f/--force] [--notes NOTES|-]",
-		Flags: []cli.Flag{
-			cli.StringFlag{
-				Name:  "train",
-				Usage: "remove component from the given train(s)",
-			},
-			cli.StringFlag{
-				Name:  "notes",
-				Usage: "custom notes for the submission",
-			},
-			cli.BoolFlag{
-				Name:  "force, f",
-				Usage: "suppress prompts",
-			},
-		},
-		Action: cmdSubmitAction,
+		ArgsUsage: submitArgs.usage(),
+		Flags:     submitArgs.flags(),
+		Action:    audited(cmdSubmitAction),
 	}
 }
 
 func cmdSubmitAction(c *cli.Context) error {
-	notes := []string{}
+	var notes []string
 
 	// check input arguments
 	err := submitCheckArg(c, &notes)
@@ -68,46 +75,228 @@ func cmdSubmitAction(c *cli.Context) error {
 		return err
 	}
 
-	fmt.Println("In the function of submit:")
-	fmt.Println("### cmd: holo submit SUBMISSION SRCROOT [--train TRAIN1,TRAIN2,TRAIN3...] [-f/--force] [--notes NOTES|-]###")
-	fmt.Println("SUBMISSION: ", c.Args().Get(0))
-	fmt.Println("SRCROOT: ", c.Args().Get(1))
-	fmt.Println("train list: ", c.String("train"))
-	fmt.Println("NOTES: ", notes)
-	fmt.Println("force:", c.Bool("force"))
+	store, err := openStore(c)
+	if err != nil {
+		return sendCmdErr(err)
+	}
+	id, _ := parseSubmissionID(c.Args().Get(0))
+	auditTargets(c, splitList(c.String("train")), nil, []string{id.Component})
+	sub, comp, err := newSubmission(store, c.Args().Get(0), c.String("train"))
+	if err != nil {
+		return sendCmdErr(err)
+	}
+	auditTargets(c, sub.Trains, nil, nil)
+	// before the notes are edited and the submission confirmed
+	err = checkGitWorkTree(c.Args().Get(1))
+	if err != nil {
+		return sendCmdErr(err)
+	}
+	sub.Submitter = actor(c)
+	switch {
+	case notes != nil:
+		sub.Notes = parseNotes(notes)
+	case isTerminal(os.Stdin):
+		prev, err := previousSubmission(store, sub.Component, sub.Name)
+		if err != nil {
+			return sendCmdErr(err)
+		}
+		sub.Notes, err = editNotes(notesTemplate(c.Args().Get(1), sub.Name, prev))
+		if err != nil {
+			return sendCmdErr(err)
+		}
+	default:
+		sub.Notes = parseNotes(nil)
+	}
+
+	im, err := trainsImpact(store, sub.Trains, sub.Component)
+	if err != nil {
+		return sendCmdErr(err)
+	}
+	err = warnMissingDeps(store, sub.Component, sub.Trains)
+	if err != nil {
+		return sendCmdErr(err)
+	}
+	err = confirm(c, im, "Really submit "+sub.Name+"?")
+	if err != nil {
+		return sendCmdErr(err)
+	}
+
+	err = submit(store, sub, comp, c.Args().Get(1))
+	if err != nil {
+		return sendCmdErr(err)
+	}
+	fmt.Println("Done!")
 	return nil
 }
 
-func submitCheckArg(c *cli.Context, notes *[]string) error {
-	var err error
+// newSubmission validates a submission before anything is changed: the
+// component must exist and have a repo, the submission must be new, every
+// target train must carry the component, and the submission must be newer
+// than what each of them carries
+func newSubmission(store Store, name, trainList string) (*Submission, *Component, error) {
+	id, err := parseSubmissionID(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	comp, err := store.GetComponent(id.Component)
+	if err != nil {
+		return nil, nil, err
+	}
+	if comp.Repo == "" {
+		return nil, nil, errors.New("component " + comp.Name + " has no repo to push tags to")
+	}
+	_, err = store.GetSubmission(name)
+	if err == nil {
+		return nil, nil, fmt.Errorf("submission %s %w", name, errExists)
+	}
+	if !errors.Is(err, errNotFound) {
+		return nil, nil, err
+	}
+
+	trains := comp.Trains
+	if trainList != "" {
+		trains = splitList(trainList)
+	}
+	if len(trains) == 0 {
+		return nil, nil, errors.New("component " + comp.Name + " is not a member of any train")
+	}
+	for _, train := range trains {
+		if !containsStr(comp.Trains, train) {
+			return nil, nil, errors.New("component " + comp.Name + " is not a member of train " + train)
+		}
+		err = checkNewerSubmission(store, id, train)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	sub := &Submission{
+		Name:      name,
+		Component: comp.Name,
+		Version:   id.version(),
+		Trains:    trains,
+	}
+	return sub, comp, nil
+}
+
+// checkNewerSubmission makes sure a submission is newer than the version of
+// its component a train carries; a hotfix such as osd-37.1 is only newer
+// on the trains that still carry osd-37, so it has to be sent to that
+// branch explicitly with --train
+func checkNewerSubmission(store Store, id submissionID, train string) error {
+	t, err := store.GetTrain(train)
+	if err != nil {
+		return err
+	}
+	carried, err := carriedSubmission(store, t, id.Component)
+	if err != nil || carried == "" {
+		return err
+	}
+	cid, err := parseSubmissionID(carried)
+	if err != nil || id.compare(cid) > 0 {
+		return nil
+	}
+	msg := "submission " + id.String() + " is not newer than " + carried + " carried by train " + train
+	if id.isHotfix() {
+		msg += ", send the hotfix only to the trains that carry " + id.Component + "-" + strconv.Itoa(id.Version) + " with --train"
+	}
+	return errors.New(msg)
+}
 
-	err = checkNumArg(c, 2)
+// submit tags SRCROOT, pushes the tag, archives HEAD and registers the
+// submission against its trains, with the issue keys of its notes and of
+// the commits since the previous submission; the tag is rolled back if any
+// step fails. SRCROOT was checked to be a git work tree before confirming.
+func submit(store Store, sub *Submission, comp *Component, srcroot string) (err error) {
+	sub.Commit, err = git(srcroot, "rev-parse", "HEAD")
+	if err != nil {
+		return err
+	}
+	prev, err := previousSubmission(store, sub.Component, sub.Name)
 	if err != nil {
 		return err
 	}
+	keys, err := commitIssues(srcroot, prev)
+	if err != nil {
+		return err
+	}
+	sub.Issues = issueKeys(append(sub.Notes.lines(), keys...))
 
-	err = checkOptionalFlagVal(c, "train")
+	fmt.Println("Tagging git repository...")
+	_, err = git(srcroot, "tag", "-a", sub.Name, "-m", "holo submit "+sub.Name)
 	if err != nil {
 		return err
 	}
+	pushed, registered := false, false
+	defer func() {
+		if err == nil {
+			return
+		}
+		fmt.Println("Rolling back...")
+		if registered {
+			store.DeleteSubmission(sub.Name)
+		}
+		if pushed {
+			git(srcroot, "push", comp.Repo, ":refs/tags/"+sub.Name)
+		}
+		git(srcroot, "tag", "-d", sub.Name)
+	}()
 
-	err = checkOptionalFlagNotesVal(c, notes)
+	fmt.Println("Pushing git tags...")
+	_, err = git(srcroot, "push", comp.Repo, "refs/tags/"+sub.Name)
 	if err != nil {
 		return err
 	}
+	pushed = true
 
-	return nil
+	fmt.Println("Archiving HEAD...")
+	archive, err := ioutil.TempFile("", "holo-"+sub.Name+"-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(archive.Name())
+	defer archive.Close()
+	hash := sha256.New()
+	err = gitArchive(srcroot, sub.Name, io.MultiWriter(archive, hash))
+	if err != nil {
+		return err
+	}
+	sub.SHA256 = hex.EncodeToString(hash.Sum(nil))
+
+	fmt.Println("Submitting sources...")
+	err = store.CreateSubmission(sub)
+	if err != nil {
+		return err
+	}
+	registered = true
+	_, err = archive.Seek(0, io.SeekStart)
+	if err != nil {
+		return err
+	}
+	err = store.PutSources(sub.Name, archive)
+	if err != nil {
+		return err
+	}
+	return store.QueueSubmission(sub.Name, sub.Trains)
 }
 
-func checkOptionalFlagNotesVal(c *cli.Context, notes *[]string) error {
-	err := checkOptionalFlagVal(c, "notes")
+func submitCheckArg(c *cli.Context, notes *[]string) error {
+	err := submitArgs.check(c)
 	if err != nil {
 		return err
 	}
+	return checkOptionalFlagNotesVal(c, notes)
+}
 
-	// in case of "--notes -", read from os.Stdin
-	match, _ := regexp.MatchString("-", os.Args[len(os.Args)-1])
-	if match {
+// checkOptionalFlagNotesVal takes the notes from --notes, or from stdin up
+// to a line holding a single "." with --notes -; notes stays nil without
+// --notes
+func checkOptionalFlagNotesVal(c *cli.Context, notes *[]string) error {
+	switch c.String("notes") {
+	case "":
+		return nil
+	case "-":
+		*notes = []string{}
 		scanner := bufio.NewScanner(os.Stdin)
 		for scanner.Scan() {
 			line := scanner.Text()
@@ -116,8 +305,11 @@ func checkOptionalFlagNotesVal(c *cli.Context, notes *[]string) error {
 			}
 			*notes = append(*notes, line)
 		}
-	} else {
-		*notes = append(*notes, c.String("notes"))
+		if scanner.Err() != nil {
+			return sendCmdErr(scanner.Err())
+		}
+		return nil
 	}
+	*notes = append(*notes, c.String("notes"))
 	return nil
 }
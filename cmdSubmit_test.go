@@ -0,0 +1,100 @@
+package main
+
+import (
+	"errors"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// newTestRepo returns a work tree with one commit whose remote is a bare
+// repository, also returned
+func newTestRepo(t *testing.T, message string) (string, string) {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found")
+	}
+	for _, v := range []string{"GIT_AUTHOR_NAME", "GIT_COMMITTER_NAME"} {
+		t.Setenv(v, "holo")
+	}
+	for _, v := range []string{"GIT_AUTHOR_EMAIL", "GIT_COMMITTER_EMAIL"} {
+		t.Setenv(v, "holo@example.com")
+	}
+	remote := filepath.Join(t.TempDir(), "osd.git")
+	work := t.TempDir()
+	_, err := git(work, "init", "-q", "--bare", remote)
+	must(t, err)
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"commit", "-q", "--allow-empty", "-m", message},
+	} {
+		_, err := git(work, args...)
+		must(t, err)
+	}
+	return work, remote
+}
+
+func TestSubmit(t *testing.T) {
+	work, remote := newTestRepo(t, "VHAP-27: Better tiling")
+	store := newTestStore(t)
+	addTrain(t, store, "T", "osd")
+	addTrain(t, store, "Other", "osd")
+	must(t, store.UpdateComponent(&Component{Name: "osd", Owner: "steve@bowerswilkins.com", Repo: remote}))
+
+	sub, comp, err := newSubmission(store, "osd-1", "T,Other")
+	must(t, err)
+	sub.Notes = parseNotes([]string{"Better tiling"})
+	must(t, submit(store, sub, comp, work))
+
+	got, err := store.GetSubmission("osd-1")
+	must(t, err)
+	head, err := git(work, "rev-parse", "HEAD")
+	must(t, err)
+	if got.Commit != head || got.SHA256 == "" || !reflect.DeepEqual(got.Issues, []string{"VHAP-27"}) {
+		t.Errorf("osd-1 = %+v, want commit %s, a checksum and issue VHAP-27", got, head)
+	}
+	if _, err := git(remote, "rev-parse", "-q", "--verify", "refs/tags/osd-1"); err != nil {
+		t.Error("the tag osd-1 was not pushed")
+	}
+	rc, err := store.GetSources("osd-1")
+	must(t, err)
+	rc.Close()
+	for _, train := range []string{"T", "Other"} {
+		tr, err := store.GetTrain(train)
+		must(t, err)
+		if !reflect.DeepEqual(tr.Pending, []string{"osd-1"}) {
+			t.Errorf("train %s has %q pending, want osd-1", train, tr.Pending)
+		}
+	}
+}
+
+func TestSubmitRollback(t *testing.T) {
+	work, remote := newTestRepo(t, "Better tiling")
+	store := newTestStore(t)
+	addTrain(t, store, "T", "osd")
+	must(t, store.UpdateComponent(&Component{Name: "osd", Owner: "steve@bowerswilkins.com", Repo: remote}))
+
+	sub, comp, err := newSubmission(store, "osd-1", "T")
+	must(t, err)
+	sub.Notes = parseNotes(nil)
+	// queueing is the last step, fail it
+	sub.Trains = append(sub.Trains, "Gone")
+	if err := submit(store, sub, comp, work); err == nil {
+		t.Fatal("submit to a train that does not exist succeeded")
+	}
+
+	if _, err := store.GetSubmission("osd-1"); !errors.Is(err, errNotFound) {
+		t.Errorf("the submission is still registered: %v", err)
+	}
+	tr, err := store.GetTrain("T")
+	must(t, err)
+	if len(tr.Pending) != 0 {
+		t.Errorf("train T has %q pending, want nothing", tr.Pending)
+	}
+	for _, dir := range []string{work, remote} {
+		if _, err := git(dir, "rev-parse", "-q", "--verify", "refs/tags/osd-1"); err == nil {
+			t.Errorf("the tag osd-1 is left in %s", dir)
+		}
+	}
+}
@@ -12,6 +12,7 @@
 //   - example output: Avalanche
 //                     Thundercat
 //                     ThundercatDeuce
+//    - output fields: name, created, base, latestRelease, components, pending, reverts
 //
 //        => subCMD 2: show
 //             - desc: describe a given build train
@@ -26,13 +27,23 @@
 //                     - osd
 //                     - zlib
 //                     - ...
+//    - output fields: name, created, base, latestRelease, components, pending, reverts
 //
 //        => subCMD 3: create
-//             - desc: create a new build train, or clone an existing build train
-//            - usage: holo trains create TRAIN [--base TRAIN]
+//             - desc: create a new build train, or clone an existing build
+//                     train: --base copies its component membership and its
+//                     latest release, which the first cut or clone of the
+//                     new train builds on
+//            - usage: holo trains create TRAIN [--base TRAIN] [-f/--force]
 //          - example: holo trains create Avalanche
 //                     holo trains create ThundercatDeuce --base Thundercat
-//   - example output: Success!
+//   - example output: This operation will copy the following from train Thundercat:
+//
+//                     - component bash
+//                     - component osd
+//                     - latest release Thundercat1A177
+//
+//                     Really create train ThundercatDeuce? (y/n)
 //
 
 package main
@@ -43,6 +54,22 @@ import (
 	"github.com/urfave/cli"
 )
 
+var showTrainsArgs = argSchema{
+	Args: []argSpec{
+		{Name: "TRAIN", Kind: "train"},
+	},
+}
+
+var createTrainsArgs = argSchema{
+	Args: []argSpec{
+		{Name: "TRAIN", Kind: "train"},
+	},
+	Flags: []flagSpec{
+		{Name: "base", Value: "TRAIN", Kind: "train", Usage: "base on existing build train"},
+		forceFlag,
+	},
+}
+
 func setTrainsCmd() cli.Command {
 
 	return cli.Command{
@@ -51,27 +78,22 @@ func setTrainsCmd() cli.Command {
 		Subcommands: []cli.Command{
 			{
 				Name:      "ls",
-				ArgsUsage: " ",
+				ArgsUsage: noArgs.usage(),
 				Usage:     "list all known build trains",
 				Action:    lsTrainsAction,
 			},
 			{
 				Name:      "show",
-				ArgsUsage: "TRAIN",
+				ArgsUsage: showTrainsArgs.usage(),
 				Usage:     "describe a given build train",
 				Action:    showTrainsAction,
 			},
 			{
 				Name:      "create",
-				ArgsUsage: "TRAIN [--base TRAIN]",
+				ArgsUsage: createTrainsArgs.usage(),
 				Usage:     "create a new build train, or clone an existing build train",
-				Flags: []cli.Flag{
-					cli.StringFlag{
-						Name:  "base",
-						Usage: "base on existing build train",
-					},
-				},
-				Action: createTrainsAction,
+				Flags:     createTrainsArgs.flags(),
+				Action:    audited(createTrainsAction),
 			},
 		},
 	}
@@ -80,55 +102,92 @@ func setTrainsCmd() cli.Command {
 // Subcommand: ls
 func lsTrainsAction(c *cli.Context) error {
 	// check arguments
-	err := checkNumArg(c, 0)
+	err := noArgs.check(c)
 	if err != nil {
 		return err
 	}
-	fmt.Println("In the function of trains ls:")
-	fmt.Println("### cmd: holo trains ls ###")
 
-	return nil
+	store, err := openStore(c)
+	if err != nil {
+		return sendCmdErr(err)
+	}
+	trains, err := store.ListTrains()
+	if err != nil {
+		return sendCmdErr(err)
+	}
+	return printOutput(c, trains, func() {
+		for _, t := range trains {
+			fmt.Println(t.Name)
+		}
+	})
 }
 
 // Subcommand: show
 func showTrainsAction(c *cli.Context) error {
 	// check arguments
-	err := checkNumArg(c, 1)
+	err := showTrainsArgs.check(c)
 	if err != nil {
 		return err
 	}
 
-	fmt.Println("In the function of trains show:")
-	fmt.Println("### cmd: holo trains show TRAIN ###")
-	fmt.Println("TRAIN: ", c.Args().Get(0))
-
-	return nil
+	store, err := openStore(c)
+	if err != nil {
+		return sendCmdErr(err)
+	}
+	t, err := store.GetTrain(c.Args().Get(0))
+	if err != nil {
+		return sendCmdErr(err)
+	}
+	return printOutput(c, t, func() {
+		fmt.Println("Train:", t.Name)
+		fmt.Println("Created:", t.Created.Format(timeLayout))
+		if t.Base != "" {
+			fmt.Println("Base:", t.Base)
+		}
+		fmt.Println("Latest release:", t.LatestRelease)
+		printList("Component membership", t.Components)
+	})
 }
 
 // Subcommand: create
 func createTrainsAction(c *cli.Context) error {
 	// check arguments
-	err := createTrainsCheckArg(c)
+	err := createTrainsArgs.check(c)
 	if err != nil {
 		return err
 	}
 
-	fmt.Println("In the function of trains create:")
-	fmt.Println("### cmd: holo trains create TRAIN [--base TRAIN] ###")
-	fmt.Println("TRAIN: ", c.Args().Get(0))
-	fmt.Println("base-TRAIN: ", c.String("base"))
-	return nil
-}
-
-// check input arguments:
-func createTrainsCheckArg(c *cli.Context) error {
-	err := checkNumArg(c, 1)
+	store, err := openStore(c)
 	if err != nil {
-		return err
+		return sendCmdErr(err)
 	}
-	err = checkOptionalFlagVal(c, "base")
+	t := &Train{Name: c.Args().Get(0)}
+	auditTargets(c, []string{t.Name, c.String("base")}, nil, nil)
+	if c.String("base") != "" {
+		base, err := store.GetTrain(c.String("base"))
+		if err != nil {
+			return sendCmdErr(err)
+		}
+		t.Base = base.Name
+		t.Components = base.Components
+		t.LatestRelease = base.LatestRelease
+
+		im := &impact{header: "This operation will copy the following from train " + base.Name + ":"}
+		for _, comp := range t.Components {
+			im.items = append(im.items, "component "+comp)
+		}
+		if t.LatestRelease != "" {
+			im.items = append(im.items, "latest release "+t.LatestRelease)
+		}
+		err = confirm(c, im, "Really create train "+t.Name+"?")
+		if err != nil {
+			return sendCmdErr(err)
+		}
+	}
+	err = store.CreateTrain(t)
 	if err != nil {
-		return err
+		return sendCmdErr(err)
 	}
+	fmt.Println("Success!")
 	return nil
 }
@@ -5,54 +5,66 @@
 //            - usage: holo which COMPONENT --release RELEASE
 //          - example: holo which osd --release Thundercat1A176
 //   - example output: osd-35
+//    - output fields: component, release, submission
 
 package main
 
 import (
+	"errors"
 	"fmt"
 
 	"github.com/urfave/cli"
 )
 
+type whichRecord struct {
+	Component  string `json:"component" yaml:"component"`
+	Release    string `json:"release" yaml:"release"`
+	Submission string `json:"submission" yaml:"submission"`
+}
+
+var whichArgs = argSchema{
+	Args: []argSpec{
+		{Name: "COMPONENT", Kind: "component"},
+	},
+	Flags: []flagSpec{
+		{Name: "release", Value: "RELEASE", Kind: "release", Required: true, Usage: "the name of a release within a build train"},
+	},
+}
+
 func setWhichCmd() cli.Command {
 
 	return cli.Command{
 		Name:      "which",
 		Usage:     "show which version of a component appeared in a release",
-		ArgsUsage: "COMPONENT --release RELEASE",
-		Flags: []cli.Flag{
-			cli.StringFlag{
-				Name:  "release",
-				Usage: "the name of a release within a build train",
-			},
-		},
-		Action: cmdWhichAction,
+		ArgsUsage: whichArgs.usage(),
+		Flags:     whichArgs.flags(),
+		Action:    cmdWhichAction,
 	}
 }
 
 func cmdWhichAction(c *cli.Context) error {
 	// check arguments
-	err := whichCheckArg(c)
+	err := whichArgs.check(c)
 	if err != nil {
 		return err
 	}
 
-	fmt.Println("In the function of which:")
-	fmt.Println("### cmd: holo which COMPONENT --release RELEASE ###")
-	fmt.Println("COMPONENT: ", c.Args().Get(0))
-	fmt.Println("release: ", c.String("release"))
-	return nil
-}
-
-func whichCheckArg(c *cli.Context) error {
-	err := checkNumArg(c, 1)
+	store, err := openStore(c)
 	if err != nil {
-		return err
+		return sendCmdErr(err)
 	}
-
-	err = checkFlagVal(c, "release")
+	r, err := store.GetRelease(c.String("release"))
 	if err != nil {
-		return err
+		return sendCmdErr(err)
+	}
+	for _, sub := range r.Submissions {
+		comp, _, err := parseSubmissionName(sub)
+		if err == nil && comp == c.Args().Get(0) {
+			rec := &whichRecord{Component: comp, Release: r.Name, Submission: sub}
+			return printOutput(c, rec, func() {
+				fmt.Println(sub)
+			})
+		}
 	}
-	return nil
+	return sendCmdErr(errors.New("component " + c.Args().Get(0) + " is not part of release " + r.Name))
 }
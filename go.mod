@@ -0,0 +1,13 @@
+module github.com/angelandcafe/cli
+
+go 1.22
+
+require (
+	github.com/urfave/cli v1.22.14
+	gopkg.in/yaml.v2 v2.4.0
+)
+
+require (
+	github.com/cpuguy83/go-md2man/v2 v2.0.2 // indirect
+	github.com/russross/blackfriday/v2 v2.1.0 // indirect
+)
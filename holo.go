@@ -16,13 +16,35 @@ func init() {
 }
 
 func main() {
+	err := newApp().Run(os.Args)
+	if err != nil {
+		os.Exit(1)
+	}
+}
+
+// globalArgs declares the flags given in front of the command
+var globalArgs = argSchema{
+	Flags: []flagSpec{
+		{Name: "config", Value: "FILE", EnvVar: "HOLO_CONFIG", Usage: "configuration file (default: ~/.config/holo/config)"},
+		{Name: "profile", Value: "PROFILE", Kind: "profile", EnvVar: "HOLO_PROFILE", Usage: "profile of the configuration file to use"},
+		{Name: "server", Value: "URL", Usage: "URL of the holo build service (default: use the local store) [$HOLO_SERVER]"},
+		{Name: "store", Value: "DIR", Usage: "directory of the local holo store (default: ~/.holo) [$HOLO_STORE]"},
+		{Name: "output, o", Value: "FORMAT", Choices: []string{"text", "json", "yaml", "table", "template"}, Usage: "output format of read commands: text, json, yaml, table or template [$HOLO_OUTPUT]"},
+		{Name: "template", Value: "TEMPLATE", Kind: "text", Usage: "Go text/template applied to each record with --output template"},
+	},
+}
+
+// newApp sets up the holo command line
+func newApp() *cli.App {
 	app := cli.NewApp()
 
 	app.Name = "holo"
 	app.Usage = "a tool for managing CosmOS builds"
 	app.Version = "1.0"
 	app.UsageText = "holo command [arguments]"
+	app.Flags = globalArgs.flags()
 
+	app.Before = loadConfigAction
 	app.Commands = setCmdList()
 	sort.Sort(cli.CommandsByName(app.Commands))
 
@@ -31,7 +53,5 @@ func main() {
 		cli.ShowAppHelp(c)
 		os.Exit(1)
 	}
-
-	app.Run(os.Args)
-
+	return app
 }
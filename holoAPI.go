@@ -0,0 +1,123 @@
+// file: holoAPI.go
+// purpose: wire format of the holo build-service API
+//
+//      - routes: POST   /v1/login                                   loginRequest => credentials
+//                POST   /v1/refresh                                 => credentials
+//                POST   /v1/logout
+//                GET    /v1/whoami                                  => credentials (without token)
+//                GET    /v1/trains
+//                POST   /v1/trains                                  Train
+//                GET    /v1/trains/TRAIN
+//                GET    /v1/trains/TRAIN/releases
+//                POST   /v1/trains/TRAIN/releases                   Release (cut)
+//                POST   /v1/releases                                Release (clone)
+//                GET    /v1/releases/RELEASE
+//                DELETE /v1/releases/RELEASE
+//                POST   /v1/releases/RELEASE/state                  stateRequest => Release
+//                GET    /v1/releases/RELEASE/artifacts
+//                POST   /v1/releases/RELEASE/artifacts              Artifact (with source)
+//                GET    /v1/releases/RELEASE/artifacts/FILENAME
+//                GET    /v1/releases/RELEASE/artifacts/FILENAME/data    [Range: bytes=OFFSET-]
+//                GET    /v1/releases/RELEASE/uploads/FILENAME          => uploadStatus
+//                PATCH  /v1/releases/RELEASE/uploads/FILENAME?offset=N raw bytes => uploadStatus
+//                POST   /v1/releases/RELEASE/uploads/FILENAME/commit   commitRequest
+//                GET    /v1/components
+//                POST   /v1/components                              Component
+//                GET    /v1/components/COMPONENT
+//                PUT    /v1/components/COMPONENT                    Component (owner, repo, deps)
+//                POST   /v1/components/COMPONENT/trains             linkRequest
+//                DELETE /v1/components/COMPONENT/trains/TRAIN
+//                GET    /v1/submissions[?component=COMPONENT]
+//                POST   /v1/submissions                             Submission
+//                GET    /v1/submissions/SUBMISSION
+//                DELETE /v1/submissions/SUBMISSION
+//                POST   /v1/submissions/SUBMISSION/trains           queueRequest
+//                PUT    /v1/submissions/SUBMISSION/sources          raw bytes
+//                GET    /v1/submissions/SUBMISSION/sources
+//                POST   /v1/reverts                                 []Revert => []Revert
+//                GET    /v1/audit[?train=TRAIN&component=COMPONENT&since=RFC3339]
+//                POST   /v1/audit                                   AuditEntry (actor and time set by the server)
+//
+//      - every object is encoded as JSON using the types in holoStore.go;
+//        failures are answered with an errorResponse and a status code
+//        that maps back onto errNotFound (404), errExists (409),
+//        errUnauthorized (401) and errLocked (423)
+//      - requests other than login need "Authorization: Bearer TOKEN"
+
+package main
+
+import (
+	"errors"
+	"net/http"
+)
+
+const apiPrefix = "/v1"
+
+type loginRequest struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+type queueRequest struct {
+	Trains []string `json:"trains"`
+}
+
+type linkRequest struct {
+	Train string `json:"train"`
+}
+
+type stateRequest struct {
+	State string `json:"state"`
+}
+
+type uploadStatus struct {
+	Offset int64 `json:"offset"`
+}
+
+type commitRequest struct {
+	Artifact Artifact `json:"artifact"`
+	Force    bool     `json:"force"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// apiError is an error received from the build service
+type apiError struct {
+	msg  string
+	kind error
+}
+
+func (e *apiError) Error() string { return e.msg }
+func (e *apiError) Unwrap() error { return e.kind }
+
+// statusOf maps a store error onto an HTTP status code
+func statusOf(err error) int {
+	switch {
+	case errors.Is(err, errNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, errExists):
+		return http.StatusConflict
+	case errors.Is(err, errUnauthorized):
+		return http.StatusUnauthorized
+	case errors.Is(err, errLocked):
+		return http.StatusLocked
+	}
+	return http.StatusBadRequest
+}
+
+// errorOf maps an HTTP status code and message back onto a store error
+func errorOf(status int, msg string) error {
+	switch status {
+	case http.StatusNotFound:
+		return &apiError{msg: msg, kind: errNotFound}
+	case http.StatusConflict:
+		return &apiError{msg: msg, kind: errExists}
+	case http.StatusUnauthorized:
+		return &apiError{msg: msg, kind: errUnauthorized}
+	case http.StatusLocked:
+		return &apiError{msg: msg, kind: errLocked}
+	}
+	return &apiError{msg: msg}
+}
@@ -0,0 +1,273 @@
+// file: holoArgs.go
+// purpose: declarative command arguments - each command declares its
+//          positional arguments and flags once in an argSchema, which
+//          builds the cli flags, the usage line of the help text and
+//          validates what was given before the command runs
+//
+//   - example: var whichArgs = argSchema{
+//                      Args: []argSpec{{Name: "COMPONENT", Kind: "component"}},
+//                      Flags: []flagSpec{{Name: "release", Value: "RELEASE", Kind: "release",
+//                              Required: true, Usage: "the name of a release"}},
+//              }
+//
+//              whichArgs.usage() => COMPONENT --release RELEASE
+//
+//      - a value is checked against its kind (train, release, submission,
+//        email...); a flag value that looks like another flag, as in
+//        --release -f, is reported as missing
+//      - rules spanning several arguments stay in the xxxCheckArg function
+//        of the command
+
+package main
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+
+	"github.com/urfave/cli"
+)
+
+// argSpec declares a positional argument
+type argSpec struct {
+	Name     string // shown in the usage, e.g. RELEASE
+	Kind     string // see argKindChecks
+	Optional bool
+	Variadic bool     // takes every remaining argument
+	Setting  string   // setting used when the argument is omitted
+	Choices  []string // the only values allowed
+}
+
+// flagSpec declares a flag; a flag without Value is a boolean one
+type flagSpec struct {
+	Name     string // e.g. "force, f"
+	Usage    string
+	Value    string // shown in the usage, e.g. RELEASE
+	Kind     string // see argKindChecks
+	Required bool
+	Setting  string   // setting used when the flag is omitted
+	List     bool     // comma separated values of Kind
+	Choices  []string // the only values allowed
+	Default  string
+	EnvVar   string
+}
+
+type argSchema struct {
+	Args  []argSpec
+	Flags []flagSpec
+}
+
+var (
+	namePattern    = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.+-]*$`)
+	releasePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*[1-9][0-9]*[A-Z][1-9][0-9]*$`)
+)
+
+// argKindChecks validate a value of each kind; kinds without a check, as
+// profile or artifact, take any value that does not look like a flag
+var argKindChecks = map[string]func(string) error{
+	"train": func(v string) error {
+		return checkPattern(namePattern, v, "train name", "e.g. Thundercat")
+	},
+	"component": func(v string) error {
+		return checkPattern(namePattern, v, "component name", "e.g. osd")
+	},
+	"release": func(v string) error {
+		return checkPattern(releasePattern, v, "release name", "expected TRAIN MAJOR LETTER BUILD, e.g. Thundercat1A176")
+	},
+	"submission": func(v string) error {
+		_, err := parseSubmissionID(v)
+		return err
+	},
+	"email": func(v string) error {
+		return checkPattern(emailPattern, v, "email address", "e.g. steve@bowerswilkins.com")
+	},
+	"key": checkSettingKey,
+	"date": func(v string) error {
+		_, err := parseAuditDate(v)
+		return err
+	},
+	// free text, e.g. --notes, may be anything
+	"text": func(v string) error {
+		return nil
+	},
+}
+
+func checkPattern(pattern *regexp.Regexp, v, what, hint string) error {
+	if !pattern.MatchString(v) {
+		return errors.New("invalid " + what + " " + v + ", " + hint)
+	}
+	return nil
+}
+
+// noArgs is the schema of commands that take no arguments
+var noArgs = argSchema{}
+
+// forceFlag is the -f/--force flag of commands that ask for confirmation
+var forceFlag = flagSpec{Name: "force, f", Usage: "suppress prompts"}
+
+// usage renders the usage line of the help text, e.g.
+// SUBMISSION [--train TRAIN1,TRAIN2,TRAIN3...] [-f/--force]
+func (s argSchema) usage() string {
+	words := []string{}
+	for _, a := range s.Args {
+		w := a.Name
+		if a.Variadic {
+			w += "..."
+		}
+		if a.Optional || a.Setting != "" {
+			w = "[" + w + "]"
+		}
+		words = append(words, w)
+	}
+	for _, f := range s.Flags {
+		names := strings.Split(f.Name, ",")
+		w := "--" + strings.TrimSpace(names[0])
+		if len(names) > 1 {
+			w = "-" + strings.TrimSpace(names[1]) + "/" + w
+		}
+		switch {
+		case len(f.Choices) > 0:
+			w += " " + strings.Join(f.Choices, "|")
+		case f.List:
+			w += " " + f.Value + "1," + f.Value + "2," + f.Value + "3..."
+		case f.Value != "":
+			w += " " + f.Value
+		}
+		if !f.Required {
+			w = "[" + w + "]"
+		}
+		words = append(words, w)
+	}
+	if len(words) == 0 {
+		// a blank usage keeps the help from showing [arguments]
+		return " "
+	}
+	return strings.Join(words, " ")
+}
+
+// flags builds the cli flags of the schema
+func (s argSchema) flags() []cli.Flag {
+	flags := []cli.Flag{}
+	for _, f := range s.Flags {
+		if f.Value == "" {
+			flags = append(flags, cli.BoolFlag{Name: f.Name, Usage: f.Usage, EnvVar: f.EnvVar})
+			continue
+		}
+		flags = append(flags, cli.StringFlag{Name: f.Name, Value: f.Default, Usage: f.Usage, EnvVar: f.EnvVar})
+	}
+	return flags
+}
+
+// check validates the arguments and flags given to a command, reporting
+// the first problem with sendArgErr
+func (s argSchema) check(c *cli.Context) error {
+	err := s.checkArgs(c)
+	if err == nil {
+		err = s.checkFlags(c)
+	}
+	if err != nil {
+		return sendArgErr(c, err.Error())
+	}
+	return nil
+}
+
+func (s argSchema) checkArgs(c *cli.Context) error {
+	min, max := 0, 0
+	for _, a := range s.Args {
+		if !a.Optional && (a.Setting == "" || setting(c, a.Setting) == "") {
+			min++
+		}
+		max++
+		if a.Variadic {
+			max = -1
+		}
+	}
+	if c.NArg() < min || max >= 0 && c.NArg() > max {
+		return errors.New("incorrect number of arguments")
+	}
+
+	// optional arguments are filled in from the left
+	spare := c.NArg() - min
+	args := c.Args()
+	for _, a := range s.Args {
+		if a.Optional || a.Setting != "" && setting(c, a.Setting) != "" {
+			if spare == 0 {
+				continue
+			}
+			spare--
+		}
+		n := 1
+		if a.Variadic {
+			n = len(args)
+		}
+		for _, v := range args[:n] {
+			if len(a.Choices) > 0 {
+				if !containsStr(a.Choices, v) {
+					return errors.New(a.Name + " must be " + orList(a.Choices))
+				}
+				continue
+			}
+			err := checkKind(a.Kind, v)
+			if err != nil {
+				return err
+			}
+		}
+		args = args[n:]
+	}
+	return nil
+}
+
+func (s argSchema) checkFlags(c *cli.Context) error {
+	for _, f := range s.Flags {
+		name := strings.TrimSpace(strings.Split(f.Name, ",")[0])
+		if f.Value == "" {
+			continue
+		}
+		v := c.String(name)
+		if v == "" {
+			if f.Required && (f.Setting == "" || setting(c, f.Setting) == "") {
+				return errors.New("--" + name + " flag is required")
+			}
+			continue
+		}
+		if strings.HasPrefix(v, "-") && f.Kind != "text" {
+			// e.g. holo artifacts put FILENAME --release -f
+			return errors.New("--" + name + " flag requires a value")
+		}
+		if len(f.Choices) > 0 {
+			if !containsStr(f.Choices, v) {
+				return errors.New("--" + name + " must be " + orList(f.Choices))
+			}
+			continue
+		}
+		values := []string{v}
+		if f.List {
+			values = splitList(v)
+		}
+		for _, v := range values {
+			err := checkKind(f.Kind, v)
+			if err != nil {
+				return errors.New("--" + name + ": " + err.Error())
+			}
+		}
+	}
+	return nil
+}
+
+func checkKind(kind, v string) error {
+	if check, ok := argKindChecks[kind]; ok {
+		return check(v)
+	}
+	if v == "" || strings.HasPrefix(v, "-") {
+		return errors.New("invalid value " + v)
+	}
+	return nil
+}
+
+// orList joins choices for an error message, e.g. "text, markdown or html"
+func orList(choices []string) string {
+	if len(choices) < 2 {
+		return strings.Join(choices, "")
+	}
+	return strings.Join(choices[:len(choices)-1], ", ") + " or " + choices[len(choices)-1]
+}
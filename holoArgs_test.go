@@ -0,0 +1,204 @@
+package main
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/urfave/cli"
+)
+
+// runSchema runs a command declared by s with args and returns what
+// s.check made of them, or the error of parsing the flags
+func runSchema(s argSchema, args ...string) error {
+	var checked error
+	app := cli.NewApp()
+	app.Writer = ioutil.Discard
+	app.ErrWriter = ioutil.Discard
+	app.Flags = []cli.Flag{cli.StringFlag{Name: "train"}, cli.StringFlag{Name: "profile"}}
+	app.Commands = []cli.Command{{
+		Name:      "cmd",
+		ArgsUsage: s.usage(),
+		Flags:     s.flags(),
+		Action: func(c *cli.Context) error {
+			checked = s.check(c)
+			return checked
+		},
+	}}
+	app.OnUsageError = func(c *cli.Context, err error, isSubcommand bool) error {
+		return err
+	}
+	err := app.Run(append([]string{"holo", "cmd"}, args...))
+	if checked == nil && err != nil {
+		return err
+	}
+	return checked
+}
+
+func TestArgSchemaArgs(t *testing.T) {
+	release := argSchema{Args: []argSpec{{Name: "RELEASE", Kind: "release"}}}
+	optional := argSchema{Args: []argSpec{
+		{Name: "COMPONENT", Kind: "component"},
+		{Name: "RELEASE", Kind: "release", Optional: true},
+	}}
+	variadic := argSchema{Args: []argSpec{
+		{Name: "RELEASE", Kind: "release"},
+		{Name: "SUBMISSION", Kind: "submission", Optional: true, Variadic: true},
+	}}
+	fromSetting := argSchema{Args: []argSpec{
+		{Name: "TRAIN", Kind: "train", Setting: "train"},
+		{Name: "COMPONENT", Kind: "component"},
+	}}
+	text := argSchema{Args: []argSpec{{Name: "NOTES", Kind: "text"}}}
+	anything := argSchema{Args: []argSpec{{Name: "SRCROOT"}}}
+	choice := argSchema{Args: []argSpec{{Name: "SHELL", Choices: []string{"bash", "zsh"}}}}
+
+	tests := []struct {
+		desc   string
+		schema argSchema
+		train  string // train setting
+		args   []string
+		ok     bool
+	}{
+		{"no arguments", noArgs, "", nil, true},
+		{"unexpected argument", noArgs, "", []string{"osd"}, false},
+		{"required", release, "", []string{"Thundercat1A1"}, true},
+		{"required missing", release, "", nil, false},
+		{"too many", release, "", []string{"Thundercat1A1", "Thundercat1A2"}, false},
+		{"invalid kind", release, "", []string{"Thundercat"}, false},
+		{"optional given", optional, "", []string{"osd", "Thundercat1A1"}, true},
+		{"optional omitted", optional, "", []string{"osd"}, true},
+		{"optional invalid", optional, "", []string{"osd", "osd"}, false},
+		{"variadic none", variadic, "", []string{"Thundercat1A1"}, true},
+		{"variadic many", variadic, "", []string{"Thundercat1A1", "osd-37", "osd-37.1"}, true},
+		{"variadic invalid", variadic, "", []string{"Thundercat1A1", "osd-37", "osd"}, false},
+		{"setting given", fromSetting, "", []string{"Thundercat", "osd"}, true},
+		{"setting missing", fromSetting, "", []string{"osd"}, false},
+		{"setting used", fromSetting, "Thundercat", []string{"osd"}, true},
+		{"setting overridden", fromSetting, "Thundercat", []string{"Avalanche", "osd"}, true},
+		{"setting overridden invalid", fromSetting, "Thundercat", []string{"Avalanche!", "osd"}, false},
+		{"choice", choice, "", []string{"zsh"}, true},
+		{"choice unknown", choice, "", []string{"tcsh"}, false},
+
+		// values starting with "-" after --
+		{"dash release", release, "", []string{"--", "-Thundercat1A1"}, false},
+		{"dash component", optional, "", []string{"--", "-osd"}, false},
+		{"dash submission", variadic, "", []string{"Thundercat1A1", "--", "-osd-37"}, false},
+		{"dash text", text, "", []string{"--", "- fixed the crash"}, true},
+		{"dash without kind", anything, "", []string{"--", "-r"}, false},
+	}
+	for _, tt := range tests {
+		t.Setenv("HOLO_TRAIN", tt.train)
+		err := runSchema(tt.schema, tt.args...)
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: check(%q) error = %v, want ok %v", tt.desc, tt.args, err, tt.ok)
+		}
+	}
+}
+
+func TestArgSchemaFlags(t *testing.T) {
+	schema := argSchema{Flags: []flagSpec{
+		{Name: "release", Value: "RELEASE", Kind: "release"},
+		{Name: "train", Value: "TRAIN", Kind: "train", List: true},
+		{Name: "format", Value: "FORMAT", Choices: []string{"text", "markdown", "html"}},
+		{Name: "notes", Value: "NOTES", Kind: "text"},
+		{Name: "since", Value: "DATE", Kind: "date"},
+		{Name: "owner", Value: "EMAIL", Kind: "email"},
+		{Name: "addr", Value: "ADDR"},
+		forceFlag,
+	}}
+	required := argSchema{Flags: []flagSpec{
+		{Name: "release", Value: "RELEASE", Kind: "release", Required: true},
+	}}
+	fromSetting := argSchema{Flags: []flagSpec{
+		{Name: "train", Value: "TRAIN", Kind: "train", Required: true, Setting: "train"},
+	}}
+
+	tests := []struct {
+		desc   string
+		schema argSchema
+		train  string // train setting
+		args   []string
+		ok     bool
+	}{
+		{"none", schema, "", nil, true},
+		{"optional given", schema, "", []string{"--release", "Thundercat1A1"}, true},
+		{"invalid kind", schema, "", []string{"--release", "Thundercat"}, false},
+		{"list", schema, "", []string{"--train", "Thundercat,Avalanche"}, true},
+		{"list invalid", schema, "", []string{"--train", "Thundercat,Ava lanche"}, false},
+		{"email", schema, "", []string{"--owner", "steve@bowerswilkins.com"}, true},
+		{"email invalid", schema, "", []string{"--owner", "steve"}, false},
+		{"date", schema, "", []string{"--since", "2017-06-01"}, true},
+		{"date invalid", schema, "", []string{"--since", "June"}, false},
+		{"bool", schema, "", []string{"-f"}, true},
+		{"required", required, "", []string{"--release", "Thundercat1A1"}, true},
+		{"required missing", required, "", nil, false},
+		{"required from setting", fromSetting, "Thundercat", nil, true},
+		{"required setting overridden", fromSetting, "Thundercat", []string{"--train", "Avalanche"}, true},
+		{"required setting missing", fromSetting, "", nil, false},
+
+		// Choices
+		{"choice", schema, "", []string{"--format", "markdown"}, true},
+		{"choice unknown", schema, "", []string{"--format", "pdf"}, false},
+		{"choice case", schema, "", []string{"--format", "HTML"}, false},
+
+		// values starting with "-"
+		{"dash release", schema, "", []string{"--release", "-f"}, false},
+		{"dash list", schema, "", []string{"--train", "-Thundercat"}, false},
+		{"dash choice", schema, "", []string{"--format", "-text"}, false},
+		{"dash date", schema, "", []string{"--since", "-1"}, false},
+		{"dash email", schema, "", []string{"--owner", "-steve@bowerswilkins.com"}, false},
+		{"dash without kind", schema, "", []string{"--addr", "-f"}, false},
+		{"dash text", schema, "", []string{"--notes", "-"}, true},
+		{"dash text line", schema, "", []string{"--notes", "- fixed the crash"}, true},
+
+		// unknown flags
+		{"unknown flag", schema, "", []string{"--bogus"}, false},
+		{"unknown short flag", schema, "", []string{"-x"}, false},
+		{"unknown flag with value", schema, "", []string{"--bogus", "value"}, false},
+		{"flag of another schema", required, "", []string{"--release", "Thundercat1A1", "--format", "text"}, false},
+	}
+	for _, tt := range tests {
+		t.Setenv("HOLO_TRAIN", tt.train)
+		err := runSchema(tt.schema, tt.args...)
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: check(%q) error = %v, want ok %v", tt.desc, tt.args, err, tt.ok)
+		}
+	}
+}
+
+func TestArgSchemaUsage(t *testing.T) {
+	s := argSchema{
+		Args: []argSpec{
+			{Name: "SUBMISSION", Kind: "submission"},
+			{Name: "SRCROOT", Optional: true},
+		},
+		Flags: []flagSpec{
+			{Name: "train", Value: "TRAIN", Kind: "train", List: true},
+			{Name: "format", Value: "FORMAT", Choices: []string{"text", "html"}},
+			{Name: "release", Value: "RELEASE", Required: true},
+			forceFlag,
+		},
+	}
+	want := "SUBMISSION [SRCROOT] [--train TRAIN1,TRAIN2,TRAIN3...] [--format text|html] --release RELEASE [-f/--force]"
+	if got := s.usage(); got != want {
+		t.Errorf("usage() = %q, want %q", got, want)
+	}
+	if got := noArgs.usage(); got != " " {
+		t.Errorf("noArgs.usage() = %q, want a blank", got)
+	}
+}
+
+func TestCheckKind(t *testing.T) {
+	if err := checkKind("", ""); err == nil {
+		t.Error("checkKind accepts an empty value")
+	}
+	if err := checkKind("key", "train"); err != nil {
+		t.Errorf("checkKind(key, train) = %v", err)
+	}
+	if err := checkKind("key", "bogus"); err == nil {
+		t.Error("checkKind accepts an unknown key")
+	}
+	if err := checkKind("submission", "osd-99999999999999999999"); err == nil {
+		t.Error("checkKind accepts an overflowing submission")
+	}
+}
@@ -0,0 +1,100 @@
+// file: holoArtifactName.go
+// purpose: artifact file names - DEVICE_BOARD_VARIANT_RELEASE_KIND.EXT for
+//          device images, e.g. Cake1,1_DVT2_1.0_Thundercat1A176_USBImage.img,
+//          and RELEASE_KIND.EXT for everything else, e.g.
+//          Thundercat1A176_SDKRoot.txz
+
+package main
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+)
+
+var (
+	artifactDevicePattern  = regexp.MustCompile(`^[A-Za-z]+[0-9]+,[0-9]+$`)
+	artifactBoardPattern   = regexp.MustCompile(`^[A-Za-z]+[0-9]*$`)
+	artifactVariantPattern = regexp.MustCompile(`^[0-9]+(\.[0-9]+)*$`)
+	artifactKindPattern    = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9]*\.[A-Za-z0-9]+(\.[A-Za-z0-9]+)*$`)
+)
+
+// artifactName is an artifact file name taken apart; Device, Board and
+// Variant are empty for artifacts that are not device specific
+type artifactName struct {
+	Device  string
+	Board   string
+	Variant string
+	Release string
+	Kind    string
+	Ext     string
+}
+
+// parseArtifactName checks that name follows the naming convention for
+// artifacts of release
+func parseArtifactName(release, name string) (artifactName, error) {
+	an := artifactName{Release: release}
+	invalid := errors.New("invalid artifact name " + name + ", expected DEVICE_BOARD_VARIANT_" + release +
+		"_KIND.EXT (e.g. Cake1,1_DVT2_1.0_" + release + "_USBImage.img) or " + release + "_KIND.EXT")
+
+	var prefix, suffix string
+	if strings.HasPrefix(name, release+"_") {
+		suffix = strings.TrimPrefix(name, release+"_")
+	} else {
+		i := strings.Index(name, "_"+release+"_")
+		if i < 0 {
+			return an, invalid
+		}
+		prefix, suffix = name[:i], name[i+len(release)+2:]
+	}
+
+	if prefix != "" {
+		parts := strings.Split(prefix, "_")
+		if len(parts) != 3 ||
+			!artifactDevicePattern.MatchString(parts[0]) ||
+			!artifactBoardPattern.MatchString(parts[1]) ||
+			!artifactVariantPattern.MatchString(parts[2]) {
+			return an, invalid
+		}
+		an.Device, an.Board, an.Variant = parts[0], parts[1], parts[2]
+	}
+
+	if !artifactKindPattern.MatchString(suffix) {
+		return an, invalid
+	}
+	dot := strings.Index(suffix, ".")
+	an.Kind, an.Ext = suffix[:dot], suffix[dot+1:]
+	return an, nil
+}
+
+// matches reports whether an artifact name passes the --device, --board and
+// --kind filters (an empty filter matches anything)
+func (an artifactName) matches(device, board, kind string) bool {
+	for _, f := range [][2]string{{device, an.Device}, {board, an.Board}, {kind, an.Kind}} {
+		if f[0] != "" && !strings.EqualFold(f[0], f[1]) {
+			return false
+		}
+	}
+	return true
+}
+
+// filterArtifacts fills in the device, board, variant and kind of each
+// artifact and keeps those that pass the filters; artifacts whose name does
+// not follow the convention only pass when there is no filter
+func filterArtifacts(artifacts []Artifact, device, board, kind string) []Artifact {
+	filtered := []Artifact{}
+	for _, a := range artifacts {
+		an, err := parseArtifactName(a.Release, a.Name)
+		if err != nil {
+			if device == "" && board == "" && kind == "" {
+				filtered = append(filtered, a)
+			}
+			continue
+		}
+		a.Device, a.Board, a.Variant, a.Kind = an.Device, an.Board, an.Variant, an.Kind
+		if an.matches(device, board, kind) {
+			filtered = append(filtered, a)
+		}
+	}
+	return filtered
+}
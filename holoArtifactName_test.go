@@ -0,0 +1,112 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseArtifactName(t *testing.T) {
+	const release = "Thundercat1A176"
+	tests := []struct {
+		name string
+		want artifactName
+		ok   bool
+	}{
+		{"Cake1,1_DVT2_1.0_Thundercat1A176_USBImage.img", artifactName{"Cake1,1", "DVT2", "1.0", release, "USBImage", "img"}, true},
+		{"Cake1,1_DVT_1.0.3_Thundercat1A176_USBImage.img", artifactName{"Cake1,1", "DVT", "1.0.3", release, "USBImage", "img"}, true},
+		{"Cake12,3_EVT1_2_Thundercat1A176_Recovery.tar.gz", artifactName{"Cake12,3", "EVT1", "2", release, "Recovery", "tar.gz"}, true},
+		{"Thundercat1A176_SDKRoot.txz", artifactName{Release: release, Kind: "SDKRoot", Ext: "txz"}, true},
+		{"Thundercat1A176_Symbols.tar.gz", artifactName{Release: release, Kind: "Symbols", Ext: "tar.gz"}, true},
+
+		// without a board or a variant
+		{"Cake1,1_1.0_Thundercat1A176_USBImage.img", artifactName{}, false},
+		{"Cake1,1_DVT2_Thundercat1A176_USBImage.img", artifactName{}, false},
+		{"Cake1,1_Thundercat1A176_USBImage.img", artifactName{}, false},
+		{"Cake1,1__1.0_Thundercat1A176_USBImage.img", artifactName{}, false},
+
+		// malformed
+		{"", artifactName{}, false},
+		{"SDKRoot.txz", artifactName{}, false},
+		{"Thundercat1A177_SDKRoot.txz", artifactName{}, false},
+		{"Thundercat1A176_SDKRoot", artifactName{}, false},
+		{"Thundercat1A176_.txz", artifactName{}, false},
+		{"Thundercat1A176_SDK_Root.txz", artifactName{}, false},
+		{"Cake_DVT2_1.0_Thundercat1A176_USBImage.img", artifactName{}, false},
+		{"Cake1,1_DVT-2_1.0_Thundercat1A176_USBImage.img", artifactName{}, false},
+		{"Cake1,1_DVT2_v1_Thundercat1A176_USBImage.img", artifactName{}, false},
+		{"Cake1,1_DVT2_1.0_extra_Thundercat1A176_USBImage.img", artifactName{}, false},
+	}
+	for _, tt := range tests {
+		got, err := parseArtifactName(release, tt.name)
+		if (err == nil) != tt.ok {
+			t.Errorf("parseArtifactName(%q) error = %v, want ok %v", tt.name, err, tt.ok)
+			continue
+		}
+		if tt.ok && got != tt.want {
+			t.Errorf("parseArtifactName(%q) = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestFilterArtifacts(t *testing.T) {
+	artifacts := []Artifact{}
+	for _, name := range []string{
+		"Cake1,1_DVT_1.0_Thundercat1A176_USBImage.img",
+		"Cake1,1_DVT2_1.0_Thundercat1A176_USBImage.img",
+		"Cake2,1_DVT2_1.0_Thundercat1A176_Recovery.img",
+		"Thundercat1A176_SDKRoot.txz",
+		"Cake1,1_Thundercat1A176_USBImage.img", // no board or variant
+		"notes.txt",
+	} {
+		artifacts = append(artifacts, Artifact{Name: name, Release: "Thundercat1A176"})
+	}
+	tests := []struct {
+		device, board, kind string
+		want                []string
+	}{
+		{"", "", "", []string{
+			"Cake1,1_DVT_1.0_Thundercat1A176_USBImage.img",
+			"Cake1,1_DVT2_1.0_Thundercat1A176_USBImage.img",
+			"Cake2,1_DVT2_1.0_Thundercat1A176_Recovery.img",
+			"Thundercat1A176_SDKRoot.txz",
+			"Cake1,1_Thundercat1A176_USBImage.img",
+			"notes.txt",
+		}},
+		{"Cake1,1", "", "", []string{
+			"Cake1,1_DVT_1.0_Thundercat1A176_USBImage.img",
+			"Cake1,1_DVT2_1.0_Thundercat1A176_USBImage.img",
+		}},
+		{"", "DVT2", "", []string{
+			"Cake1,1_DVT2_1.0_Thundercat1A176_USBImage.img",
+			"Cake2,1_DVT2_1.0_Thundercat1A176_Recovery.img",
+		}},
+		{"", "dvt2", "", []string{
+			"Cake1,1_DVT2_1.0_Thundercat1A176_USBImage.img",
+			"Cake2,1_DVT2_1.0_Thundercat1A176_Recovery.img",
+		}},
+		{"", "", "SDKRoot", []string{"Thundercat1A176_SDKRoot.txz"}},
+		{"", "", "usbimage", []string{
+			"Cake1,1_DVT_1.0_Thundercat1A176_USBImage.img",
+			"Cake1,1_DVT2_1.0_Thundercat1A176_USBImage.img",
+		}},
+		{"Cake1,1", "DVT", "USBImage", []string{"Cake1,1_DVT_1.0_Thundercat1A176_USBImage.img"}},
+		{"Cake2,1", "DVT", "", []string{}},
+		{"Cake3,1", "", "", []string{}},
+	}
+	for _, tt := range tests {
+		got := []string{}
+		for _, a := range filterArtifacts(artifacts, tt.device, tt.board, tt.kind) {
+			got = append(got, a.Name)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("filterArtifacts(%q, %q, %q) = %v, want %v", tt.device, tt.board, tt.kind, got, tt.want)
+		}
+	}
+
+	// the parts of the name are filled in
+	got := filterArtifacts(artifacts[1:2], "", "", "")
+	want := Artifact{Name: artifacts[1].Name, Release: "Thundercat1A176", Device: "Cake1,1", Board: "DVT2", Variant: "1.0", Kind: "USBImage"}
+	if len(got) != 1 || !reflect.DeepEqual(got[0], want) {
+		t.Errorf("filterArtifacts filled in %+v, want %+v", got, want)
+	}
+}
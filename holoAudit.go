@@ -0,0 +1,112 @@
+// file: holoAudit.go
+// purpose: audit log - every command that changes shared state records who
+//          ran it, the command line, the trains, releases and components it
+//          touched and how it ended, in the append-only log of the store
+//
+//      - the action of such a command is wrapped with audited and calls
+//        auditTargets once its arguments are checked and it knows what it
+//        is about to change; usage errors before that are not recorded
+//      - outcome: ok, failed (with the error) or aborted at the prompt
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/urfave/cli"
+)
+
+// auditDateLayouts are the formats holo log --since accepts
+var auditDateLayouts = []string{"2006-01-02", "2006-01-02 15:04", time.RFC3339}
+
+func parseAuditDate(v string) (time.Time, error) {
+	for _, layout := range auditDateLayouts {
+		t, err := time.ParseInLocation(layout, v, time.Local)
+		if err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, errors.New("invalid date " + v + ", e.g. 2017-06-01 or \"2017-06-01 12:34\"")
+}
+
+func (q auditQuery) match(e *AuditEntry) bool {
+	if q.Train != "" && !containsStr(e.Trains, q.Train) {
+		return false
+	}
+	if q.Component != "" && !containsStr(e.Components, q.Component) {
+		return false
+	}
+	return q.Since.IsZero() || !e.Time.Before(q.Since)
+}
+
+// audited wraps the action of a command that changes shared state
+func audited(action cli.ActionFunc) cli.ActionFunc {
+	return func(c *cli.Context) error {
+		err := action(c)
+		e, ok := c.App.Metadata["audit"].(*AuditEntry)
+		if !ok {
+			return err
+		}
+		delete(c.App.Metadata, "audit")
+		switch {
+		case err == nil:
+			e.Outcome = "ok"
+		case errors.Is(err, errAborted):
+			e.Outcome = "aborted"
+		default:
+			e.Outcome = "failed"
+			e.Error = err.Error()
+		}
+		store, serr := openStore(c)
+		if serr == nil {
+			serr = store.AppendAudit(e)
+		}
+		if serr != nil {
+			fmt.Fprintln(os.Stderr, "Warning: could not write the audit log: "+serr.Error())
+		}
+		return err
+	}
+}
+
+// auditTargets starts the audit entry of the running command, or adds to
+// it when called again
+func auditTargets(c *cli.Context, trains, releases, components []string) {
+	e, ok := c.App.Metadata["audit"].(*AuditEntry)
+	if !ok {
+		e = &AuditEntry{
+			Time:       time.Now(),
+			Actor:      actor(c),
+			Command:    strings.Join(append([]string{"holo"}, os.Args[1:]...), " "),
+			Trains:     []string{},
+			Releases:   []string{},
+			Components: []string{},
+		}
+		c.App.Metadata["audit"] = e
+	}
+	e.Trains = appendMissing(e.Trains, trains)
+	e.Releases = appendMissing(e.Releases, releases)
+	e.Components = appendMissing(e.Components, components)
+}
+
+func appendMissing(list, items []string) []string {
+	for _, item := range items {
+		if item != "" && !containsStr(list, item) {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// auditRelease adds a release and, when it can be found, its train to the
+// audit entry of the running command
+func auditRelease(c *cli.Context, store Store, release string) {
+	auditTargets(c, nil, []string{release}, nil)
+	t, err := trainOfRelease(store, release)
+	if err == nil {
+		auditTargets(c, []string{t.Name}, nil, nil)
+	}
+}
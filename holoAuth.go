@@ -0,0 +1,262 @@
+// file: holoAuth.go
+// purpose: authentication for holo cli
+//
+//      - client: one credentials file per profile,
+//                ~/.config/holo/credentials/PROFILE (mode 0600), holding the
+//                API token obtained by holo login; the token is sent as
+//                "Authorization: Bearer TOKEN" and refreshed shortly before
+//                it expires
+//      - server: the reference server logs in the users listed in
+//                STORE/users, one "EMAIL SHA256" line each, SHA256 being
+//                the hex digest of the password; the tokens it issues are
+//                kept in STORE/tokens.json; every request but login must
+//                carry a valid token, and the identity behind it is recorded
+//                as the submitter of submissions and the cutter of releases
+//      - local:  without a server there is nobody to log in to, so the
+//                acting identity is the owner setting, the global git
+//                user.email or $USER
+
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/urfave/cli"
+)
+
+const (
+	tokenLifetime = 24 * time.Hour
+	// tokens are refreshed when they expire within refreshWindow
+	refreshWindow = time.Hour
+)
+
+var errUnauthorized = errors.New("unauthorized")
+
+func authError(msg string) error {
+	return &apiError{msg: msg, kind: errUnauthorized}
+}
+
+// credentials are what holo login stores for a profile
+type credentials struct {
+	Server  string    `json:"server"`
+	Email   string    `json:"email"`
+	Token   string    `json:"token"`
+	Expires time.Time `json:"expires"`
+}
+
+func credentialsPath(c *cli.Context) string {
+	return filepath.Join(filepath.Dir(configPath(c)), "credentials", profileName(c))
+}
+
+// loadCredentials returns the stored credentials of the selected profile,
+// or nil when not logged in
+func loadCredentials(c *cli.Context) (*credentials, error) {
+	data, err := ioutil.ReadFile(credentialsPath(c))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var cred credentials
+	err = json.Unmarshal(data, &cred)
+	if err != nil {
+		return nil, errors.New("invalid credentials " + credentialsPath(c) + ": " + err.Error())
+	}
+	return &cred, nil
+}
+
+func saveCredentials(c *cli.Context, cred *credentials) error {
+	path := credentialsPath(c)
+	err := os.MkdirAll(filepath.Dir(path), 0700)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(cred, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0600)
+}
+
+func removeCredentials(c *cli.Context) error {
+	err := os.Remove(credentialsPath(c))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// actor returns the identity recorded for an operation run locally; a
+// build service replaces it with the identity of the token
+func actor(c *cli.Context) string {
+	cred, err := loadCredentials(c)
+	if err == nil && cred != nil && cred.Server == setting(c, "server") {
+		return cred.Email
+	}
+	if setting(c, "owner") != "" {
+		return setting(c, "owner")
+	}
+	// --global, so that the identity does not depend on the directory
+	// holo runs in
+	email, err := git(".", "config", "--global", "user.email")
+	if err == nil && email != "" {
+		return email
+	}
+	u, err := user.Current()
+	if err == nil {
+		return u.Username
+	}
+	return "unknown"
+}
+
+// passwords maps the users of the reference server to the SHA-256 of their
+// password, see the header
+type passwords map[string]string
+
+func loadPasswords(path string) (passwords, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	users := passwords{}
+	for i, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		if len(fields) != 2 || !emailPattern.MatchString(fields[0]) {
+			return nil, fmt.Errorf("invalid user on line %d of %s, expected EMAIL SHA256", i+1, path)
+		}
+		users[fields[0]] = strings.ToLower(fields[1])
+	}
+	return users, nil
+}
+
+// check reports whether password is the password of email
+func (p passwords) check(email, password string) bool {
+	want, ok := p[email]
+	sum := sha256.Sum256([]byte(password))
+	return ok && subtle.ConstantTimeCompare([]byte(hex.EncodeToString(sum[:])), []byte(want)) == 1
+}
+
+// newToken returns a random API token
+func newToken() (string, error) {
+	b := make([]byte, 24)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// tokenStore keeps the tokens issued by the reference server
+type tokenStore struct {
+	path   string
+	mu     sync.Mutex
+	tokens map[string]*credentials
+}
+
+func newTokenStore(path string) (*tokenStore, error) {
+	ts := &tokenStore{path: path, tokens: map[string]*credentials{}}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return ts, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return ts, json.Unmarshal(data, &ts.tokens)
+}
+
+func (ts *tokenStore) save() error {
+	data, err := json.MarshalIndent(ts.tokens, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(ts.path, data, 0600)
+}
+
+// issue creates a token for email
+func (ts *tokenStore) issue(email string) (*credentials, error) {
+	token, err := newToken()
+	if err != nil {
+		return nil, err
+	}
+	cred := &credentials{Email: email, Token: token, Expires: time.Now().Add(tokenLifetime)}
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	for t, old := range ts.tokens {
+		if time.Now().After(old.Expires) {
+			delete(ts.tokens, t)
+		}
+	}
+	ts.tokens[token] = cred
+	return cred, ts.save()
+}
+
+// lookup returns the credentials of a valid token
+func (ts *tokenStore) lookup(token string) (*credentials, error) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	cred := ts.tokens[token]
+	if cred == nil {
+		return nil, authError("invalid token, run holo login")
+	}
+	if time.Now().After(cred.Expires) {
+		return nil, authError("token expired at " + cred.Expires.Format(timeLayout) + ", run holo login")
+	}
+	return cred, nil
+}
+
+func (ts *tokenStore) revoke(token string) error {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	delete(ts.tokens, token)
+	return ts.save()
+}
+
+type identityKey struct{}
+
+// identity returns the authenticated email of a request ("" if anonymous)
+func identity(r *http.Request) string {
+	email, _ := r.Context().Value(identityKey{}).(string)
+	return email
+}
+
+// requireAuth checks the bearer token of every request but login
+func (ts *tokenStore) requireAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth := r.Header.Get("Authorization")
+		if auth == "" {
+			if r.URL.Path != apiPrefix+"/login" {
+				reply(w, r, nil, authError("login required, run holo login"))
+				return
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+		cred, err := ts.lookup(strings.TrimPrefix(auth, "Bearer "))
+		if err != nil {
+			reply(w, r, nil, err)
+			return
+		}
+		ctx := context.WithValue(r.Context(), identityKey{}, cred.Email)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestLogin(t *testing.T) {
+	store, client := newTestServer(t, nil)
+	addTrain(t, store, "T")
+
+	if _, err := client.ListTrains(); !errors.Is(err, errUnauthorized) {
+		t.Errorf("ListTrains without a login = %v, want unauthorized", err)
+	}
+	for _, login := range [][2]string{
+		{testEmail, "wrong"},
+		{testEmail, ""},
+		{"bob@bowerswilkins.com", testPassword},
+	} {
+		if _, err := client.login(login[0], login[1]); !errors.Is(err, errUnauthorized) {
+			t.Errorf("login(%s, %q) = %v, want unauthorized", login[0], login[1], err)
+		}
+	}
+
+	cred, err := client.login(testEmail, testPassword)
+	must(t, err)
+	client.cred = cred
+	trains, err := client.ListTrains()
+	must(t, err)
+	if len(trains) != 1 || trains[0].Name != "T" {
+		t.Errorf("ListTrains = %+v, want train T", trains)
+	}
+	who, err := client.whoami()
+	must(t, err)
+	if who.Email != testEmail || who.Token != "" {
+		t.Errorf("whoami = %+v, want %s without the token", who, testEmail)
+	}
+
+	must(t, client.logout())
+	if _, err := client.ListTrains(); !errors.Is(err, errUnauthorized) {
+		t.Errorf("ListTrains after logout = %v, want unauthorized", err)
+	}
+}
+
+func TestLoginRefresh(t *testing.T) {
+	_, client := newTestServer(t, nil)
+	login(t, client)
+	old := *client.cred
+	var saved *credentials
+	client.save = func(cred *credentials) error {
+		saved = cred
+		return nil
+	}
+
+	// about to expire: the next request refreshes the token first
+	client.cred.Expires = time.Now().Add(refreshWindow / 2)
+	_, err := client.ListTrains()
+	must(t, err)
+	if client.cred.Token == old.Token || saved != client.cred {
+		t.Fatalf("the token was not refreshed and saved: %+v", client.cred)
+	}
+	stale := &apiClient{base: client.base, http: client.http, cred: &old}
+	if _, err := stale.ListTrains(); !errors.Is(err, errUnauthorized) {
+		t.Errorf("the token replaced by a refresh still works: %v", err)
+	}
+
+	// expired: the client does not even try
+	client.cred.Expires = time.Now().Add(-time.Minute)
+	if _, err := client.ListTrains(); !errors.Is(err, errUnauthorized) {
+		t.Errorf("ListTrains with expired credentials = %v, want unauthorized", err)
+	}
+}
@@ -0,0 +1,276 @@
+// file: holoBisect.go
+// purpose: bisect a regression across releases - first over the releases
+//          of a train between a good and a bad one, then over the individual
+//          submissions that changed between the last good and the first bad
+//          release, until the submission that introduced it is found
+//
+//      - state: one bisect per profile, kept in
+//               ~/.config/holo/bisect/PROFILE (mode 0600)
+//      - a release step is tested with its artifacts; a submission step is
+//        the last good release with the changes up to and including that
+//        submission applied, which has to be built to be tested
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/urfave/cli"
+)
+
+var errNoBisect = errors.New("no bisect in progress, run holo bisect start")
+
+// bisectMark is one good, bad or skip verdict
+type bisectMark struct {
+	Step string `json:"step" yaml:"step"`
+	Mark string `json:"mark" yaml:"mark"`
+}
+
+type bisectState struct {
+	Train string `json:"train" yaml:"train"`
+	Good  string `json:"good" yaml:"good"`
+	Bad   string `json:"bad" yaml:"bad"`
+	// Phase is releases, then submissions
+	Phase string `json:"phase" yaml:"phase"`
+	// Steps are the candidates of the current phase, oldest first; Lo is
+	// the last known good step and Hi the first known bad one
+	Steps   []string `json:"steps" yaml:"steps"`
+	Lo      int      `json:"lo" yaml:"lo"`
+	Hi      int      `json:"hi" yaml:"hi"`
+	Skipped []string `json:"skipped" yaml:"skipped"`
+	Current string   `json:"current" yaml:"current"`
+	// Culprit is a submission, or a release when no submission changed
+	// between it and the last good one
+	Culprit string       `json:"culprit,omitempty" yaml:"culprit,omitempty"`
+	Marks   []bisectMark `json:"marks" yaml:"marks"`
+}
+
+// bisectStep is what has to be tested next
+type bisectStep struct {
+	Release     string   `json:"release" yaml:"release"`
+	Applied     []string `json:"applied" yaml:"applied"`
+	Submissions []string `json:"submissions" yaml:"submissions"`
+	Remaining   int      `json:"remaining" yaml:"remaining"`
+}
+
+func bisectPath(c *cli.Context) string {
+	return filepath.Join(filepath.Dir(configPath(c)), "bisect", profileName(c))
+}
+
+func loadBisect(c *cli.Context) (*bisectState, error) {
+	data, err := ioutil.ReadFile(bisectPath(c))
+	if os.IsNotExist(err) {
+		return nil, errNoBisect
+	}
+	if err != nil {
+		return nil, err
+	}
+	var bs bisectState
+	return &bs, json.Unmarshal(data, &bs)
+}
+
+func saveBisect(c *cli.Context, bs *bisectState) error {
+	path := bisectPath(c)
+	err := os.MkdirAll(filepath.Dir(path), 0700)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(bs, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0600)
+}
+
+func resetBisect(c *cli.Context) error {
+	err := os.Remove(bisectPath(c))
+	if os.IsNotExist(err) {
+		return errNoBisect
+	}
+	return err
+}
+
+// startBisect lists the releases of the train between good and bad
+func startBisect(store Store, good, bad string) (*bisectState, error) {
+	t, err := trainOfRelease(store, bad)
+	if err != nil {
+		return nil, err
+	}
+	g, err := parseReleaseName(t.Name, good)
+	if err != nil {
+		return nil, err
+	}
+	b, err := parseReleaseName(t.Name, bad)
+	if err != nil {
+		return nil, err
+	}
+	if g.compare(b) >= 0 {
+		return nil, errors.New("good release " + good + " must be older than bad release " + bad)
+	}
+	releases, err := store.ListReleases(t.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	bs := &bisectState{Train: t.Name, Good: good, Bad: bad, Phase: "releases", Skipped: []string{}, Marks: []bisectMark{}}
+	for i := len(releases) - 1; i >= 0; i-- {
+		rn, err := parseReleaseName(t.Name, releases[i].Name)
+		if err == nil && rn.compare(g) >= 0 && rn.compare(b) <= 0 {
+			bs.Steps = append(bs.Steps, releases[i].Name)
+		}
+	}
+	if len(bs.Steps) < 2 || bs.Steps[0] != good || bs.Steps[len(bs.Steps)-1] != bad {
+		return nil, errors.New("releases " + good + " and " + bad + " must both exist")
+	}
+	bs.Hi = len(bs.Steps) - 1
+	return bs, advanceBisect(store, bs)
+}
+
+// markBisect records a verdict on the current step and moves on
+func markBisect(store Store, bs *bisectState, mark string) error {
+	if bs.Culprit != "" {
+		return errors.New("bisect is done, the culprit is " + bs.Culprit)
+	}
+	if bs.Current == "" {
+		return errors.New("nothing left to test")
+	}
+	i := indexOf(bs.Steps, bs.Current)
+	switch mark {
+	case "good":
+		bs.Lo = i
+	case "bad":
+		bs.Hi = i
+	case "skip":
+		bs.Skipped = append(bs.Skipped, bs.Current)
+	default:
+		return errors.New("unknown mark " + mark + ", expected good, bad or skip")
+	}
+	bs.Marks = append(bs.Marks, bisectMark{Step: bs.Current, Mark: mark})
+	return advanceBisect(store, bs)
+}
+
+// advanceBisect picks the next step, moving from releases to submissions
+// once two adjacent releases are known good and bad
+func advanceBisect(store Store, bs *bisectState) error {
+	bs.Current = ""
+	untested := []int{}
+	for i := bs.Lo + 1; i < bs.Hi; i++ {
+		if !containsStr(bs.Skipped, bs.Steps[i]) {
+			untested = append(untested, i)
+		}
+	}
+	if len(untested) > 0 {
+		// the untested step closest to the middle
+		mid := (bs.Lo + bs.Hi) / 2
+		sort.SliceStable(untested, func(a, b int) bool {
+			return abs(untested[a]-mid) < abs(untested[b]-mid)
+		})
+		bs.Current = bs.Steps[untested[0]]
+		return nil
+	}
+	if bs.Hi-bs.Lo > 1 {
+		// only skipped steps are left, the culprit is one of them
+		return nil
+	}
+
+	if bs.Phase == "submissions" {
+		bs.Culprit = bs.Steps[bs.Hi]
+		return nil
+	}
+	subs, err := changedSubmissions(store, bs.Steps[bs.Lo], bs.Steps[bs.Hi])
+	if err != nil {
+		return err
+	}
+	if len(subs) == 0 {
+		// nothing to narrow down, the release itself is to blame
+		bs.Culprit = bs.Steps[bs.Hi]
+		return nil
+	}
+	// step 0 is the last good release itself, step k applies the first k
+	// changes on top of it
+	bs.Good, bs.Bad = bs.Steps[bs.Lo], bs.Steps[bs.Hi]
+	bs.Phase = "submissions"
+	bs.Steps = append([]string{bs.Good}, subs...)
+	bs.Lo, bs.Hi = 0, len(bs.Steps)-1
+	return advanceBisect(store, bs)
+}
+
+// changedSubmissions lists the submissions that make up the difference
+// between two releases, oldest first; a downgrade counts as one change
+func changedSubmissions(store Store, from, to string) ([]string, error) {
+	d, err := diffReleases(store, from, to)
+	if err != nil {
+		return nil, err
+	}
+	changes := []Submission{}
+	for _, ch := range d.Components {
+		switch ch.Change {
+		case "added", "upgraded":
+			changes = append(changes, ch.Submissions...)
+		case "downgraded":
+			sub, err := store.GetSubmission(ch.To)
+			if err != nil {
+				return nil, err
+			}
+			changes = append(changes, *sub)
+		}
+	}
+	sort.SliceStable(changes, func(i, j int) bool {
+		return changes[i].Created.Before(changes[j].Created)
+	})
+	names := []string{}
+	for _, sub := range changes {
+		names = append(names, sub.Name)
+	}
+	return names, nil
+}
+
+// step describes the current step of a bisect
+func (bs *bisectState) step(store Store) (*bisectStep, error) {
+	st := &bisectStep{Release: bs.Current, Applied: []string{}, Submissions: []string{}, Remaining: bs.Hi - bs.Lo - 1}
+	if bs.Phase == "submissions" {
+		i := indexOf(bs.Steps, bs.Current)
+		st.Release = bs.Good
+		st.Applied = bs.Steps[1 : i+1]
+	}
+	r, err := store.GetRelease(st.Release)
+	if err != nil {
+		return nil, err
+	}
+	st.Submissions = overlaySubmissions(r.Submissions, st.Applied)
+	return st, nil
+}
+
+// suspects lists what the culprit may be when skipped steps stop a bisect
+func (bs *bisectState) suspects() []string {
+	return bs.Steps[bs.Lo+1 : bs.Hi+1]
+}
+
+func (st *bisectStep) String() string {
+	if len(st.Applied) == 0 {
+		return st.Release
+	}
+	return st.Release + " + " + strings.Join(st.Applied, ", ")
+}
+
+func indexOf(list []string, str string) int {
+	for i, s := range list {
+		if s == str {
+			return i
+		}
+	}
+	return -1
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
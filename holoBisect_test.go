@@ -0,0 +1,134 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+// newBisectStore returns a train T whose releases T1A1 to T1A5 bring in
+// osd-1 to osd-4 and bash-1 to bash-2; T1A4 is a rebuild of T1A3
+func newBisectStore(t *testing.T) Store {
+	t.Helper()
+	store := newTestStore(t)
+	addTrain(t, store, "T", "osd", "bash")
+	for _, name := range []string{"osd-1", "bash-1", "osd-2", "osd-3", "bash-2", "osd-4"} {
+		addSubmission(t, store, name, Notes{}, "T")
+	}
+	for _, r := range []Release{
+		{Name: "T1A1", Submissions: []string{"bash-1", "osd-1"}},
+		{Name: "T1A2", Submissions: []string{"bash-1", "osd-2"}},
+		{Name: "T1A3", Submissions: []string{"bash-2", "osd-3"}},
+		{Name: "T1A4", Submissions: []string{"bash-2", "osd-3"}},
+		{Name: "T1A5", Submissions: []string{"bash-2", "osd-4"}},
+	} {
+		r.Train = "T"
+		must(t, store.CloneRelease(&r))
+	}
+	return store
+}
+
+func TestBisect(t *testing.T) {
+	store := newBisectStore(t)
+	tests := []struct {
+		desc    string
+		bad     func(st *bisectStep) bool
+		culprit string
+		marks   []bisectMark
+	}{
+		{
+			"submission",
+			func(st *bisectStep) bool { return containsStr(st.Submissions, "bash-2") },
+			"bash-2",
+			[]bisectMark{{"T1A3", "bad"}, {"T1A2", "good"}, {"osd-3", "good"}},
+		},
+		{
+			"release without changes",
+			func(st *bisectStep) bool { return st.Release == "T1A4" || st.Release == "T1A5" },
+			"T1A4",
+			[]bisectMark{{"T1A3", "good"}, {"T1A4", "bad"}},
+		},
+		{
+			"last release",
+			func(st *bisectStep) bool { return containsStr(st.Submissions, "osd-4") },
+			"osd-4",
+			[]bisectMark{{"T1A3", "good"}, {"T1A4", "good"}},
+		},
+	}
+	for _, tt := range tests {
+		bs, err := startBisect(store, "T1A1", "T1A5")
+		must(t, err)
+		for i := 0; bs.Culprit == "" && bs.Current != "" && i < 10; i++ {
+			st, err := bs.step(store)
+			must(t, err)
+			mark := "good"
+			if tt.bad(st) {
+				mark = "bad"
+			}
+			must(t, markBisect(store, bs, mark))
+		}
+		if bs.Culprit != tt.culprit {
+			t.Errorf("%s: culprit = %q, want %q", tt.desc, bs.Culprit, tt.culprit)
+		}
+		if !reflect.DeepEqual(bs.Marks, tt.marks) {
+			t.Errorf("%s: marks = %v, want %v", tt.desc, bs.Marks, tt.marks)
+		}
+		if err := markBisect(store, bs, "good"); err == nil {
+			t.Errorf("%s: marking a finished bisect succeeded", tt.desc)
+		}
+	}
+}
+
+func TestBisectStep(t *testing.T) {
+	store := newBisectStore(t)
+	bs, err := startBisect(store, "T1A2", "T1A3")
+	must(t, err)
+	if bs.Phase != "submissions" || bs.Current != "osd-3" {
+		t.Fatalf("adjacent releases start at %s %s, want submissions osd-3", bs.Phase, bs.Current)
+	}
+	st, err := bs.step(store)
+	must(t, err)
+	want := &bisectStep{Release: "T1A2", Applied: []string{"osd-3"}, Submissions: []string{"bash-1", "osd-3"}, Remaining: 1}
+	if !reflect.DeepEqual(st, want) {
+		t.Errorf("step = %+v, want %+v", st, want)
+	}
+	if st.String() != "T1A2 + osd-3" {
+		t.Errorf("step.String() = %q", st.String())
+	}
+}
+
+func TestBisectSkip(t *testing.T) {
+	store := newBisectStore(t)
+	bs, err := startBisect(store, "T1A1", "T1A4")
+	must(t, err)
+	for i := 0; bs.Current != "" && i < 10; i++ {
+		must(t, markBisect(store, bs, "skip"))
+	}
+	if bs.Culprit != "" || bs.Current != "" {
+		t.Fatalf("skipping every step gave culprit %q and step %q", bs.Culprit, bs.Current)
+	}
+	if want := []string{"T1A2", "T1A3", "T1A4"}; !reflect.DeepEqual(bs.suspects(), want) {
+		t.Errorf("suspects = %q, want %q", bs.suspects(), want)
+	}
+	if err := markBisect(store, bs, "bad"); err == nil {
+		t.Error("marking with nothing left to test succeeded")
+	}
+}
+
+func TestStartBisectInvalid(t *testing.T) {
+	store := newBisectStore(t)
+	for _, rs := range [][2]string{
+		{"T1A3", "T1A2"},
+		{"T1A3", "T1A3"},
+		{"T1A1", "T1A9"},
+		{"T1A0", "T1A3"},
+	} {
+		if _, err := startBisect(store, rs[0], rs[1]); err == nil {
+			t.Errorf("startBisect(%s, %s) succeeded", rs[0], rs[1])
+		}
+	}
+	bs, err := startBisect(store, "T1A1", "T1A5")
+	must(t, err)
+	if err := markBisect(store, bs, "maybe"); err == nil {
+		t.Error("an unknown mark was accepted")
+	}
+}
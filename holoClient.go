@@ -0,0 +1,441 @@
+// file: holoClient.go
+// purpose: Store implementation that talks to a holo build service over HTTP
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/urfave/cli"
+)
+
+type apiClient struct {
+	base string
+	http *http.Client
+	// cred is attached to every request when logged in; save persists it
+	// after a refresh
+	cred *credentials
+	save func(*credentials) error
+}
+
+func newAPIClient(server string) *apiClient {
+	return &apiClient{
+		base: strings.TrimSuffix(server, "/") + apiPrefix,
+		http: &http.Client{Transport: apiTransport},
+	}
+}
+
+// apiTransport bounds connecting to the service and waiting for it to
+// answer, but not the body: artifacts are streamed for as long as it takes
+var apiTransport = &http.Transport{
+	Proxy: http.ProxyFromEnvironment,
+	DialContext: (&net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}).DialContext,
+	TLSHandshakeTimeout:   10 * time.Second,
+	ResponseHeaderTimeout: 5 * time.Minute,
+	IdleConnTimeout:       90 * time.Second,
+}
+
+// newAuthAPIClient returns a client for the server setting that carries
+// the credentials stored for the selected profile
+func newAuthAPIClient(c *cli.Context) (*apiClient, error) {
+	a := newAPIClient(setting(c, "server"))
+	cred, err := loadCredentials(c)
+	if err != nil {
+		return nil, err
+	}
+	if cred != nil && cred.Server == setting(c, "server") {
+		a.cred = cred
+		a.save = func(cred *credentials) error {
+			return saveCredentials(c, cred)
+		}
+	}
+	return a, nil
+}
+
+// route builds a request URL, escaping every path element
+func (a *apiClient) route(elems ...string) string {
+	path := a.base
+	for _, e := range elems {
+		path += "/" + url.PathEscape(e)
+	}
+	return path
+}
+
+// do sends a request and decodes a JSON response into out (if non-nil)
+func (a *apiClient) do(method, path string, in, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, path, body)
+	if err != nil {
+		return err
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := a.send(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// send performs a request and turns error responses into store errors
+func (a *apiClient) send(req *http.Request) (*http.Response, error) {
+	err := a.authorize(req)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := a.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 300 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+	data, _ := ioutil.ReadAll(resp.Body)
+	var e errorResponse
+	if json.Unmarshal(data, &e) != nil || e.Error == "" {
+		e.Error = fmt.Sprintf("%s %s: %s", req.Method, req.URL.Path, resp.Status)
+	}
+	return nil, errorOf(resp.StatusCode, e.Error)
+}
+
+// authorize attaches the token to a request, refreshing it first when it is
+// about to expire
+func (a *apiClient) authorize(req *http.Request) error {
+	if a.cred == nil {
+		return nil
+	}
+	if time.Now().After(a.cred.Expires) {
+		return authError("login for " + a.cred.Email + " expired at " + a.cred.Expires.Format(timeLayout) + ", run holo login")
+	}
+	if time.Until(a.cred.Expires) < refreshWindow && req.URL.String() != a.route("refresh") {
+		err := a.refresh()
+		if err != nil {
+			return err
+		}
+	}
+	req.Header.Set("Authorization", "Bearer "+a.cred.Token)
+	return nil
+}
+
+// Authentication
+
+func (a *apiClient) login(email, password string) (*credentials, error) {
+	var cred credentials
+	err := a.do("POST", a.route("login"), &loginRequest{Email: email, Password: password}, &cred)
+	if err != nil {
+		return nil, err
+	}
+	return &cred, nil
+}
+
+func (a *apiClient) refresh() error {
+	var cred credentials
+	err := a.do("POST", a.route("refresh"), nil, &cred)
+	if err != nil {
+		return err
+	}
+	cred.Server = a.cred.Server
+	a.cred = &cred
+	if a.save != nil {
+		return a.save(a.cred)
+	}
+	return nil
+}
+
+func (a *apiClient) logout() error {
+	return a.do("POST", a.route("logout"), nil, nil)
+}
+
+func (a *apiClient) whoami() (*credentials, error) {
+	var cred credentials
+	err := a.do("GET", a.route("whoami"), nil, &cred)
+	if err != nil {
+		return nil, err
+	}
+	return &cred, nil
+}
+
+// Trains
+
+func (a *apiClient) ListTrains() ([]Train, error) {
+	var trains []Train
+	err := a.do("GET", a.route("trains"), nil, &trains)
+	return trains, err
+}
+
+func (a *apiClient) GetTrain(name string) (*Train, error) {
+	var t Train
+	err := a.do("GET", a.route("trains", name), nil, &t)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func (a *apiClient) CreateTrain(t *Train) error {
+	return a.do("POST", a.route("trains"), t, t)
+}
+
+func (a *apiClient) QueueSubmission(submission string, trains []string) error {
+	return a.do("POST", a.route("submissions", submission, "trains"), &queueRequest{Trains: trains}, nil)
+}
+
+func (a *apiClient) RevertSubmission(reverts []*Revert) error {
+	var done []Revert
+	err := a.do("POST", a.route("reverts"), reverts, &done)
+	if err != nil {
+		return err
+	}
+	for i := range done {
+		if i < len(reverts) {
+			*reverts[i] = done[i]
+		}
+	}
+	return nil
+}
+
+// Releases
+
+func (a *apiClient) ListReleases(train string) ([]Release, error) {
+	var releases []Release
+	err := a.do("GET", a.route("trains", train, "releases"), nil, &releases)
+	return releases, err
+}
+
+func (a *apiClient) GetRelease(name string) (*Release, error) {
+	var r Release
+	err := a.do("GET", a.route("releases", name), nil, &r)
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+func (a *apiClient) PromoteRelease(name, state string) (*Release, error) {
+	var r Release
+	err := a.do("POST", a.route("releases", name, "state"), &stateRequest{State: state}, &r)
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+func (a *apiClient) CloneRelease(r *Release) error {
+	return a.do("POST", a.route("releases"), r, r)
+}
+
+func (a *apiClient) DeleteRelease(name string) error {
+	return a.do("DELETE", a.route("releases", name), nil, nil)
+}
+
+func (a *apiClient) CutRelease(r *Release) error {
+	return a.do("POST", a.route("trains", r.Train, "releases"), r, r)
+}
+
+// Components
+
+func (a *apiClient) ListComponents() ([]Component, error) {
+	var comps []Component
+	err := a.do("GET", a.route("components"), nil, &comps)
+	return comps, err
+}
+
+func (a *apiClient) GetComponent(name string) (*Component, error) {
+	var comp Component
+	err := a.do("GET", a.route("components", name), nil, &comp)
+	if err != nil {
+		return nil, err
+	}
+	return &comp, nil
+}
+
+func (a *apiClient) CreateComponent(comp *Component) error {
+	return a.do("POST", a.route("components"), comp, comp)
+}
+
+func (a *apiClient) UpdateComponent(comp *Component) error {
+	return a.do("PUT", a.route("components", comp.Name), comp, comp)
+}
+
+func (a *apiClient) LinkComponent(component, train string) error {
+	return a.do("POST", a.route("components", component, "trains"), &linkRequest{Train: train}, nil)
+}
+
+func (a *apiClient) UnlinkComponent(component, train string) error {
+	return a.do("DELETE", a.route("components", component, "trains", train), nil, nil)
+}
+
+// Submissions
+
+func (a *apiClient) ListSubmissions(component string) ([]Submission, error) {
+	path := a.route("submissions")
+	if component != "" {
+		path += "?component=" + url.QueryEscape(component)
+	}
+	var subs []Submission
+	err := a.do("GET", path, nil, &subs)
+	return subs, err
+}
+
+func (a *apiClient) GetSubmission(name string) (*Submission, error) {
+	var sub Submission
+	err := a.do("GET", a.route("submissions", name), nil, &sub)
+	if err != nil {
+		return nil, err
+	}
+	return &sub, nil
+}
+
+func (a *apiClient) CreateSubmission(sub *Submission) error {
+	return a.do("POST", a.route("submissions"), sub, sub)
+}
+
+func (a *apiClient) DeleteSubmission(name string) error {
+	return a.do("DELETE", a.route("submissions", name), nil, nil)
+}
+
+func (a *apiClient) PutSources(submission string, r io.Reader) error {
+	req, err := http.NewRequest("PUT", a.route("submissions", submission, "sources"), r)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/gzip")
+	resp, err := a.send(req)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+func (a *apiClient) GetSources(submission string) (io.ReadCloser, error) {
+	req, err := http.NewRequest("GET", a.route("submissions", submission, "sources"), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := a.send(req)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// Artifacts
+
+func (a *apiClient) ListArtifacts(release string) ([]Artifact, error) {
+	var artifacts []Artifact
+	err := a.do("GET", a.route("releases", release, "artifacts"), nil, &artifacts)
+	return artifacts, err
+}
+
+func (a *apiClient) StatArtifact(release, name string) (*Artifact, error) {
+	var art Artifact
+	err := a.do("GET", a.route("releases", release, "artifacts", name), nil, &art)
+	if err != nil {
+		return nil, err
+	}
+	return &art, nil
+}
+
+func (a *apiClient) OpenArtifact(release, name string, offset int64) (io.ReadCloser, error) {
+	req, err := http.NewRequest("GET", a.route("releases", release, "artifacts", name, "data"), nil)
+	if err != nil {
+		return nil, err
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+	resp, err := a.send(req)
+	if err != nil {
+		return nil, err
+	}
+	// a server ignoring the range sends the whole artifact with 200
+	if offset > 0 && resp.StatusCode != http.StatusPartialContent {
+		resp.Body.Close()
+		return nil, errNoRange
+	}
+	return resp.Body, nil
+}
+
+func (a *apiClient) UploadOffset(release, name string) (int64, error) {
+	var status uploadStatus
+	err := a.do("GET", a.route("releases", release, "uploads", name), nil, &status)
+	return status.Offset, err
+}
+
+func (a *apiClient) UploadChunk(release, name string, offset int64, r io.Reader) (int64, error) {
+	path := a.route("releases", release, "uploads", name) + "?offset=" + strconv.FormatInt(offset, 10)
+	req, err := http.NewRequest("PATCH", path, r)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	resp, err := a.send(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	var status uploadStatus
+	err = json.NewDecoder(resp.Body).Decode(&status)
+	return status.Offset, err
+}
+
+func (a *apiClient) CommitUpload(art *Artifact, force bool) error {
+	req := &commitRequest{Artifact: *art, Force: force}
+	return a.do("POST", a.route("releases", art.Release, "uploads", art.Name, "commit"), req, art)
+}
+
+func (a *apiClient) LinkArtifact(art *Artifact) error {
+	return a.do("POST", a.route("releases", art.Release, "artifacts"), art, art)
+}
+
+// Audit log
+
+func (a *apiClient) AppendAudit(e *AuditEntry) error {
+	return a.do("POST", a.route("audit"), e, e)
+}
+
+func (a *apiClient) ListAudit(q auditQuery) ([]AuditEntry, error) {
+	v := url.Values{}
+	if q.Train != "" {
+		v.Set("train", q.Train)
+	}
+	if q.Component != "" {
+		v.Set("component", q.Component)
+	}
+	if !q.Since.IsZero() {
+		v.Set("since", q.Since.Format(time.RFC3339))
+	}
+	path := a.route("audit")
+	if len(v) > 0 {
+		path += "?" + v.Encode()
+	}
+	var entries []AuditEntry
+	err := a.do("GET", path, nil, &entries)
+	return entries, err
+}
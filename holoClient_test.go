@@ -0,0 +1,184 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	testEmail    = "steve@bowerswilkins.com"
+	testPassword = "tiling"
+)
+
+// newTestServer serves a test store over HTTP and returns a client that is
+// not logged in yet. wrap, when given, sits in front of the API handler.
+func newTestServer(t *testing.T, wrap func(http.Handler) http.Handler) (*fileStore, *apiClient) {
+	t.Helper()
+	store := newTestStore(t)
+	tokens, err := newTokenStore(filepath.Join(t.TempDir(), "tokens"))
+	must(t, err)
+	sum := sha256.Sum256([]byte(testPassword))
+	handler := newAPIHandler(store, tokens, passwords{testEmail: hex.EncodeToString(sum[:])})
+	if wrap != nil {
+		handler = wrap(handler)
+	}
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	return store, newAPIClient(srv.URL)
+}
+
+// login logs the client in with the test user
+func login(t *testing.T, client *apiClient) {
+	t.Helper()
+	cred, err := client.login(testEmail, testPassword)
+	must(t, err)
+	client.cred = cred
+}
+
+func TestDownloadArtifactResume(t *testing.T) {
+	const name = "Cake1,1_DVT2_1.0_T1A1_USBImage.img"
+	data := strings.Repeat("0123456789", 1000)
+	tests := []struct {
+		desc   string
+		part   string   // left by an earlier attempt
+		ranged bool     // whether the server honors Range
+		ranges []string // the Range headers sent
+	}{
+		{"fresh", "", true, []string{""}},
+		{"resumed", data[:4321], true, []string{"bytes=4321-"}},
+		{"complete", data, true, nil},
+		{"too long", data + "garbage", true, []string{""}},
+		{"range ignored", data[:4321], false, []string{"bytes=4321-", ""}},
+		{"range ignored fresh", "", false, []string{""}},
+	}
+	for _, tt := range tests {
+		var ranges []string
+		wrap := func(h http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if strings.HasSuffix(r.URL.Path, "/data") {
+					ranges = append(ranges, r.Header.Get("Range"))
+					if !tt.ranged {
+						r.Header.Del("Range")
+					}
+				}
+				h.ServeHTTP(w, r)
+			})
+		}
+		store, client := newTestServer(t, wrap)
+		addTrain(t, store, "T")
+		must(t, store.CloneRelease(&Release{Name: "T1A1", Train: "T"}))
+		a := addArtifact(t, store, "T1A1", name, data)
+		login(t, client)
+
+		dest := filepath.Join(t.TempDir(), name)
+		if tt.part != "" {
+			must(t, ioutil.WriteFile(dest+".part", []byte(tt.part), 0644))
+		}
+		err := downloadArtifact(client, a, dest)
+		if err != nil {
+			t.Errorf("%s: downloadArtifact: %v", tt.desc, err)
+			continue
+		}
+		got, err := ioutil.ReadFile(dest)
+		must(t, err)
+		if string(got) != data {
+			t.Errorf("%s: downloaded %d bytes, want the %d of the artifact", tt.desc, len(got), len(data))
+		}
+		if _, err := os.Stat(dest + ".part"); !os.IsNotExist(err) {
+			t.Errorf("%s: %s.part is left behind", tt.desc, name)
+		}
+		if !reflect.DeepEqual(ranges, tt.ranges) {
+			t.Errorf("%s: requested ranges %q, want %q", tt.desc, ranges, tt.ranges)
+		}
+	}
+}
+
+func TestUploadArtifactResume(t *testing.T) {
+	const name = "Cake1,1_DVT2_1.0_T1A1_USBImage.img"
+	data := strings.Repeat("0123456789", 1000)
+	file := filepath.Join(t.TempDir(), name)
+	must(t, ioutil.WriteFile(file, []byte(data), 0644))
+	tests := []struct {
+		desc string
+		part string // uploaded by an earlier attempt
+		ok   bool
+	}{
+		{"fresh", "", true},
+		{"resumed", data[:4321], true},
+		{"corrupt", strings.Repeat("x", 4321), false},
+		{"too long", data + "garbage", false},
+	}
+	for _, tt := range tests {
+		store, client := newTestServer(t, nil)
+		addTrain(t, store, "T")
+		must(t, store.CloneRelease(&Release{Name: "T1A1", Train: "T"}))
+		login(t, client)
+		if tt.part != "" {
+			_, err := store.UploadChunk("T1A1", name, 0, strings.NewReader(tt.part))
+			must(t, err)
+		}
+
+		err := uploadArtifact(client, &Artifact{Name: name, Release: "T1A1"}, file, false)
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: uploadArtifact error = %v, want ok %v", tt.desc, err, tt.ok)
+		}
+		if !tt.ok {
+			if _, err := store.StatArtifact("T1A1", name); !errors.Is(err, errNotFound) {
+				t.Errorf("%s: a failed upload was published: %v", tt.desc, err)
+			}
+			continue
+		}
+		rc, err := store.OpenArtifact("T1A1", name, 0)
+		must(t, err)
+		got, err := ioutil.ReadAll(rc)
+		rc.Close()
+		must(t, err)
+		if string(got) != data {
+			t.Errorf("%s: stored %d bytes, want the %d of the file", tt.desc, len(got), len(data))
+		}
+	}
+}
+
+func TestCacheValid(t *testing.T) {
+	data := "0123456789"
+	sum := sha256.Sum256([]byte(data))
+	a := &Artifact{Name: "T1A1_SDKRoot.txz", Release: "T1A1", Size: int64(len(data)), SHA256: hex.EncodeToString(sum[:])}
+	cached := filepath.Join(t.TempDir(), a.Name)
+	verified := time.Now().Add(-time.Minute)
+	write := func(content, checksum string, changed time.Time) {
+		must(t, ioutil.WriteFile(cached, []byte(content), 0644))
+		must(t, ioutil.WriteFile(cached+".sha256", []byte(checksum), 0644))
+		must(t, os.Chtimes(cached, changed, changed))
+		must(t, os.Chtimes(cached+".sha256", verified, verified))
+	}
+
+	if cacheValid(cached, a) {
+		t.Error("an empty cache is valid")
+	}
+	write(data, a.SHA256, verified)
+	if !cacheValid(cached, a) {
+		t.Error("a verified copy is not valid")
+	}
+	write(data, "0000", verified)
+	if cacheValid(cached, a) {
+		t.Error("a copy of another checksum is valid")
+	}
+	write(data[:5], a.SHA256, verified)
+	if cacheValid(cached, a) {
+		t.Error("a truncated copy is valid")
+	}
+	write("9876543210", a.SHA256, time.Now())
+	if cacheValid(cached, a) {
+		t.Error("a copy changed after it was verified is valid")
+	}
+}
@@ -6,58 +6,72 @@ package main
 import (
 	"errors"
 	"fmt"
-	"os"
 	"regexp"
+	"strings"
 
 	"github.com/urfave/cli"
 )
 
+// emailPattern matches a plausible email address, e.g. steve@bowerswilkins.com
+var emailPattern = regexp.MustCompile(`^[^@\s]+@[^@\s]+\.[^@\s]+$`)
+
 func sendArgErr(c *cli.Context, str string) error {
 	fmt.Print("Incorrect Usage: ", str, "!\n\n")
 	cli.ShowSubcommandHelp(c)
 	return errors.New(str)
 }
 
-func checkNumArg(c *cli.Context, argNum int) error {
-	if c.NArg() != argNum {
-		return sendArgErr(c, "incorrect number of arguments")
+// sendCmdErr reports an error raised while executing a command
+func sendCmdErr(err error) error {
+	fmt.Print("Error: ", err, "!\n")
+	return err
+}
+
+// trainArg returns the TRAIN argument of a command, defaulting to the
+// train setting when it is omitted
+func trainArg(c *cli.Context) string {
+	if c.NArg() == 0 {
+		return setting(c, "train")
 	}
-	return nil
+	return c.Args().Get(0)
 }
 
-func checkFlagVal(c *cli.Context, strFlag string) error {
-	if c.String(strFlag) == "" {
-		err := "--" + strFlag + " flag is required"
-		return sendArgErr(c, err)
+// flagOrSetting returns the value of a flag, defaulting to a setting
+func flagOrSetting(c *cli.Context, flag, key string) string {
+	if c.String(flag) != "" {
+		return c.String(flag)
 	}
+	return setting(c, key)
+}
 
-	// in case of an example:
-	// incorrect command: holo artifacts put FILENAME --release -f
-	// correct command: holo artifacts put FILENAME --release RELEASE -f
-	// issue: --release flag takes "-f" as its argument
-	errFlagArgContainsForceFlag, _ := regexp.MatchString("^(-)+f(orce)*$", c.String(strFlag))
-	if errFlagArgContainsForceFlag {
-		err := "--" + strFlag + " flag requires an argument"
-		return sendArgErr(c, err)
+// splitList splits a comma separated flag value, e.g. --train TRAIN1,TRAIN2
+func splitList(str string) []string {
+	list := []string{}
+	for _, s := range strings.Split(str, ",") {
+		s = strings.TrimSpace(s)
+		if s != "" {
+			list = append(list, s)
+		}
 	}
+	return list
+}
 
-	// in case of an example:
-	// incorrect command: holo artifacts put FILENAME WRONG_Extra_Arg --release
-	// correct command: holo artifacts put FILENAME --release RELEASE
-	// issue: --release flag takes "FILENAME" as its argument
-	// => exclude "-f", "-force", "--f", "--force", "-"
-	forceFlag, _ := regexp.MatchString("^(-)+f(orce)*$", os.Args[len(os.Args)-1])
-	flagPrefix, _ := regexp.MatchString("^(-)+.+$", os.Args[len(os.Args)-1])
-	if flagPrefix && !forceFlag {
-		err := "--" + strFlag + " flag requires an argument"
-		return sendArgErr(c, err)
+// parseSubmissionName splits a submission name, e.g. osd-38, into its
+// component and version
+func parseSubmissionName(name string) (string, string, error) {
+	id, err := parseSubmissionID(name)
+	if err != nil {
+		return "", "", err
 	}
-	return nil
+	return id.Component, id.version(), nil
 }
 
-func checkOptionalFlagVal(c *cli.Context, strFlag string) error {
-	if c.String(strFlag) == "" {
-		return nil
+// printList prints a header followed by a bulleted list, e.g.
+// Submissions:
+// - osd-38
+func printList(header string, list []string) {
+	fmt.Println(header + ":")
+	for _, s := range list {
+		fmt.Println("-", s)
 	}
-	return checkFlagVal(c, strFlag)
 }
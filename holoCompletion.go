@@ -0,0 +1,343 @@
+// file: holoCompletion.go
+// purpose: shell completion - subcommands and flags come from the command
+//          list, train, release, component, submission and artifact names
+//          from the store; the shell scripts of holo completion call
+//          holo __complete WORD... CURRENT and offer the lines it prints
+//
+//      - what an argument or a flag value takes comes from the argSchema
+//        of its command: names of its Kind from the store, or its Choices
+//      - names from the store are cached for a minute in
+//        ~/.cache/holo/completion/PROFILE/ so that a slow server does not
+//        stall the shell
+
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/urfave/cli"
+)
+
+const completionTTL = time.Minute
+
+// commandArgs holds the argSchema of each command taking arguments or
+// flags, by command path
+var commandArgs = map[string]argSchema{
+	"notes":             notesArgs,
+	"issues":            issuesArgs,
+	"log":               logArgs,
+	"revert":            revertArgs,
+	"bisect start":      startBisectArgs,
+	"bisect run":        runBisectArgs,
+	"submit":            submitArgs,
+	"which":             whichArgs,
+	"artifacts ls":      lsArtifactsArgs,
+	"artifacts put":     putArtifactsArgs,
+	"artifacts get":     getArtifactsArgs,
+	"components show":   showComponentsArgs,
+	"components create": createComponentsArgs,
+	"components link":   linkComponentsArgs,
+	"components unlink": unlinkComponentsArgs,
+	"components deps":   depsComponentsArgs,
+	"components graph":  graphComponentsArgs,
+	"releases ls":       lsReleasesArgs,
+	"releases show":     showReleasesArgs,
+	"releases cut":      cutReleasesArgs,
+	"releases clone":    cloneReleasesArgs,
+	"releases diff":     diffReleasesArgs,
+	"releases promote":  promoteReleasesArgs,
+	"trains show":       showTrainsArgs,
+	"trains create":     createTrainsArgs,
+	"serve":             serveArgs,
+	"config get":        getConfigArgs,
+	"config set":        setConfigArgs,
+	"config use":        useConfigArgs,
+	"login":             loginArgs,
+	"plan":              planArgs,
+	"apply":             applyArgs,
+	"completion":        completionArgs,
+}
+
+// completionCache is one cached list of names; Store is the server or
+// local store the names came from
+type completionCache struct {
+	Created time.Time `json:"created"`
+	Store   string    `json:"store"`
+	Names   []string  `json:"names"`
+}
+
+// completionLine is a parsed command line up to the word being completed
+type completionLine struct {
+	path   []string // command names, e.g. releases cut
+	cmd    *cli.Command
+	flags  []cli.Flag
+	schema argSchema
+	args   []string          // positional arguments
+	set    map[string]string // flag values, by flag name
+	flag   string            // the flag whose value is being completed
+}
+
+// parseCompletionLine walks the words before the one being completed
+// through the command tree
+func parseCompletionLine(app *cli.App, words []string) *completionLine {
+	cl := &completionLine{flags: app.Flags, schema: globalArgs, set: map[string]string{}}
+	cmds := app.Commands
+	for i := 0; i < len(words); i++ {
+		w := words[i]
+		if strings.HasPrefix(w, "-") && w != "-" {
+			name := strings.TrimLeft(w, "-")
+			if strings.Contains(name, "=") {
+				parts := strings.SplitN(name, "=", 2)
+				cl.set[parts[0]] = parts[1]
+				continue
+			}
+			f := findFlag(cl.flags, name)
+			if f == nil || !takesValue(f) {
+				cl.set[name] = ""
+				continue
+			}
+			if i == len(words)-1 {
+				cl.flag = flagName(f)
+				continue
+			}
+			i++
+			cl.set[flagName(f)] = words[i]
+			continue
+		}
+		if len(cl.args) == 0 {
+			if cmd := findCommand(cmds, w); cmd != nil {
+				cl.path = append(cl.path, cmd.Name)
+				cl.cmd = cmd
+				cl.flags = cmd.Flags
+				cl.schema = commandArgs[strings.Join(cl.path, " ")]
+				cmds = cmd.Subcommands
+				continue
+			}
+		}
+		cl.args = append(cl.args, w)
+	}
+	return cl
+}
+
+// candidates lists the completions of cur
+func (cl *completionLine) candidates(c *cli.Context, cur string) []string {
+	if cl.flag != "" {
+		for _, f := range cl.schema.Flags {
+			if strings.TrimSpace(strings.Split(f.Name, ",")[0]) == cl.flag {
+				return cl.values(c, f.Kind, f.Choices, cur, f.List)
+			}
+		}
+		return nil
+	}
+	if strings.HasPrefix(cur, "-") {
+		names := []string{}
+		for _, f := range cl.flags {
+			for _, name := range strings.Split(f.GetName(), ",") {
+				name = strings.TrimSpace(name)
+				if len(name) == 1 {
+					names = append(names, "-"+name)
+				} else {
+					names = append(names, "--"+name)
+				}
+			}
+		}
+		return withPrefix(names, cur)
+	}
+
+	if cl.cmd == nil || len(cl.cmd.Subcommands) > 0 {
+		cmds := c.App.Commands
+		if cl.cmd != nil {
+			cmds = cl.cmd.Subcommands
+		}
+		names := []string{}
+		for _, cmd := range cmds {
+			if !cmd.Hidden {
+				names = append(names, cmd.Name)
+			}
+		}
+		return withPrefix(names, cur)
+	}
+
+	args := cl.schema.Args
+	if len(args) == 0 {
+		return nil
+	}
+	a := args[len(args)-1]
+	if len(cl.args) < len(args) {
+		a = args[len(cl.args)]
+	} else if !a.Variadic {
+		return nil
+	}
+	return cl.values(c, a.Kind, a.Choices, cur, false)
+}
+
+// values lists the choices, or the names of a kind, that start with cur
+func (cl *completionLine) values(c *cli.Context, kind string, choices []string, cur string, list bool) []string {
+	prefix := ""
+	if list && strings.Contains(cur, ",") {
+		prefix = cur[:strings.LastIndex(cur, ",")+1]
+		cur = cur[len(prefix):]
+	}
+
+	var names []string
+	switch {
+	case len(choices) > 0:
+		names = choices
+	case kind == "train", kind == "component", kind == "release", kind == "submission":
+		names = cachedNames(c, kind, "")
+	case kind == "artifact":
+		release := cl.set["release"]
+		if release == "" {
+			return nil
+		}
+		names = cachedNames(c, kind, release)
+	case kind == "key":
+		names = settingKeys
+	case kind == "profile":
+		names = profileNames()
+	default:
+		return nil
+	}
+
+	candidates := []string{}
+	for _, name := range withPrefix(names, cur) {
+		candidates = append(candidates, prefix+name)
+	}
+	return candidates
+}
+
+// cachedNames returns the names of a kind from the completion cache, or
+// from the store when the cache is missing or stale; errors only mean no
+// completions
+func cachedNames(c *cli.Context, kind, release string) []string {
+	path, source := "", setting(c, "server")+setting(c, "store")
+	dir, err := cacheDir(c)
+	if err == nil {
+		key := kind
+		if release != "" {
+			key += "-" + release
+		}
+		path = filepath.Join(dir, "completion", profileName(c), key+".json")
+		var cache completionCache
+		data, err := ioutil.ReadFile(path)
+		if err == nil && json.Unmarshal(data, &cache) == nil &&
+			cache.Store == source && time.Since(cache.Created) < completionTTL {
+			return cache.Names
+		}
+	}
+
+	store, err := openStore(c)
+	if err != nil {
+		return nil
+	}
+	names, err := storeNames(store, kind, release)
+	if err != nil {
+		return nil
+	}
+	if path != "" {
+		data, err := json.Marshal(completionCache{Created: time.Now(), Store: source, Names: names})
+		if err == nil && os.MkdirAll(filepath.Dir(path), 0755) == nil {
+			ioutil.WriteFile(path, data, 0644)
+		}
+	}
+	return names
+}
+
+// storeNames lists the names of a kind from the store
+func storeNames(store Store, kind, release string) ([]string, error) {
+	names := []string{}
+	switch kind {
+	case "train", "release":
+		trains, err := store.ListTrains()
+		if err != nil {
+			return nil, err
+		}
+		for _, t := range trains {
+			if kind == "train" {
+				names = append(names, t.Name)
+				continue
+			}
+			releases, err := store.ListReleases(t.Name)
+			if err != nil {
+				return nil, err
+			}
+			for _, r := range releases {
+				names = append(names, r.Name)
+			}
+		}
+	case "component", "submission":
+		comps, err := store.ListComponents()
+		if err != nil {
+			return nil, err
+		}
+		for _, comp := range comps {
+			if kind == "component" {
+				names = append(names, comp.Name)
+				continue
+			}
+			subs, err := store.ListSubmissions(comp.Name)
+			if err != nil {
+				return nil, err
+			}
+			for _, sub := range subs {
+				names = append(names, sub.Name)
+			}
+		}
+	case "artifact":
+		artifacts, err := store.ListArtifacts(release)
+		if err != nil {
+			return nil, err
+		}
+		for _, a := range artifacts {
+			names = append(names, a.Name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func findCommand(cmds []cli.Command, name string) *cli.Command {
+	for i := range cmds {
+		if cmds[i].HasName(name) {
+			return &cmds[i]
+		}
+	}
+	return nil
+}
+
+func findFlag(flags []cli.Flag, name string) cli.Flag {
+	for _, f := range flags {
+		for _, n := range strings.Split(f.GetName(), ",") {
+			if strings.TrimSpace(n) == name {
+				return f
+			}
+		}
+	}
+	return nil
+}
+
+// flagName returns the long name of a flag, e.g. force for "force, f"
+func flagName(f cli.Flag) string {
+	return strings.TrimSpace(strings.Split(f.GetName(), ",")[0])
+}
+
+func takesValue(f cli.Flag) bool {
+	_, isBool := f.(cli.BoolFlag)
+	return !isBool
+}
+
+func withPrefix(names []string, prefix string) []string {
+	matches := []string{}
+	for _, name := range names {
+		if strings.HasPrefix(name, prefix) {
+			matches = append(matches, name)
+		}
+	}
+	return matches
+}
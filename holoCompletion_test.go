@@ -0,0 +1,97 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/urfave/cli"
+)
+
+// complete runs holo __complete with words and returns the candidates it
+// prints
+func complete(t *testing.T, store *fileStore, words ...string) []string {
+	t.Helper()
+	out, err := os.Create(filepath.Join(t.TempDir(), "stdout"))
+	must(t, err)
+	defer out.Close()
+	stdout := os.Stdout
+	os.Stdout = out
+	err = runHolo(t, store, append([]string{"__complete"}, words...)...)
+	os.Stdout = stdout
+	must(t, err)
+	data, err := ioutil.ReadFile(out.Name())
+	must(t, err)
+	return strings.Fields(string(data))
+}
+
+func TestCommandArgs(t *testing.T) {
+	paths := map[string]bool{}
+	var walk func(path string, cmds []cli.Command)
+	walk = func(path string, cmds []cli.Command) {
+		for _, cmd := range cmds {
+			p := strings.TrimSpace(path + " " + cmd.Name)
+			if len(cmd.Subcommands) > 0 {
+				walk(p, cmd.Subcommands)
+				continue
+			}
+			if cmd.Hidden || cmd.ArgsUsage == noArgs.usage() {
+				continue
+			}
+			paths[p] = true
+			s, ok := commandArgs[p]
+			if !ok {
+				t.Errorf("holo %s has no argSchema in commandArgs", p)
+				continue
+			}
+			if s.usage() != cmd.ArgsUsage || len(s.flags()) != len(cmd.Flags) {
+				t.Errorf("commandArgs[%q] is not the argSchema of holo %s", p, p)
+			}
+		}
+	}
+	walk("", newApp().Commands)
+	for p := range commandArgs {
+		if !paths[p] {
+			t.Errorf("commandArgs[%q] names no command", p)
+		}
+	}
+}
+
+func TestComplete(t *testing.T) {
+	store := newTestStore(t)
+	addTrain(t, store, "Thundercat", "osd")
+	addTrain(t, store, "Avalanche", "osd")
+	must(t, store.CloneRelease(&Release{Name: "Thundercat1A1", Train: "Thundercat"}))
+	addArtifact(t, store, "Thundercat1A1", "Thundercat1A1_SDKRoot.txz", "sdk")
+
+	tests := []struct {
+		words []string
+		want  []string
+	}{
+		{[]string{"rel"}, []string{"releases"}},
+		{[]string{"releases", "promote", "--"}, []string{"--to", "--force"}},
+		{[]string{"releases", "promote", "Thundercat1A1", "--to", ""}, promoteStates},
+		{[]string{"releases", "diff", "Thundercat1A1", ""}, []string{"Thundercat1A1"}},
+		{[]string{"trains", "create", "Thundercat2", "--base", ""}, []string{"Avalanche", "Thundercat"}},
+		{[]string{"notes", "--since", "T"}, []string{"Thundercat1A1"}},
+		{[]string{"log", "--since", ""}, nil},
+		{[]string{"revert", "osd-1", "--train", "Thundercat,A"}, []string{"Thundercat,Avalanche"}},
+		{[]string{"artifacts", "get", "--release", "Thundercat1A1", ""}, []string{"Thundercat1A1_SDKRoot.txz"}},
+		{[]string{"artifacts", "put", "--release", "Thundercat1A1", ""}, nil},
+		{[]string{"config", "get", "tr"}, []string{"train", "tracker"}},
+		{[]string{"completion", ""}, completionShells},
+		{[]string{"--output", "j"}, []string{"json"}},
+	}
+	for _, tt := range tests {
+		got := complete(t, store, tt.words...)
+		if len(got) == 0 && len(tt.want) == 0 {
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("complete(%q) = %q, want %q", tt.words, got, tt.want)
+		}
+	}
+}
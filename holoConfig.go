@@ -0,0 +1,160 @@
+// file: holoConfig.go
+// purpose: configuration file, profiles and environment overrides
+//
+//      - file: ~/.config/holo/config (or --config FILE / $HOLO_CONFIG)
+//
+//              profile: default
+//              profiles:
+//                default:
+//                  server: http://holo.bowerswilkins.com
+//                  train: Thundercat
+//                  owner: steve@bowerswilkins.com
+//                laptop:
+//                  store: /home/steve/holo-test
+//                  output: json
+//
+//      - keys: server, store, train, owner, cache-dir, output, tracker
+//      - the profile is picked by --profile, $HOLO_PROFILE, then the
+//        "profile" entry of the file, then "default"
+//      - a setting is taken from its global option (if any), then from
+//        $HOLO_<KEY> (e.g. HOLO_CACHE_DIR), then from the profile
+
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/urfave/cli"
+	"gopkg.in/yaml.v2"
+)
+
+// settingKeys are the keys a profile may contain
+var settingKeys = []string{"server", "store", "train", "owner", "cache-dir", "output", "tracker"}
+
+type config struct {
+	Profile  string                       `yaml:"profile,omitempty"`
+	Profiles map[string]map[string]string `yaml:"profiles"`
+}
+
+// holoConfig is loaded once before any command runs
+var holoConfig = &config{}
+
+func loadConfigAction(c *cli.Context) error {
+	cfg, err := loadConfig(configPath(c))
+	if err != nil {
+		return sendCmdErr(err)
+	}
+	holoConfig = cfg
+	return nil
+}
+
+func configPath(c *cli.Context) string {
+	path := c.GlobalString("config")
+	if path == "" {
+		// the app's own context (used by app.Before) has no parent to look in
+		path = c.String("config")
+	}
+	if path != "" {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".config", "holo", "config")
+}
+
+func loadConfig(path string) (*config, error) {
+	cfg := &config{Profiles: map[string]map[string]string{}}
+	if path == "" {
+		return cfg, nil
+	}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return cfg, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	err = yaml.Unmarshal(data, cfg)
+	if err != nil {
+		return nil, errors.New("invalid config " + path + ": " + err.Error())
+	}
+	if cfg.Profiles == nil {
+		cfg.Profiles = map[string]map[string]string{}
+	}
+	return cfg, nil
+}
+
+func (cfg *config) save(path string) error {
+	data, err := yaml.Marshal(cfg)
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, data)
+}
+
+// profileName returns the name of the selected profile
+func profileName(c *cli.Context) string {
+	if c.GlobalString("profile") != "" {
+		return c.GlobalString("profile")
+	}
+	if holoConfig.Profile != "" {
+		return holoConfig.Profile
+	}
+	return "default"
+}
+
+// settingEnv returns the environment variable overriding a key
+func settingEnv(key string) string {
+	return "HOLO_" + strings.ToUpper(strings.Replace(key, "-", "_", -1))
+}
+
+// settingSource returns the effective value of a key and where it came from
+func settingSource(c *cli.Context, key string) (string, string) {
+	if c.GlobalIsSet(key) && c.GlobalString(key) != "" {
+		return c.GlobalString(key), "--" + key
+	}
+	if v := os.Getenv(settingEnv(key)); v != "" {
+		return v, "$" + settingEnv(key)
+	}
+	name := profileName(c)
+	if v := holoConfig.Profiles[name][key]; v != "" {
+		return v, "profile " + name
+	}
+	return "", ""
+}
+
+// setting returns the effective value of a key
+func setting(c *cli.Context, key string) string {
+	v, _ := settingSource(c, key)
+	return v
+}
+
+func checkSettingKey(key string) error {
+	for _, k := range settingKeys {
+		if k == key {
+			return nil
+		}
+	}
+	return errors.New("unknown key " + key + ", expected one of " + strings.Join(settingKeys, ", "))
+}
+
+// profileNames lists the profiles in the config, sorted
+func profileNames() []string {
+	names := []string{}
+	for name := range holoConfig.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
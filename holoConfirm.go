@@ -0,0 +1,156 @@
+// file: holoConfirm.go
+// purpose: shared confirmation for holo subCMDs that change build trains
+//          (submit, revert, releases cut, releases clone, trains create
+//          --base, components unlink)
+//
+//   - example output: This operation will affect the following build trains:
+//
+//                     - Avalanche: osd-37
+//                     - Thundercat: osd-37
+//
+//                     Really submit osd-38? (y/n)
+//
+//      - the impact is always printed; the question is skipped with -f/--force,
+//        asked on the terminal otherwise, and without a terminal the
+//        operation is refused
+
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/urfave/cli"
+)
+
+var errAborted = errors.New("aborted")
+
+// impact is the summary of what an operation is about to change
+type impact struct {
+	header string
+	items  []string
+}
+
+func (im *impact) print() {
+	fmt.Println(im.header)
+	fmt.Println()
+	if len(im.items) == 0 {
+		fmt.Println("- (none)")
+	}
+	for _, item := range im.items {
+		fmt.Println("-", item)
+	}
+	fmt.Println()
+}
+
+// confirm prints the impact and asks question unless --force is given
+func confirm(c *cli.Context, im *impact, question string) error {
+	im.print()
+	if c.Bool("force") {
+		return nil
+	}
+
+	// stdin may already be used for --notes -, so talk to the terminal directly
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return errors.New("no terminal to confirm on, use -f/--force to proceed")
+	}
+	defer tty.Close()
+
+	fmt.Fprint(tty, question, " (y/n) ")
+	answer, err := bufio.NewReader(tty).ReadString('\n')
+	if err != nil {
+		return errAborted
+	}
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	if answer != "y" && answer != "yes" {
+		return errAborted
+	}
+	return nil
+}
+
+// carriedSubmission returns the version of a component a train currently
+// carries: a pending submission if there is one, or else the one in the
+// latest release ("" if the train has never carried the component)
+func carriedSubmission(store Store, t *Train, component string) (string, error) {
+	for _, sub := range t.Pending {
+		comp, _, err := parseSubmissionName(sub)
+		if err == nil && comp == component {
+			return sub, nil
+		}
+	}
+	if t.LatestRelease == "" {
+		return "", nil
+	}
+	r, err := store.GetRelease(t.LatestRelease)
+	if err != nil {
+		return "", err
+	}
+	for _, sub := range r.Submissions {
+		comp, _, err := parseSubmissionName(sub)
+		if err == nil && comp == component {
+			return sub, nil
+		}
+	}
+	return "", nil
+}
+
+// trainsImpact lists the version of component carried by each train
+func trainsImpact(store Store, trains []string, component string) (*impact, error) {
+	im := &impact{header: "This operation will affect the following build trains:"}
+	for _, name := range trains {
+		t, err := store.GetTrain(name)
+		if err != nil {
+			return nil, err
+		}
+		carried, err := carriedSubmission(store, t, component)
+		if err != nil {
+			return nil, err
+		}
+		if carried == "" {
+			carried = "(none)"
+		}
+		im.items = append(im.items, t.Name+": "+carried)
+	}
+	return im, nil
+}
+
+// cutImpact lists the submissions a release brings in on top of its base,
+// and the reverts behind those that go back to an older version
+func cutImpact(r *Release, base []string, reverts []Revert) *impact {
+	im := &impact{header: "This operation will bring in the following submissions:"}
+	for _, sub := range r.Submissions {
+		if containsStr(base, sub) {
+			continue
+		}
+		item := sub
+		for _, rv := range reverts {
+			if rv.Submission == sub {
+				item += " (" + describeRevert(rv) + ")"
+			}
+		}
+		im.items = append(im.items, item)
+	}
+	for _, sub := range base {
+		if !containsStr(r.Submissions, sub) {
+			im.items = append(im.items, sub+" (removed, its component left the train)")
+		}
+	}
+	return im
+}
+
+// describeRevert explains a revert, e.g.
+// reverts osd-39, by steve@bowerswilkins.com: crashes on boot
+func describeRevert(rv Revert) string {
+	s := "reverts " + rv.Reverted
+	if rv.RevertedBy != "" {
+		s += ", by " + rv.RevertedBy
+	}
+	if rv.Reason != "" {
+		s += ": " + rv.Reason
+	}
+	return s
+}
@@ -0,0 +1,170 @@
+// file: holoDeps.go
+// purpose: component dependencies - each component declares the components
+//          it needs (osd on zlib, jetpack on bash); holo walks the graph to
+//          list dependencies and dependents, warns when a train would carry
+//          a component without what it needs, and exports the graph as
+//          DOT or JSON
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// depGraph maps every component onto the components it depends on
+type depGraph map[string][]string
+
+func loadDepGraph(store Store) (depGraph, error) {
+	comps, err := store.ListComponents()
+	if err != nil {
+		return nil, err
+	}
+	g := depGraph{}
+	for _, comp := range comps {
+		g[comp.Name] = comp.Deps
+	}
+	return g, nil
+}
+
+// reverse returns the graph of dependents
+func (g depGraph) reverse() depGraph {
+	r := depGraph{}
+	for comp := range g {
+		r[comp] = []string{}
+	}
+	for comp, deps := range g {
+		for _, dep := range deps {
+			r[dep] = append(r[dep], comp)
+		}
+	}
+	for comp := range r {
+		sort.Strings(r[comp])
+	}
+	return r
+}
+
+// depRecord is one component reached while walking the graph; Via is the
+// component it was reached from
+type depRecord struct {
+	Component string `json:"component" yaml:"component"`
+	Depth     int    `json:"depth" yaml:"depth"`
+	Via       string `json:"via" yaml:"via"`
+}
+
+// walk lists everything reachable from comp, depth first, each component
+// once
+func (g depGraph) walk(comp string) []depRecord {
+	records := []depRecord{}
+	seen := map[string]bool{comp: true}
+	var visit func(name string, depth int)
+	visit = func(name string, depth int) {
+		for _, dep := range g[name] {
+			if seen[dep] {
+				continue
+			}
+			seen[dep] = true
+			records = append(records, depRecord{Component: dep, Depth: depth, Via: name})
+			visit(dep, depth+1)
+		}
+	}
+	visit(comp, 1)
+	return records
+}
+
+// checkDeps makes sure new dependencies of a component exist and do not
+// lead back to it
+func (g depGraph) checkDeps(comp string, deps []string) error {
+	for _, dep := range deps {
+		if dep == comp {
+			return errors.New("component " + comp + " cannot depend on itself")
+		}
+		if _, ok := g[dep]; !ok {
+			return fmt.Errorf("dependency %s of %s: component %s %w", dep, comp, dep, errNotFound)
+		}
+	}
+	g[comp] = deps
+	for _, rec := range g.walk(comp) {
+		if rec.Component == comp || containsStr(g[rec.Component], comp) {
+			return errors.New("dependencies of " + comp + " would form a cycle through " + rec.Component)
+		}
+	}
+	return nil
+}
+
+// missingDeps lists the dependencies of comp, direct or not, that are not
+// among members
+func (g depGraph) missingDeps(comp string, members []string) []string {
+	missing := []string{}
+	for _, rec := range g.walk(comp) {
+		if !containsStr(members, rec.Component) {
+			missing = append(missing, rec.Component)
+		}
+	}
+	sort.Strings(missing)
+	return missing
+}
+
+// warnMissingDeps warns on stderr about each train that carries comp
+// without all of its dependencies
+func warnMissingDeps(store Store, comp string, trains []string) error {
+	g, err := loadDepGraph(store)
+	if err != nil {
+		return err
+	}
+	for _, name := range trains {
+		t, err := store.GetTrain(name)
+		if err != nil {
+			return err
+		}
+		missing := g.missingDeps(comp, append(t.Components, comp))
+		if len(missing) > 0 {
+			fmt.Fprintf(os.Stderr, "Warning: train %s carries %s without its dependencies: %s\n", t.Name, comp, strings.Join(missing, ", "))
+		}
+	}
+	return nil
+}
+
+type depEdge struct {
+	From string `json:"from" yaml:"from"`
+	To   string `json:"to" yaml:"to"`
+}
+
+type depExport struct {
+	Components []string  `json:"components" yaml:"components"`
+	Deps       []depEdge `json:"deps" yaml:"deps"`
+}
+
+// export lists the components and dependencies of the graph, limited to
+// members when given
+func (g depGraph) export(members []string) depExport {
+	ex := depExport{Components: []string{}, Deps: []depEdge{}}
+	for comp := range g {
+		if members == nil || containsStr(members, comp) {
+			ex.Components = append(ex.Components, comp)
+		}
+	}
+	sort.Strings(ex.Components)
+	for _, comp := range ex.Components {
+		for _, dep := range g[comp] {
+			ex.Deps = append(ex.Deps, depEdge{From: comp, To: dep})
+		}
+	}
+	return ex
+}
+
+// writeDOT renders an exported graph for graphviz
+func writeDOT(w io.Writer, ex depExport) {
+	fmt.Fprintln(w, "digraph holo {")
+	for _, comp := range ex.Components {
+		fmt.Fprintf(w, "  %q;\n", comp)
+	}
+	for _, e := range ex.Deps {
+		fmt.Fprintf(w, "  %q -> %q;\n", e.From, e.To)
+	}
+	fmt.Fprintln(w, "}")
+}
@@ -0,0 +1,188 @@
+// file: holoDiff.go
+// purpose: compare two releases - components added, removed or changed,
+//          the submissions in between and the artifacts that appeared or
+//          disappeared
+
+package main
+
+import (
+	"sort"
+	"strings"
+)
+
+type releaseDiff struct {
+	From       string            `json:"from" yaml:"from"`
+	To         string            `json:"to" yaml:"to"`
+	Components []componentChange `json:"components" yaml:"components"`
+	Artifacts  artifactChange    `json:"artifacts" yaml:"artifacts"`
+}
+
+// componentChange is one component that differs between two releases;
+// Change is added, removed, upgraded or downgraded
+type componentChange struct {
+	Component   string       `json:"component" yaml:"component"`
+	Change      string       `json:"change" yaml:"change"`
+	From        string       `json:"from,omitempty" yaml:"from,omitempty"`
+	To          string       `json:"to,omitempty" yaml:"to,omitempty"`
+	Submissions []Submission `json:"submissions" yaml:"submissions"`
+}
+
+type artifactChange struct {
+	Added   []string `json:"added" yaml:"added"`
+	Removed []string `json:"removed" yaml:"removed"`
+}
+
+// diffReleases compares release from with release to
+func diffReleases(store Store, from, to string) (*releaseDiff, error) {
+	a, err := store.GetRelease(from)
+	if err != nil {
+		return nil, err
+	}
+	b, err := store.GetRelease(to)
+	if err != nil {
+		return nil, err
+	}
+	d := &releaseDiff{From: a.Name, To: b.Name, Components: []componentChange{}}
+	onTrain, err := trainMember(store, b.Train)
+	if err != nil {
+		return nil, err
+	}
+	// a downgrade from a release of another train lists what it rolls back
+	keep := func(sub *Submission) bool {
+		return onTrain(sub) || containsStr(a.Submissions, sub.Name)
+	}
+
+	before, after := byComponent(a.Submissions), byComponent(b.Submissions)
+	names := []string{}
+	for comp := range before {
+		names = append(names, comp)
+	}
+	for comp := range after {
+		if _, ok := before[comp]; !ok {
+			names = append(names, comp)
+		}
+	}
+	sort.Strings(names)
+
+	for _, comp := range names {
+		ch := componentChange{Component: comp, From: before[comp], To: after[comp]}
+		switch {
+		case ch.From == ch.To:
+			continue
+		case ch.From == "":
+			ch.Change = "added"
+		case ch.To == "":
+			ch.Change = "removed"
+		case compareSubmissions(ch.From, ch.To) < 0:
+			ch.Change = "upgraded"
+		default:
+			ch.Change = "downgraded"
+		}
+		ch.Submissions, err = submissionsBetween(store, comp, ch.From, ch.To, keep)
+		if err != nil {
+			return nil, err
+		}
+		d.Components = append(d.Components, ch)
+	}
+
+	d.Artifacts, err = diffArtifacts(store, a.Name, b.Name)
+	if err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// byComponent indexes a list of submissions by their component
+func byComponent(subs []string) map[string]string {
+	m := map[string]string{}
+	for _, sub := range subs {
+		comp, _, err := parseSubmissionName(sub)
+		if err == nil {
+			m[comp] = sub
+		}
+	}
+	return m
+}
+
+// trainMember returns whether a submission belongs to a train: it was
+// sent to the train, or one of the releases of the train carries it
+func trainMember(store Store, train string) (func(sub *Submission) bool, error) {
+	releases, err := store.ListReleases(train)
+	if err != nil {
+		return nil, err
+	}
+	released := map[string]bool{}
+	for _, r := range releases {
+		for _, name := range r.Submissions {
+			released[name] = true
+		}
+	}
+	return func(sub *Submission) bool {
+		return released[sub.Name] || containsStr(sub.Trains, train)
+	}, nil
+}
+
+// submissionsBetween returns the submissions of a component that lie
+// between two versions and that keep accepts, ordered oldest first: for an
+// upgrade those after from up to and including to, for a downgrade those
+// that were rolled back
+func submissionsBetween(store Store, comp, from, to string, keep func(sub *Submission) bool) ([]Submission, error) {
+	subs, err := store.ListSubmissions(comp)
+	if err != nil {
+		return nil, err
+	}
+	lo, hi := from, to
+	if from != "" && to != "" && compareSubmissions(from, to) > 0 {
+		lo, hi = to, from
+	}
+	between := []Submission{}
+	for _, sub := range subs {
+		if !keep(&sub) {
+			continue
+		}
+		if lo != "" && compareSubmissions(sub.Name, lo) <= 0 {
+			continue
+		}
+		if hi != "" && compareSubmissions(sub.Name, hi) > 0 {
+			continue
+		}
+		between = append(between, sub)
+	}
+	sort.Slice(between, func(i, j int) bool {
+		return compareSubmissions(between[i].Name, between[j].Name) < 0
+	})
+	return between, nil
+}
+
+// diffArtifacts compares the artifacts of two releases; artifact names embed
+// their release, so Thundercat1A175_SDKRoot.txz and Thundercat1A177_SDKRoot.txz
+// count as the same artifact
+func diffArtifacts(store Store, from, to string) (artifactChange, error) {
+	ch := artifactChange{Added: []string{}, Removed: []string{}}
+	a, err := store.ListArtifacts(from)
+	if err != nil {
+		return ch, err
+	}
+	b, err := store.ListArtifacts(to)
+	if err != nil {
+		return ch, err
+	}
+	before, after := map[string]bool{}, map[string]bool{}
+	for _, art := range a {
+		before[strings.Replace(art.Name, from, "", -1)] = true
+	}
+	for _, art := range b {
+		after[strings.Replace(art.Name, to, "", -1)] = true
+	}
+	for _, art := range b {
+		if !before[strings.Replace(art.Name, to, "", -1)] {
+			ch.Added = append(ch.Added, art.Name)
+		}
+	}
+	for _, art := range a {
+		if !after[strings.Replace(art.Name, from, "", -1)] {
+			ch.Removed = append(ch.Removed, art.Name)
+		}
+	}
+	return ch, nil
+}
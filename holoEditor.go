@@ -0,0 +1,139 @@
+// file: holoEditor.go
+// purpose: write submission notes in $EDITOR - the notes template holds
+//          the sections of Notes, pre-filled with the subjects of the
+//          commits since the previous submission of the component
+//
+//      - the editor is $VISUAL, then $EDITOR, then vi
+//      - the instructions of the template start with "# holo:" and are
+//        dropped; other lines, such as #123 fixed, are kept; notes left
+//        empty abort
+//      - commit subjects mentioning a fix, bug or crash go under
+//        "Bugs fixed:", the others under "New features:"
+
+package main
+
+import (
+	"bufio"
+	"errors"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+)
+
+var (
+	errEmptyNotes = errors.New("aborting submission due to empty notes")
+	bugSubject    = regexp.MustCompile(`(?i)\b(fix(es|ed)?|bugs?|crash(es|er|ers)?)\b`)
+)
+
+// notesComment starts the lines of the template that are not notes
+const notesComment = "# holo:"
+
+// notesTemplate renders the notes to edit for a submission; prev is the
+// previous submission of the component, whose tag the commits are
+// listed from, or "" for the first one
+func notesTemplate(srcroot, name, prev string) string {
+	ns := Notes{}
+	if prev != "" {
+		// a tag missing from the work tree only means nothing is pre-filled
+		out, err := git(srcroot, "log", "--no-merges", "--format=%s", prev+"..HEAD")
+		if err == nil && out != "" {
+			for _, subject := range strings.Split(out, "\n") {
+				if bugSubject.MatchString(subject) {
+					ns.Bugs = append(ns.Bugs, subject)
+				} else {
+					ns.Features = append(ns.Features, subject)
+				}
+			}
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString("\n")
+	b.WriteString(notesComment + " Notes for " + name + ": a one line summary on the first line,\n")
+	b.WriteString(notesComment + " then the sections below. Lines starting with \"" + notesComment + "\" are\n")
+	b.WriteString(notesComment + " ignored, and empty notes abort the submission.\n")
+	if prev != "" {
+		b.WriteString(notesComment + " The sections list the commits since " + prev + ".\n")
+	}
+	b.WriteString("\nNew features:\n\n")
+	for _, line := range ns.Features {
+		b.WriteString(line + "\n")
+	}
+	b.WriteString("\nBugs fixed:\n\n")
+	for _, line := range ns.Bugs {
+		b.WriteString(line + "\n")
+	}
+	return b.String()
+}
+
+// editNotes opens the template in the editor and parses what is saved
+func editNotes(template string) (Notes, error) {
+	f, err := ioutil.TempFile("", "holo-notes-")
+	if err != nil {
+		return Notes{}, err
+	}
+	defer os.Remove(f.Name())
+	_, err = f.WriteString(template)
+	if err == nil {
+		err = f.Close()
+	}
+	if err != nil {
+		return Notes{}, err
+	}
+
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = "vi"
+	}
+	// through the shell, as the editor may come with arguments, e.g. code -w
+	cmd := exec.Command("sh", "-c", editor+` "$1"`, "sh", f.Name())
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	err = cmd.Run()
+	if err != nil {
+		return Notes{}, errors.New("editor " + editor + ": " + err.Error())
+	}
+
+	data, err := ioutil.ReadFile(f.Name())
+	if err != nil {
+		return Notes{}, err
+	}
+	ns := parseEditedNotes(string(data))
+	if ns.empty() {
+		return Notes{}, errEmptyNotes
+	}
+	return ns, nil
+}
+
+// parseEditedNotes parses a template saved by the editor, without the
+// lines of the template that are not notes
+func parseEditedNotes(text string) Notes {
+	lines := []string{}
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	for scanner.Scan() {
+		if !strings.HasPrefix(strings.TrimSpace(scanner.Text()), notesComment) {
+			lines = append(lines, scanner.Text())
+		}
+	}
+	return parseNotes(lines)
+}
+
+// previousSubmission returns the latest submission of a component older
+// than name, or "" when there is none
+func previousSubmission(store Store, component, name string) (string, error) {
+	subs, err := store.ListSubmissions(component)
+	if err != nil {
+		return "", err
+	}
+	prev := ""
+	for _, sub := range subs {
+		if compareSubmissions(sub.Name, name) < 0 && (prev == "" || compareSubmissions(sub.Name, prev) > 0) {
+			prev = sub.Name
+		}
+	}
+	return prev, nil
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"os/exec"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseEditedNotes(t *testing.T) {
+	template := notesTemplate(t.TempDir(), "osd-38", "")
+	tests := []struct {
+		desc string
+		edit func(string) string
+		want Notes
+	}{
+		{
+			"untouched",
+			func(s string) string { return s },
+			Notes{Features: []string{}, Bugs: []string{}, Other: []string{}},
+		},
+		{
+			"summary only",
+			func(s string) string { return "Better tiling" + s },
+			Notes{Summary: "Better tiling", Features: []string{}, Bugs: []string{}, Other: []string{}},
+		},
+		{
+			"sections",
+			func(s string) string {
+				s = "Better tiling" + s
+				s = strings.Replace(s, "New features:\n\n", "New features:\n\nTiling of 4K screens\n", 1)
+				return s + "Crash when unplugging VHAP-27\n"
+			},
+			Notes{
+				Summary:  "Better tiling",
+				Features: []string{"Tiling of 4K screens"},
+				Bugs:     []string{"Crash when unplugging VHAP-27"},
+				Other:    []string{},
+			},
+		},
+		{
+			"lines starting with # are notes",
+			func(s string) string {
+				s = "#123 fixed" + s
+				return s + "# the crash on boot\n#hashtag\n"
+			},
+			Notes{
+				Summary:  "#123 fixed",
+				Features: []string{},
+				Bugs:     []string{"# the crash on boot", "#hashtag"},
+				Other:    []string{},
+			},
+		},
+		{
+			"own comments",
+			func(s string) string {
+				return "Better tiling\n" + notesComment + " left out\n  " + notesComment + " indented\n" + s
+			},
+			Notes{Summary: "Better tiling", Features: []string{}, Bugs: []string{}, Other: []string{}},
+		},
+		{
+			"other lines",
+			func(s string) string { return "Better tiling\nSee the wiki\n" + s },
+			Notes{Summary: "Better tiling", Features: []string{}, Bugs: []string{}, Other: []string{"See the wiki"}},
+		},
+		{
+			"sections removed",
+			func(s string) string { return "Better tiling\n\nFaster boot\n" },
+			Notes{Summary: "Better tiling", Features: []string{}, Bugs: []string{}, Other: []string{"Faster boot"}},
+		},
+		{
+			"emptied",
+			func(s string) string { return "" },
+			Notes{Features: []string{}, Bugs: []string{}, Other: []string{}},
+		},
+	}
+	for _, tt := range tests {
+		got := parseEditedNotes(tt.edit(template))
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: parseEditedNotes = %+v, want %+v", tt.desc, got, tt.want)
+		}
+	}
+	if !parseEditedNotes(template).empty() {
+		t.Error("an untouched template is not empty")
+	}
+}
+
+func TestNotesTemplateCommits(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found")
+	}
+	dir := t.TempDir()
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"-c", "user.name=holo", "-c", "user.email=holo@example.com", "commit", "-q", "--allow-empty", "-m", "First"},
+		{"tag", "osd-37"},
+		{"-c", "user.name=holo", "-c", "user.email=holo@example.com", "commit", "-q", "--allow-empty", "-m", "Tiling of 4K screens"},
+		{"-c", "user.name=holo", "-c", "user.email=holo@example.com", "commit", "-q", "--allow-empty", "-m", "Fix the crash on boot"},
+	} {
+		_, err := git(dir, args...)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	template := notesTemplate(dir, "osd-38", "osd-37")
+	if !strings.Contains(template, notesComment+" The sections list the commits since osd-37.") {
+		t.Errorf("template does not name osd-37:\n%s", template)
+	}
+	got := parseEditedNotes("Better tiling" + template)
+	want := Notes{
+		Summary:  "Better tiling",
+		Features: []string{"Tiling of 4K screens"},
+		Bugs:     []string{"Fix the crash on boot"},
+		Other:    []string{},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseEditedNotes = %+v, want %+v", got, want)
+	}
+}
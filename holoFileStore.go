@@ -0,0 +1,1259 @@
+// file: holoFileStore.go
+// purpose: default on-disk implementation of the holo Store
+//
+//      - layout: ROOT/trains/TRAIN.json
+//                ROOT/releases/RELEASE.json
+//                ROOT/components/COMPONENT.json
+//                ROOT/submissions/SUBMISSION.json
+//                ROOT/sources/SUBMISSION.tar.gz
+//                ROOT/artifacts/RELEASE/meta/FILENAME.json
+//                ROOT/artifacts/RELEASE/data/FILENAME
+//                ROOT/artifacts/RELEASE/uploads/FILENAME (until committed)
+//                ROOT/locks/TRAIN.lock (while a train is being changed)
+//                ROOT/audit.log (one JSON AuditEntry per line, append only)
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+type fileStore struct {
+	root string
+	mu   sync.Mutex
+}
+
+func newFileStore(root string) (*fileStore, error) {
+	for _, kind := range []string{"trains", "releases", "components", "submissions", "sources", "artifacts"} {
+		err := os.MkdirAll(filepath.Join(root, kind), 0755)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &fileStore{root: root}, nil
+}
+
+// checkName rejects names that would escape the store directory
+func checkName(kind, name string) error {
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+		return fmt.Errorf("invalid %s name %q", kind, name)
+	}
+	return nil
+}
+
+func (s *fileStore) path(kind, name string) string {
+	return filepath.Join(s.root, kind+"s", name+".json")
+}
+
+func (s *fileStore) load(kind, name string, v interface{}) error {
+	err := checkName(kind, name)
+	if err != nil {
+		return err
+	}
+	data, err := ioutil.ReadFile(s.path(kind, name))
+	if os.IsNotExist(err) {
+		return fmt.Errorf("%s %s %w", kind, name, errNotFound)
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+func (s *fileStore) save(kind, name string, v interface{}) error {
+	err := checkName(kind, name)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(s.path(kind, name), data)
+}
+
+// create stores a new object; of two processes creating the same object
+// only one succeeds
+func (s *fileStore) create(kind, name string, v interface{}) error {
+	err := checkName(kind, name)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(s.path(kind, name)), ".tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Chmod(0644)
+	}
+	if err == nil {
+		err = tmp.Close()
+	} else {
+		tmp.Close()
+	}
+	if err != nil {
+		return err
+	}
+	err = os.Link(tmp.Name(), s.path(kind, name))
+	if os.IsExist(err) {
+		return fmt.Errorf("%s %s %w", kind, name, errExists)
+	}
+	return err
+}
+
+const (
+	// lockWait is how long to wait for a train lock held by someone else
+	lockWait = 10 * time.Second
+	// lockStale is the age after which a lock is considered abandoned
+	lockStale = 10 * time.Minute
+)
+
+// lockTrain takes the lock of a train, shared by every holo process using
+// the store, and returns the function that releases it
+func (s *fileStore) lockTrain(train string) (func(), error) {
+	err := checkName("train", train)
+	if err != nil {
+		return nil, err
+	}
+	path := filepath.Join(s.root, "locks", train+".lock")
+	err = os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return nil, err
+	}
+	host, _ := os.Hostname()
+	holder := fmt.Sprintf("pid %d on %s since %s", os.Getpid(), host, time.Now().Format(timeLayout))
+
+	deadline := time.Now().Add(lockWait)
+	for {
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if err == nil {
+			_, err = f.WriteString(holder)
+			f.Close()
+			if err != nil {
+				os.Remove(path)
+				return nil, err
+			}
+			return func() { os.Remove(path) }, nil
+		}
+		if !os.IsExist(err) {
+			return nil, err
+		}
+		info, err := os.Stat(path)
+		if err == nil && time.Since(info.ModTime()) > lockStale {
+			os.Remove(path)
+			continue
+		}
+		if time.Now().After(deadline) {
+			owner, _ := ioutil.ReadFile(path)
+			return nil, fmt.Errorf("train %s is %w by %s", train, errLocked, strings.TrimSpace(string(owner)))
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// updateTrain changes a train under its lock; the change is saved unless
+// change returns an error
+func (s *fileStore) updateTrain(train string, change func(t *Train) error) error {
+	unlock, err := s.lockTrain(train)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	var t Train
+	err = s.load("train", train, &t)
+	if err != nil {
+		return err
+	}
+	err = change(&t)
+	if err != nil {
+		return err
+	}
+	return s.save("train", train, &t)
+}
+
+// updateTrains changes several trains under their locks, all or none:
+// nothing is saved unless change succeeds on every train, and the trains
+// saved before a failed save are put back
+func (s *fileStore) updateTrains(trains []string, change func(t *Train) error) error {
+	// take the locks in order so that two commands cannot wait on each other
+	trains = append([]string{}, trains...)
+	sort.Strings(trains)
+	before := make([]Train, len(trains))
+	after := make([]Train, len(trains))
+	for i, train := range trains {
+		unlock, err := s.lockTrain(train)
+		if err != nil {
+			return err
+		}
+		defer unlock()
+		err = s.load("train", train, &before[i])
+		if err == nil {
+			err = s.load("train", train, &after[i])
+		}
+		if err == nil {
+			err = change(&after[i])
+		}
+		if err != nil {
+			return err
+		}
+	}
+	for i, train := range trains {
+		err := s.save("train", train, &after[i])
+		if err != nil {
+			for j := 0; j < i; j++ {
+				s.save("train", trains[j], &before[j])
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// names lists every stored object of a given kind, sorted
+func (s *fileStore) names(kind string) ([]string, error) {
+	files, err := ioutil.ReadDir(filepath.Join(s.root, kind+"s"))
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(files))
+	for _, f := range files {
+		if !f.IsDir() && strings.HasSuffix(f.Name(), ".json") {
+			names = append(names, strings.TrimSuffix(f.Name(), ".json"))
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// writeFileAtomic replaces a file so that readers never observe a partial write
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), ".tmp-")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Chmod(0644)
+	}
+	if err == nil {
+		err = tmp.Close()
+	} else {
+		tmp.Close()
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Trains
+
+func (s *fileStore) ListTrains() ([]Train, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	names, err := s.names("train")
+	if err != nil {
+		return nil, err
+	}
+	trains := make([]Train, 0, len(names))
+	for _, name := range names {
+		var t Train
+		err = s.load("train", name, &t)
+		if err != nil {
+			return nil, err
+		}
+		trains = append(trains, t)
+	}
+	return trains, nil
+}
+
+func (s *fileStore) GetTrain(name string) (*Train, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var t Train
+	err := s.load("train", name, &t)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func (s *fileStore) CreateTrain(t *Train) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if t.Created.IsZero() {
+		t.Created = time.Now()
+	}
+	if t.Components == nil {
+		t.Components = []string{}
+	}
+	if t.Pending == nil {
+		t.Pending = []string{}
+	}
+	return s.create("train", t.Name, t)
+}
+
+func (s *fileStore) QueueSubmission(submission string, trains []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var sub Submission
+	err := s.load("submission", submission, &sub)
+	if err != nil {
+		return err
+	}
+	return s.updateTrains(trains, func(t *Train) error {
+		s.queue(t, &sub)
+		return nil
+	})
+}
+
+// queue replaces the pending submission and revert of a component
+func (s *fileStore) queue(t *Train, sub *Submission) {
+	pending := []string{}
+	for _, name := range t.Pending {
+		var p Submission
+		if s.load("submission", name, &p) == nil && p.Component == sub.Component {
+			continue
+		}
+		pending = append(pending, name)
+	}
+	t.Pending = append(pending, sub.Name)
+	sortSubmissions(t.Pending)
+	reverts := []Revert{}
+	for _, rv := range t.Reverts {
+		comp, _, err := parseSubmissionName(rv.Submission)
+		if err == nil && comp != sub.Component {
+			reverts = append(reverts, rv)
+		}
+	}
+	t.Reverts = reverts
+}
+
+func (s *fileStore) RevertSubmission(reverts []*Revert) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	byTrain := map[string]*Revert{}
+	trains := []string{}
+	for _, rv := range reverts {
+		if byTrain[rv.Train] != nil {
+			return errors.New("train " + rv.Train + " is reverted twice")
+		}
+		byTrain[rv.Train] = rv
+		trains = append(trains, rv.Train)
+	}
+	return s.updateTrains(trains, func(t *Train) error {
+		rv := byTrain[t.Name]
+		var sub Submission
+		err := s.load("submission", rv.Submission, &sub)
+		if err != nil {
+			return err
+		}
+		s.queue(t, &sub)
+		if rv.Created.IsZero() {
+			rv.Created = time.Now()
+		}
+		t.Reverts = append(t.Reverts, *rv)
+		return nil
+	})
+}
+
+// Releases
+
+func (s *fileStore) ListReleases(train string) ([]Release, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var t Train
+	err := s.load("train", train, &t)
+	if err != nil {
+		return nil, err
+	}
+	return s.releases(train)
+}
+
+// releases lists the releases of a train, newest first
+func (s *fileStore) releases(train string) ([]Release, error) {
+	names, err := s.names("release")
+	if err != nil {
+		return nil, err
+	}
+	releases := []Release{}
+	for _, name := range names {
+		var r Release
+		err = s.load("release", name, &r)
+		if err != nil {
+			return nil, err
+		}
+		if r.Train == train {
+			r.State = releaseState(&r)
+			releases = append(releases, r)
+		}
+	}
+	sortReleases(releases)
+	return releases, nil
+}
+
+func (s *fileStore) GetRelease(name string) (*Release, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var r Release
+	err := s.load("release", name, &r)
+	if err != nil {
+		return nil, err
+	}
+	r.State = releaseState(&r)
+	return &r, nil
+}
+
+func (s *fileStore) CloneRelease(r *Release) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err := parseReleaseName(r.Train, r.Name)
+	if err != nil {
+		return err
+	}
+	unlock, err := s.lockTrain(r.Train)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	var t Train
+	err = s.load("train", r.Train, &t)
+	if err != nil {
+		return err
+	}
+	if r.Created.IsZero() {
+		r.Created = time.Now()
+	}
+	if r.Submissions == nil {
+		r.Submissions = []string{}
+	}
+	r.State = "cut"
+	sortSubmissions(r.Submissions)
+	err = s.create("release", r.Name, r)
+	if err != nil {
+		return err
+	}
+	releases, err := s.releases(t.Name)
+	if err != nil {
+		return err
+	}
+	if releases[0].Name == t.LatestRelease {
+		return nil
+	}
+	t.LatestRelease = releases[0].Name
+	return s.save("train", t.Name, &t)
+}
+
+func (s *fileStore) DeleteRelease(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var r Release
+	err := s.load("release", name, &r)
+	if err != nil {
+		return err
+	}
+	unlock, err := s.lockTrain(r.Train)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	var t Train
+	err = s.load("train", r.Train, &t)
+	if err != nil {
+		return err
+	}
+	links, err := s.artifactLinks(name, "")
+	if err != nil {
+		return err
+	}
+	if len(links) > 0 {
+		return errors.New("release " + name + " holds the data of artifacts linked from other releases: " + strings.Join(links, ", "))
+	}
+	err = os.RemoveAll(s.artifactDir(name, ""))
+	if err != nil {
+		return err
+	}
+	err = os.Remove(s.path("release", name))
+	if err != nil {
+		return err
+	}
+	if t.LatestRelease != name {
+		return nil
+	}
+	releases, err := s.releases(t.Name)
+	if err != nil {
+		return err
+	}
+	t.LatestRelease = ""
+	if len(releases) > 0 {
+		t.LatestRelease = releases[0].Name
+	}
+	return s.save("train", t.Name, &t)
+}
+
+func (s *fileStore) CutRelease(r *Release) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	unlock, err := s.lockTrain(r.Train)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	var t Train
+	err = s.load("train", r.Train, &t)
+	if err != nil {
+		return err
+	}
+	if r.Base == "" {
+		r.Base = t.LatestRelease
+	}
+	base := []string{}
+	if r.Base != "" {
+		var b Release
+		err = s.load("release", r.Base, &b)
+		if err != nil {
+			return err
+		}
+		base = b.Submissions
+	}
+	snapshot := trainSnapshot(&t, base)
+	if r.Submissions != nil && !sameStrs(r.Submissions, snapshot) {
+		return errors.New("the pending submissions of train " + t.Name + " changed while cutting " + r.Name + ", try again")
+	}
+	r.Submissions = snapshot
+	return s.createRelease(&t, r)
+}
+
+func (s *fileStore) PromoteRelease(name, state string) (*Release, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var r Release
+	err := s.load("release", name, &r)
+	if err != nil {
+		return nil, err
+	}
+	unlock, err := s.lockTrain(r.Train)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	err = checkTransition(&r, state)
+	if err != nil {
+		return nil, err
+	}
+	if state == "candidate" {
+		files, err := ioutil.ReadDir(s.artifactDir(name, "meta"))
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		err = checkCandidate(name, len(files))
+		if err != nil {
+			return nil, err
+		}
+	}
+	r.State = state
+	return &r, s.save("release", name, &r)
+}
+
+// createRelease stores a release of train t and flushes what it includes
+// from the pending submissions and reverts of t; the train lock is held
+func (s *fileStore) createRelease(t *Train, r *Release) error {
+	_, err := parseReleaseName(r.Train, r.Name)
+	if err != nil {
+		return err
+	}
+	if r.Created.IsZero() {
+		r.Created = time.Now()
+	}
+	if r.Submissions == nil {
+		r.Submissions = []string{}
+	}
+	r.State = "cut"
+	sortSubmissions(r.Submissions)
+	reverts := []Revert{}
+	for _, rv := range t.Reverts {
+		if containsStr(r.Submissions, rv.Submission) {
+			r.Reverts = append(r.Reverts, rv)
+		} else {
+			reverts = append(reverts, rv)
+		}
+	}
+	err = s.create("release", r.Name, r)
+	if err != nil {
+		return err
+	}
+	t.LatestRelease = r.Name
+	pending := []string{}
+	for _, name := range t.Pending {
+		if !containsStr(r.Submissions, name) {
+			pending = append(pending, name)
+		}
+	}
+	t.Pending = pending
+	t.Reverts = reverts
+	return s.save("train", t.Name, t)
+}
+
+// Components
+
+// membership fills in the trains a component is a member of
+func (s *fileStore) membership(comp *Component) error {
+	names, err := s.names("train")
+	if err != nil {
+		return err
+	}
+	comp.Trains = []string{}
+	for _, name := range names {
+		var t Train
+		err = s.load("train", name, &t)
+		if err != nil {
+			return err
+		}
+		if containsStr(t.Components, comp.Name) {
+			comp.Trains = append(comp.Trains, t.Name)
+		}
+	}
+	return nil
+}
+
+func (s *fileStore) ListComponents() ([]Component, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	names, err := s.names("component")
+	if err != nil {
+		return nil, err
+	}
+	comps := make([]Component, 0, len(names))
+	for _, name := range names {
+		var comp Component
+		err = s.load("component", name, &comp)
+		if err != nil {
+			return nil, err
+		}
+		err = s.membership(&comp)
+		if err != nil {
+			return nil, err
+		}
+		comps = append(comps, comp)
+	}
+	return comps, nil
+}
+
+func (s *fileStore) GetComponent(name string) (*Component, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var comp Component
+	err := s.load("component", name, &comp)
+	if err != nil {
+		return nil, err
+	}
+	err = s.membership(&comp)
+	if err != nil {
+		return nil, err
+	}
+	return &comp, nil
+}
+
+func (s *fileStore) CreateComponent(comp *Component) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if comp.Created.IsZero() {
+		comp.Created = time.Now()
+	}
+	// membership is derived from the trains, never stored on the component
+	stored := *comp
+	stored.Trains = nil
+	return s.create("component", comp.Name, &stored)
+}
+
+func (s *fileStore) UpdateComponent(comp *Component) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var stored Component
+	err := s.load("component", comp.Name, &stored)
+	if err != nil {
+		return err
+	}
+	stored.Owner, stored.Repo, stored.Deps = comp.Owner, comp.Repo, comp.Deps
+	err = s.save("component", stored.Name, &stored)
+	if err != nil {
+		return err
+	}
+	*comp = stored
+	return s.membership(comp)
+}
+
+func (s *fileStore) LinkComponent(component, train string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var comp Component
+	err := s.load("component", component, &comp)
+	if err != nil {
+		return err
+	}
+	return s.updateTrain(train, func(t *Train) error {
+		if containsStr(t.Components, component) {
+			return fmt.Errorf("component %s is already a member of train %s", component, train)
+		}
+		t.Components = append(t.Components, component)
+		sort.Strings(t.Components)
+		return nil
+	})
+}
+
+func (s *fileStore) UnlinkComponent(component, train string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.updateTrain(train, func(t *Train) error {
+		if !containsStr(t.Components, component) {
+			return fmt.Errorf("component %s is not a member of train %s", component, train)
+		}
+		t.Components = removeStr(t.Components, component)
+		// what is queued for the component would never be cut
+		pending := []string{}
+		for _, name := range t.Pending {
+			comp, _, err := parseSubmissionName(name)
+			if err != nil || comp != component {
+				pending = append(pending, name)
+			}
+		}
+		t.Pending = pending
+		reverts := []Revert{}
+		for _, rv := range t.Reverts {
+			comp, _, err := parseSubmissionName(rv.Submission)
+			if err != nil || comp != component {
+				reverts = append(reverts, rv)
+			}
+		}
+		t.Reverts = reverts
+		return nil
+	})
+}
+
+// Submissions
+
+func (s *fileStore) ListSubmissions(component string) ([]Submission, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	names, err := s.names("submission")
+	if err != nil {
+		return nil, err
+	}
+	subs := []Submission{}
+	for _, name := range names {
+		var sub Submission
+		err = s.load("submission", name, &sub)
+		if err != nil {
+			return nil, err
+		}
+		if component == "" || sub.Component == component {
+			subs = append(subs, sub)
+		}
+	}
+	sort.SliceStable(subs, func(i, j int) bool {
+		return lessSubmission(subs[i].Name, subs[j].Name)
+	})
+	return subs, nil
+}
+
+func (s *fileStore) GetSubmission(name string) (*Submission, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var sub Submission
+	err := s.load("submission", name, &sub)
+	if err != nil {
+		return nil, err
+	}
+	return &sub, nil
+}
+
+func (s *fileStore) CreateSubmission(sub *Submission) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var comp Component
+	err := s.load("component", sub.Component, &comp)
+	if err != nil {
+		return err
+	}
+	id, err := parseSubmissionID(sub.Name)
+	if err != nil {
+		return err
+	}
+	if id.Component != sub.Component {
+		return errors.New("submission " + sub.Name + " does not belong to component " + sub.Component)
+	}
+	if sub.Created.IsZero() {
+		sub.Created = time.Now()
+	}
+	if sub.Notes.Features == nil {
+		sub.Notes.Features = []string{}
+	}
+	if sub.Notes.Bugs == nil {
+		sub.Notes.Bugs = []string{}
+	}
+	if sub.Notes.Other == nil {
+		sub.Notes.Other = []string{}
+	}
+	if sub.Trains == nil {
+		sub.Trains = []string{}
+	}
+	return s.create("submission", sub.Name, sub)
+}
+
+func (s *fileStore) DeleteSubmission(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	err := s.load("submission", name, &Submission{})
+	if err != nil {
+		return err
+	}
+	releases, err := s.names("release")
+	if err != nil {
+		return err
+	}
+	for _, release := range releases {
+		var r Release
+		err = s.load("release", release, &r)
+		if err != nil {
+			return err
+		}
+		if containsStr(r.Submissions, name) {
+			return errors.New("submission " + name + " is shipped in release " + r.Name)
+		}
+	}
+	trains, err := s.names("train")
+	if err != nil {
+		return err
+	}
+	for _, train := range trains {
+		var t Train
+		err = s.load("train", train, &t)
+		if err != nil {
+			return err
+		}
+		if !containsStr(t.Pending, name) {
+			continue
+		}
+		err = s.updateTrain(train, func(t *Train) error {
+			t.Pending = removeStr(t.Pending, name)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	err = os.Remove(s.sourcesPath(name))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return os.Remove(s.path("submission", name))
+}
+
+func (s *fileStore) sourcesPath(submission string) string {
+	return filepath.Join(s.root, "sources", submission+".tar.gz")
+}
+
+func (s *fileStore) PutSources(submission string, r io.Reader) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var sub Submission
+	err := s.load("submission", submission, &sub)
+	if err != nil {
+		return err
+	}
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(s.sourcesPath(submission), data)
+}
+
+func (s *fileStore) GetSources(submission string) (io.ReadCloser, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	err := checkName("submission", submission)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(s.sourcesPath(submission))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("sources of submission %s %w", submission, errNotFound)
+	}
+	return f, err
+}
+
+// Artifacts
+
+func (s *fileStore) artifactDir(release, sub string) string {
+	return filepath.Join(s.root, "artifacts", release, sub)
+}
+
+func (s *fileStore) ListArtifacts(release string) ([]Artifact, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var r Release
+	err := s.load("release", release, &r)
+	if err != nil {
+		return nil, err
+	}
+	files, err := ioutil.ReadDir(s.artifactDir(release, "meta"))
+	if os.IsNotExist(err) {
+		return []Artifact{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	artifacts := make([]Artifact, 0, len(files))
+	for _, f := range files {
+		if !strings.HasSuffix(f.Name(), ".json") {
+			continue
+		}
+		a, err := s.statArtifact(release, strings.TrimSuffix(f.Name(), ".json"))
+		if err != nil {
+			return nil, err
+		}
+		artifacts = append(artifacts, *a)
+	}
+	return artifacts, nil
+}
+
+// checkArtifactNames rejects release and artifact names that would escape
+// the store directory; the server hands them over straight from the URL
+func checkArtifactNames(release, name string) error {
+	err := checkName("release", release)
+	if err != nil {
+		return err
+	}
+	return checkName("artifact", name)
+}
+
+func (s *fileStore) statArtifact(release, name string) (*Artifact, error) {
+	err := checkArtifactNames(release, name)
+	if err != nil {
+		return nil, err
+	}
+	data, err := ioutil.ReadFile(filepath.Join(s.artifactDir(release, "meta"), name+".json"))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("artifact %s in release %s %w", name, release, errNotFound)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var a Artifact
+	err = json.Unmarshal(data, &a)
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// artifactLinks lists, as RELEASE/FILENAME, the artifacts whose Source is
+// the artifact name of release; when name is empty, the artifacts of other
+// releases whose Source is any artifact of release
+func (s *fileStore) artifactLinks(release, name string) ([]string, error) {
+	dirs, err := ioutil.ReadDir(filepath.Join(s.root, "artifacts"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var links []string
+	for _, dir := range dirs {
+		if name == "" && dir.Name() == release {
+			continue
+		}
+		files, err := ioutil.ReadDir(s.artifactDir(dir.Name(), "meta"))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range files {
+			if !strings.HasSuffix(f.Name(), ".json") {
+				continue
+			}
+			a, err := s.statArtifact(dir.Name(), strings.TrimSuffix(f.Name(), ".json"))
+			if err != nil {
+				return nil, err
+			}
+			if a.Source == release+"/"+name || name == "" && strings.HasPrefix(a.Source, release+"/") {
+				links = append(links, a.Release+"/"+a.Name)
+			}
+		}
+	}
+	return links, nil
+}
+
+func (s *fileStore) StatArtifact(release, name string) (*Artifact, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.statArtifact(release, name)
+}
+
+func (s *fileStore) OpenArtifact(release, name string, offset int64) (io.ReadCloser, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	a, err := s.statArtifact(release, name)
+	if err != nil {
+		return nil, err
+	}
+	if a.Source != "" {
+		release, name = path.Split(a.Source)
+		release = strings.TrimSuffix(release, "/")
+		err = checkArtifactNames(release, name)
+		if err != nil {
+			return nil, err
+		}
+	}
+	f, err := os.Open(filepath.Join(s.artifactDir(release, "data"), name))
+	if err != nil {
+		return nil, err
+	}
+	_, err = f.Seek(offset, io.SeekStart)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
+// uploadPath is where an upload is kept until it is committed
+func (s *fileStore) uploadPath(release, name string) (string, error) {
+	var r Release
+	err := s.load("release", release, &r)
+	if err != nil {
+		return "", err
+	}
+	err = checkName("artifact", name)
+	if err != nil {
+		return "", err
+	}
+	_, err = parseArtifactName(release, name)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(s.artifactDir(release, "uploads"), name), nil
+}
+
+func (s *fileStore) UploadOffset(release, name string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	path, err := s.uploadPath(release, name)
+	if err != nil {
+		return 0, err
+	}
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
+
+func (s *fileStore) UploadChunk(release, name string, offset int64, r io.Reader) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	path, err := s.uploadPath(release, name)
+	if err != nil {
+		return 0, err
+	}
+	err = os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return 0, err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE, 0644)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	size, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return 0, err
+	}
+	if size != offset {
+		return size, fmt.Errorf("upload of %s is at offset %d, not %d", name, size, offset)
+	}
+	n, err := io.Copy(f, r)
+	return offset + n, err
+}
+
+func (s *fileStore) CommitUpload(a *Artifact, force bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	path, err := s.uploadPath(a.Release, a.Name)
+	if err != nil {
+		return err
+	}
+	_, err = s.statArtifact(a.Release, a.Name)
+	if err == nil && !force {
+		return fmt.Errorf("artifact %s in release %s %w", a.Name, a.Release, errExists)
+	}
+	if err == nil {
+		links, err := s.artifactLinks(a.Release, a.Name)
+		if err != nil {
+			return err
+		}
+		if len(links) > 0 {
+			return errors.New("artifact " + a.Name + " in release " + a.Release + " cannot be replaced, it is linked from " + strings.Join(links, ", "))
+		}
+	}
+
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return fmt.Errorf("upload of %s %w", a.Name, errNotFound)
+	}
+	if err != nil {
+		return err
+	}
+	hash := sha256.New()
+	size, err := io.Copy(hash, f)
+	f.Close()
+	if err != nil {
+		return err
+	}
+	sum := hex.EncodeToString(hash.Sum(nil))
+	if size != a.Size || sum != a.SHA256 {
+		// a corrupt upload can never be completed, start over next time
+		os.Remove(path)
+		return fmt.Errorf("upload of %s is corrupt: got %d bytes with SHA-256 %s", a.Name, size, sum)
+	}
+
+	for _, sub := range []string{"meta", "data"} {
+		err = os.MkdirAll(s.artifactDir(a.Release, sub), 0755)
+		if err != nil {
+			return err
+		}
+	}
+	err = os.Rename(path, filepath.Join(s.artifactDir(a.Release, "data"), a.Name))
+	if err != nil {
+		return err
+	}
+	a.Created = time.Now()
+	data, err := json.MarshalIndent(a, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(s.artifactDir(a.Release, "meta"), a.Name+".json"), data)
+}
+
+func (s *fileStore) LinkArtifact(a *Artifact) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var r Release
+	err := s.load("release", a.Release, &r)
+	if err != nil {
+		return err
+	}
+	err = checkName("artifact", a.Name)
+	if err != nil {
+		return err
+	}
+	_, err = parseArtifactName(a.Release, a.Name)
+	if err != nil {
+		return err
+	}
+	_, err = s.statArtifact(a.Release, a.Name)
+	if err == nil {
+		return fmt.Errorf("artifact %s in release %s %w", a.Name, a.Release, errExists)
+	}
+
+	release, name := path.Split(a.Source)
+	src, err := s.statArtifact(strings.TrimSuffix(release, "/"), name)
+	if err != nil {
+		return err
+	}
+	a.Source = src.Release + "/" + src.Name
+	if src.Source != "" {
+		// always refer to the artifact holding the data
+		a.Source = src.Source
+	}
+	a.Size, a.SHA256 = src.Size, src.SHA256
+	a.Created = time.Now()
+
+	err = os.MkdirAll(s.artifactDir(a.Release, "meta"), 0755)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(a, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(s.artifactDir(a.Release, "meta"), a.Name+".json"), data)
+}
+
+// Audit log
+
+func (s *fileStore) AppendAudit(e *AuditEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	// a single write in append mode keeps lines from concurrent holo
+	// processes whole
+	f, err := os.OpenFile(filepath.Join(s.root, "audit.log"), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(append(data, '\n'))
+	if err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func (s *fileStore) ListAudit(q auditQuery) ([]AuditEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entries := []AuditEntry{}
+	data, err := ioutil.ReadFile(filepath.Join(s.root, "audit.log"))
+	if os.IsNotExist(err) {
+		return entries, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	for i, line := range strings.Split(string(data), "\n") {
+		if line == "" {
+			continue
+		}
+		var e AuditEntry
+		err = json.Unmarshal([]byte(line), &e)
+		if err != nil {
+			return nil, fmt.Errorf("invalid audit log entry on line %d: %v", i+1, err)
+		}
+		if q.match(&e) {
+			entries = append(entries, e)
+		}
+	}
+	return entries, nil
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestStore returns an empty store in a temporary directory
+func newTestStore(t *testing.T) *fileStore {
+	t.Helper()
+	store, err := newFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
+
+func must(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// addTrain creates a train with components linked to it, creating the
+// components that do not exist yet
+func addTrain(t *testing.T, store Store, train string, components ...string) {
+	t.Helper()
+	must(t, store.CreateTrain(&Train{Name: train}))
+	for _, comp := range components {
+		_, err := store.GetComponent(comp)
+		if err != nil {
+			must(t, store.CreateComponent(&Component{Name: comp, Owner: "steve@bowerswilkins.com"}))
+		}
+		must(t, store.LinkComponent(comp, train))
+	}
+}
+
+// addSubmission creates a submission sent to trains, without queueing it
+func addSubmission(t *testing.T, store Store, name string, notes Notes, trains ...string) {
+	t.Helper()
+	comp, _, err := parseSubmissionName(name)
+	must(t, err)
+	must(t, store.CreateSubmission(&Submission{Name: name, Component: comp, Notes: notes, Trains: trains}))
+}
+
+// addArtifact uploads data as an artifact of release
+func addArtifact(t *testing.T, store Store, release, name, data string) *Artifact {
+	t.Helper()
+	a := addArtifactData(t, store, release, name, data)
+	must(t, store.CommitUpload(a, false))
+	return a
+}
+
+// addArtifactData uploads data for an artifact of release without
+// committing it
+func addArtifactData(t *testing.T, store Store, release, name, data string) *Artifact {
+	t.Helper()
+	_, err := store.UploadChunk(release, name, 0, strings.NewReader(data))
+	must(t, err)
+	sum := sha256.Sum256([]byte(data))
+	return &Artifact{Name: name, Release: release, Size: int64(len(data)), SHA256: hex.EncodeToString(sum[:])}
+}
+
+func TestArtifactLinks(t *testing.T) {
+	store := newTestStore(t)
+	addTrain(t, store, "T")
+	must(t, store.CloneRelease(&Release{Name: "T1A1", Train: "T"}))
+	must(t, store.CloneRelease(&Release{Name: "T1A2", Train: "T", Base: "T1A1"}))
+	src := addArtifact(t, store, "T1A1", "Cake1,1_DVT2_1.0_T1A1_USBImage.img", "first")
+	link := &Artifact{Name: "Cake1,1_DVT2_1.0_T1A2_USBImage.img", Release: "T1A2", Source: "T1A1/" + src.Name}
+	must(t, store.LinkArtifact(link))
+
+	err := store.DeleteRelease("T1A1")
+	if err == nil || !strings.Contains(err.Error(), "T1A2/"+link.Name) {
+		t.Errorf("DeleteRelease of a linked release = %v, want an error naming the link", err)
+	}
+	replaced := addArtifactData(t, store, "T1A1", src.Name, "second")
+	err = store.CommitUpload(replaced, true)
+	if err == nil || !strings.Contains(err.Error(), "T1A2/"+link.Name) {
+		t.Errorf("CommitUpload of a linked artifact = %v, want an error naming the link", err)
+	}
+	a, err := store.StatArtifact("T1A2", link.Name)
+	must(t, err)
+	if a.SHA256 != src.SHA256 {
+		t.Error("the link no longer matches its source")
+	}
+
+	// the link itself holds no data others refer to
+	must(t, store.CommitUpload(addArtifactData(t, store, "T1A2", link.Name, "second"), true))
+	must(t, store.DeleteRelease("T1A1"))
+	if _, err := store.GetRelease("T1A1"); err == nil {
+		t.Error("T1A1 is still there")
+	}
+}
+
+func TestDeleteSubmission(t *testing.T) {
+	store := newTestStore(t)
+	addTrain(t, store, "T", "osd")
+	addTrain(t, store, "Other", "osd")
+	addSubmission(t, store, "osd-1", Notes{}, "T")
+	addSubmission(t, store, "osd-2", Notes{}, "T", "Other")
+	must(t, store.CloneRelease(&Release{Name: "Other1A1", Train: "Other", Submissions: []string{"osd-1"}}))
+	must(t, store.QueueSubmission("osd-2", []string{"T", "Other"}))
+
+	err := store.DeleteSubmission("osd-1")
+	if err == nil || !strings.Contains(err.Error(), "Other1A1") {
+		t.Errorf("DeleteSubmission of a shipped submission = %v, want an error naming the release", err)
+	}
+	if _, err := store.GetSubmission("osd-1"); err != nil {
+		t.Errorf("the shipped submission is gone: %v", err)
+	}
+
+	must(t, store.DeleteSubmission("osd-2"))
+	for _, train := range []string{"T", "Other"} {
+		tr, err := store.GetTrain(train)
+		must(t, err)
+		if len(tr.Pending) != 0 {
+			t.Errorf("train %s still has %q pending", train, tr.Pending)
+		}
+	}
+	if err := store.DeleteSubmission("osd-2"); !errors.Is(err, errNotFound) {
+		t.Errorf("DeleteSubmission of a deleted submission = %v, want not found", err)
+	}
+}
+
+func TestCutRelease(t *testing.T) {
+	store := newTestStore(t)
+	addTrain(t, store, "T", "osd", "bash")
+	addSubmission(t, store, "osd-1", Notes{}, "T")
+	addSubmission(t, store, "bash-1", Notes{}, "T")
+	must(t, store.QueueSubmission("osd-1", []string{"T"}))
+	must(t, store.QueueSubmission("bash-1", []string{"T"}))
+
+	r := &Release{Name: "T1A1", Train: "T"}
+	must(t, store.CutRelease(r))
+	if want := []string{"bash-1", "osd-1"}; !reflect.DeepEqual(r.Submissions, want) {
+		t.Errorf("T1A1 carries %q, want %q", r.Submissions, want)
+	}
+	tr, err := store.GetTrain("T")
+	must(t, err)
+	if tr.LatestRelease != "T1A1" || len(tr.Pending) != 0 {
+		t.Errorf("after the cut the train has latest release %s and pending %q, want T1A1 and nothing", tr.LatestRelease, tr.Pending)
+	}
+
+	// what was confirmed has to be what gets cut
+	addSubmission(t, store, "osd-2", Notes{}, "T")
+	must(t, store.QueueSubmission("osd-2", []string{"T"}))
+	err = store.CutRelease(&Release{Name: "T1A2", Train: "T", Submissions: []string{"bash-1", "osd-1"}})
+	if err == nil {
+		t.Error("CutRelease with stale submissions succeeded")
+	}
+	if _, err := store.GetRelease("T1A2"); !errors.Is(err, errNotFound) {
+		t.Errorf("a failed cut left T1A2 behind: %v", err)
+	}
+
+	// unlinked components leave the cut, even when the base carries them
+	must(t, store.UnlinkComponent("bash", "T"))
+	r = &Release{Name: "T1A2", Train: "T", Submissions: []string{"osd-2"}}
+	must(t, store.CutRelease(r))
+	if r.Base != "T1A1" {
+		t.Errorf("T1A2 is based on %q, want the latest release T1A1", r.Base)
+	}
+	got, err := store.GetRelease("T1A2")
+	must(t, err)
+	if want := []string{"osd-2"}; !reflect.DeepEqual(got.Submissions, want) || got.State != "cut" {
+		t.Errorf("T1A2 is %s carrying %q, want cut carrying %q", got.State, got.Submissions, want)
+	}
+}
+
+func TestCutReleaseStaleLock(t *testing.T) {
+	store := newTestStore(t)
+	addTrain(t, store, "T", "osd")
+	lock := filepath.Join(store.root, "locks", "T.lock")
+	must(t, os.MkdirAll(filepath.Dir(lock), 0755))
+	must(t, ioutil.WriteFile(lock, []byte("pid 1 on gone"), 0644))
+	old := time.Now().Add(-2 * lockStale)
+	must(t, os.Chtimes(lock, old, old))
+
+	must(t, store.CutRelease(&Release{Name: "T1A1", Train: "T"}))
+	if _, err := os.Stat(lock); !os.IsNotExist(err) {
+		t.Error("the lock is still held after the cut")
+	}
+}
+
+func TestQueueSubmission(t *testing.T) {
+	store := newTestStore(t)
+	addTrain(t, store, "T", "osd", "bash")
+	addTrain(t, store, "Other", "osd")
+	addSubmission(t, store, "osd-1", Notes{}, "T", "Other")
+	addSubmission(t, store, "osd-2", Notes{}, "T", "Other")
+	addSubmission(t, store, "bash-1", Notes{}, "T")
+
+	// all trains or none of them
+	err := store.QueueSubmission("osd-1", []string{"T", "Gone", "Other"})
+	if err == nil {
+		t.Error("queueing to a train that does not exist succeeded")
+	}
+	for _, train := range []string{"T", "Other"} {
+		tr, err := store.GetTrain(train)
+		must(t, err)
+		if len(tr.Pending) != 0 {
+			t.Errorf("a failed queue left %q pending on %s", tr.Pending, train)
+		}
+	}
+
+	// a newer submission replaces the pending one of its component
+	must(t, store.QueueSubmission("osd-1", []string{"T", "Other"}))
+	must(t, store.QueueSubmission("bash-1", []string{"T"}))
+	must(t, store.QueueSubmission("osd-2", []string{"T"}))
+	tr, err := store.GetTrain("T")
+	must(t, err)
+	if want := []string{"bash-1", "osd-2"}; !reflect.DeepEqual(tr.Pending, want) {
+		t.Errorf("train T has %q pending, want %q", tr.Pending, want)
+	}
+	tr, err = store.GetTrain("Other")
+	must(t, err)
+	if want := []string{"osd-1"}; !reflect.DeepEqual(tr.Pending, want) {
+		t.Errorf("train Other has %q pending, want %q", tr.Pending, want)
+	}
+}
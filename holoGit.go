@@ -0,0 +1,55 @@
+// file: holoGit.go
+// purpose: git helpers for holo cli - tagging, pushing and archiving
+//          the source tree of a submission
+
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"os/exec"
+	"strings"
+)
+
+// git runs a git command in dir and returns its trimmed output
+func git(dir string, args ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	if err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = err.Error()
+		}
+		return "", errors.New("git " + args[0] + ": " + msg)
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+// checkGitWorkTree makes sure dir is inside a git work tree
+func checkGitWorkTree(dir string) error {
+	out, err := git(dir, "rev-parse", "--is-inside-work-tree")
+	if err != nil || out != "true" {
+		return errors.New(dir + " is not a git work tree")
+	}
+	return nil
+}
+
+// gitArchive writes a gzipped tarball of HEAD to w; the output only depends
+// on the commit, so archiving the same HEAD twice gives identical bytes
+func gitArchive(dir, prefix string, w io.Writer) error {
+	zw := gzip.NewWriter(w)
+	var stderr bytes.Buffer
+	cmd := exec.Command("git", "-C", dir, "archive", "--format=tar", "--prefix="+prefix+"/", "HEAD")
+	cmd.Stdout = zw
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	if err != nil {
+		return errors.New("git archive: " + strings.TrimSpace(stderr.String()))
+	}
+	return zw.Close()
+}
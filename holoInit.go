@@ -10,13 +10,25 @@ func setCmdList() []cli.Command {
 
 	cmdList := make([]cli.Command, 0)
 	cmdList = append(cmdList, setNotesCmd())
+	cmdList = append(cmdList, setIssuesCmd())
+	cmdList = append(cmdList, setLogCmd())
 	cmdList = append(cmdList, setRevertCmd())
+	cmdList = append(cmdList, setBisectCmd())
 	cmdList = append(cmdList, setSubmitCmd())
 	cmdList = append(cmdList, setWhichCmd())
 	cmdList = append(cmdList, setArtifactsCmd())
 	cmdList = append(cmdList, setComponentsCmd())
 	cmdList = append(cmdList, setReleasesCmd())
 	cmdList = append(cmdList, setTrainsCmd())
+	cmdList = append(cmdList, setServeCmd())
+	cmdList = append(cmdList, setConfigCmd())
+	cmdList = append(cmdList, setLoginCmd())
+	cmdList = append(cmdList, setLogoutCmd())
+	cmdList = append(cmdList, setWhoamiCmd())
+	cmdList = append(cmdList, setPlanCmd())
+	cmdList = append(cmdList, setApplyCmd())
+	cmdList = append(cmdList, setCompletionCmd())
+	cmdList = append(cmdList, setCompleteCmd())
 
 	return cmdList
 }
@@ -0,0 +1,223 @@
+// file: holoIssues.go
+// purpose: issue keys (e.g. VHAP-27) - taken from the notes of a
+//          submission and the commit messages it brings in, stored on the
+//          submission, and looked up in an issue tracker
+//
+//      - tracker setting: KIND:LOCATION, e.g. file:/home/steve/issues; the
+//        kinds are the keys of trackerClients
+//      - the file tracker stands in for a real one: one KEY.json per issue
+//
+//              {"key": "VHAP-27", "title": "Better tiling", "status": "open",
+//               "comments": ["fixed in Thundercat1A177"]}
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/urfave/cli"
+)
+
+// Issue is an issue as the tracker knows it
+type Issue struct {
+	Key      string   `json:"key" yaml:"key"`
+	Title    string   `json:"title" yaml:"title"`
+	Status   string   `json:"status" yaml:"status"`
+	Comments []string `json:"comments" yaml:"comments"`
+}
+
+// shippedIssue is an issue brought in by a submission of a release
+type shippedIssue struct {
+	Key        string `json:"key" yaml:"key"`
+	Release    string `json:"release,omitempty" yaml:"release,omitempty"`
+	Component  string `json:"component" yaml:"component"`
+	Submission string `json:"submission" yaml:"submission"`
+	Title      string `json:"title,omitempty" yaml:"title,omitempty"`
+	Status     string `json:"status,omitempty" yaml:"status,omitempty"`
+}
+
+// Tracker is an issue tracker holo reads issues from and comments on
+type Tracker interface {
+	GetIssue(key string) (*Issue, error)
+	AddComment(key, comment string) error
+}
+
+// trackerClients open a tracker from the location in the tracker setting
+var trackerClients = map[string]func(location string) (Tracker, error){
+	"file": newFileTracker,
+}
+
+// openTracker opens the tracker of the tracker setting, or returns nil
+// when there is none
+func openTracker(c *cli.Context) (Tracker, error) {
+	v := setting(c, "tracker")
+	if v == "" {
+		return nil, nil
+	}
+	parts := strings.SplitN(v, ":", 2)
+	open, ok := trackerClients[parts[0]]
+	if !ok || len(parts) < 2 || parts[1] == "" {
+		kinds := []string{}
+		for kind := range trackerClients {
+			kinds = append(kinds, kind+":LOCATION")
+		}
+		sort.Strings(kinds)
+		return nil, errors.New("invalid tracker " + v + ", expected " + orList(kinds))
+	}
+	return open(parts[1])
+}
+
+// issueKeys lists the issue keys found in lines, in order and once each
+func issueKeys(lines []string) []string {
+	keys := []string{}
+	for _, line := range lines {
+		for _, key := range issuePattern.FindAllString(line, -1) {
+			if !containsStr(keys, key) {
+				keys = append(keys, key)
+			}
+		}
+	}
+	return keys
+}
+
+// commitIssues lists the issue keys in the messages of the commits since
+// the tag of the previous submission, or in the whole history of HEAD for
+// the first one
+func commitIssues(srcroot, prev string) ([]string, error) {
+	rev := "HEAD"
+	if prev != "" {
+		rev = prev + "..HEAD"
+	}
+	out, err := git(srcroot, "log", "--no-merges", "--format=%B", rev)
+	if err != nil {
+		return nil, err
+	}
+	return issueKeys(strings.Split(out, "\n")), nil
+}
+
+// submissionIssues returns the issue keys of a submission; submissions
+// made before keys were stored fall back on their notes
+func submissionIssues(sub *Submission) []string {
+	if sub.Issues != nil {
+		return sub.Issues
+	}
+	return issueKeys(sub.Notes.lines())
+}
+
+// releaseIssues lists the issues a release brings in since another
+// release (default: its base), oldest submission first
+func releaseIssues(store Store, release, since string) ([]shippedIssue, error) {
+	r, err := store.GetRelease(release)
+	if err != nil {
+		return nil, err
+	}
+	if since == "" {
+		since = r.Base
+	}
+	changes, err := releaseChanges(store, r, since)
+	if err != nil {
+		return nil, err
+	}
+	issues := []shippedIssue{}
+	seen := map[string]bool{}
+	for _, ch := range changes {
+		if ch.Change != "added" && ch.Change != "upgraded" {
+			continue
+		}
+		for i := range ch.Submissions {
+			sub := &ch.Submissions[i]
+			for _, key := range submissionIssues(sub) {
+				if seen[key] {
+					continue
+				}
+				seen[key] = true
+				issues = append(issues, shippedIssue{Key: key, Release: r.Name, Component: sub.Component, Submission: sub.Name})
+			}
+		}
+	}
+	return issues, nil
+}
+
+// lookupIssues fills in the title and status of issues from a tracker;
+// keys the tracker does not know are left as they are
+func lookupIssues(tracker Tracker, issues []shippedIssue) error {
+	if tracker == nil {
+		return nil
+	}
+	for i := range issues {
+		issue, err := tracker.GetIssue(issues[i].Key)
+		if errors.Is(err, errNotFound) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		issues[i].Title, issues[i].Status = issue.Title, issue.Status
+	}
+	return nil
+}
+
+func printIssues(issues []shippedIssue) {
+	for _, is := range issues {
+		line := fmt.Sprintf("%s (%s)", is.Key, is.Submission)
+		if is.Status != "" {
+			line += " [" + is.Status + "]"
+		}
+		if is.Title != "" {
+			line += ": " + is.Title
+		}
+		fmt.Println(line)
+	}
+}
+
+// fileTracker keeps issues as KEY.json files in a directory
+type fileTracker struct {
+	dir string
+}
+
+func newFileTracker(dir string) (Tracker, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, errors.New("tracker " + dir + " is not a directory")
+	}
+	return &fileTracker{dir: dir}, nil
+}
+
+func (t *fileTracker) GetIssue(key string) (*Issue, error) {
+	data, err := ioutil.ReadFile(filepath.Join(t.dir, key+".json"))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("issue %s %w", key, errNotFound)
+	}
+	if err != nil {
+		return nil, err
+	}
+	issue := &Issue{Key: key, Comments: []string{}}
+	err = json.Unmarshal(data, issue)
+	if err != nil {
+		return nil, errors.New("invalid issue " + key + ": " + err.Error())
+	}
+	return issue, nil
+}
+
+func (t *fileTracker) AddComment(key, comment string) error {
+	issue, err := t.GetIssue(key)
+	if err != nil {
+		return err
+	}
+	issue.Comments = append(issue.Comments, comment)
+	data, err := json.MarshalIndent(issue, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(t.dir, key+".json"), data)
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/urfave/cli"
+)
+
+func TestCommentIssues(t *testing.T) {
+	dir := t.TempDir()
+	for _, issue := range []Issue{
+		{Key: "VHAP-27", Title: "Better tiling", Status: "open", Comments: []string{}},
+		{Key: "VHAP-47", Title: "Crash", Status: "open", Comments: []string{"fixed in T1A2"}},
+	} {
+		data, err := json.Marshal(issue)
+		must(t, err)
+		must(t, ioutil.WriteFile(filepath.Join(dir, issue.Key+".json"), data, 0644))
+	}
+	tracker, err := newFileTracker(dir)
+	must(t, err)
+	issues := []shippedIssue{
+		{Key: "VHAP-27", Component: "osd", Submission: "osd-2"},
+		{Key: "VHAP-27", Component: "osd", Submission: "osd-3"},
+		{Key: "VHAP-47", Component: "osd", Submission: "osd-3"},
+		{Key: "VHAP-90", Component: "bash", Submission: "bash-1"},
+	}
+
+	comment := func() error {
+		app := cli.NewApp()
+		app.Writer = ioutil.Discard
+		app.Flags = argSchema{Flags: []flagSpec{forceFlag}}.flags()
+		app.Action = func(c *cli.Context) error {
+			return commentIssues(c, tracker, issues, "fixed in T1A2")
+		}
+		return app.Run([]string{"holo", "-f"})
+	}
+	// commenting again after a partial failure must not repeat comments
+	must(t, comment())
+	must(t, comment())
+	for key, want := range map[string][]string{
+		"VHAP-27": {"fixed in T1A2"},
+		"VHAP-47": {"fixed in T1A2"},
+	} {
+		issue, err := tracker.GetIssue(key)
+		must(t, err)
+		if !reflect.DeepEqual(issue.Comments, want) {
+			t.Errorf("%s comments = %q, want %q", key, issue.Comments, want)
+		}
+	}
+}
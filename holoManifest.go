@@ -0,0 +1,216 @@
+// file: holoManifest.go
+// purpose: declarative train manifests - the trains, their components and
+//          the owners of those components, kept in version control and
+//          converged onto the store by holo plan / holo apply
+//
+//   - example: trains:
+//                - name: Thundercat
+//                  components: [bash, osd, zlib]
+//                - name: ThundercatDeuce
+//                  base: Thundercat
+//                  components: [osd]
+//              components:
+//                - name: osd
+//                  owner: steve@bowerswilkins.com
+//                  repo: git@github.com:bowerswilkins/app.git
+//                  deps: [zlib]
+//
+//      - the manifest may be YAML or JSON
+//      - trains that are not in the manifest are left alone; a train that
+//        is in it loses every component the manifest does not list
+//      - base is only used when the train is created, which then starts
+//        from the latest release of the base train
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"sort"
+
+	"gopkg.in/yaml.v2"
+)
+
+type manifest struct {
+	Trains     []manifestTrain     `json:"trains" yaml:"trains"`
+	Components []manifestComponent `json:"components" yaml:"components"`
+}
+
+type manifestTrain struct {
+	Name       string   `json:"name" yaml:"name"`
+	Base       string   `json:"base,omitempty" yaml:"base,omitempty"`
+	Components []string `json:"components" yaml:"components"`
+}
+
+type manifestComponent struct {
+	Name  string   `json:"name" yaml:"name"`
+	Owner string   `json:"owner" yaml:"owner"`
+	Repo  string   `json:"repo,omitempty" yaml:"repo,omitempty"`
+	Deps  []string `json:"deps,omitempty" yaml:"deps,omitempty"`
+}
+
+// planOp is one change needed to converge the store onto a manifest;
+// Action is create-component, update-component, create-train, link or
+// unlink
+type planOp struct {
+	Action    string   `json:"action" yaml:"action"`
+	Train     string   `json:"train,omitempty" yaml:"train,omitempty"`
+	Component string   `json:"component,omitempty" yaml:"component,omitempty"`
+	Owner     string   `json:"owner,omitempty" yaml:"owner,omitempty"`
+	Repo      string   `json:"repo,omitempty" yaml:"repo,omitempty"`
+	Deps      []string `json:"deps,omitempty" yaml:"deps,omitempty"`
+	Base      string   `json:"base,omitempty" yaml:"base,omitempty"`
+}
+
+// loadManifest reads and checks a manifest file
+func loadManifest(path string) (*manifest, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var m manifest
+	// JSON is valid YAML
+	err = yaml.UnmarshalStrict(data, &m)
+	if err != nil {
+		return nil, errors.New("invalid manifest " + path + ": " + err.Error())
+	}
+
+	seen := map[string]bool{}
+	for _, comp := range m.Components {
+		if comp.Name == "" || seen[comp.Name] {
+			return nil, errors.New("invalid manifest " + path + ": component names must be set and unique")
+		}
+		if !emailPattern.MatchString(comp.Owner) {
+			return nil, errors.New("invalid manifest " + path + ": component " + comp.Name + " needs an owner email")
+		}
+		seen[comp.Name] = true
+	}
+	seen = map[string]bool{}
+	for _, t := range m.Trains {
+		if t.Name == "" || seen[t.Name] {
+			return nil, errors.New("invalid manifest " + path + ": train names must be set and unique")
+		}
+		seen[t.Name] = true
+	}
+	return &m, nil
+}
+
+// planManifest lists the operations that make the store match a manifest
+func planManifest(store Store, m *manifest) ([]planOp, error) {
+	ops := []planOp{}
+
+	defined := map[string]bool{}
+	for _, mc := range m.Components {
+		defined[mc.Name] = true
+		comp, err := store.GetComponent(mc.Name)
+		if errors.Is(err, errNotFound) {
+			ops = append(ops, planOp{Action: "create-component", Component: mc.Name, Owner: mc.Owner, Repo: mc.Repo, Deps: mc.Deps})
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if comp.Owner != mc.Owner || comp.Repo != mc.Repo || !sameStrs(comp.Deps, mc.Deps) {
+			ops = append(ops, planOp{Action: "update-component", Component: mc.Name, Owner: mc.Owner, Repo: mc.Repo, Deps: mc.Deps})
+		}
+	}
+
+	g, err := loadDepGraph(store)
+	if err != nil {
+		return nil, err
+	}
+	for _, mc := range m.Components {
+		if _, ok := g[mc.Name]; !ok {
+			g[mc.Name] = nil
+		}
+	}
+	for _, mc := range m.Components {
+		err = g.checkDeps(mc.Name, mc.Deps)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	links, unlinks := []planOp{}, []planOp{}
+	for _, mt := range m.Trains {
+		current := []string{}
+		t, err := store.GetTrain(mt.Name)
+		switch {
+		case errors.Is(err, errNotFound):
+			ops = append(ops, planOp{Action: "create-train", Train: mt.Name, Base: mt.Base})
+		case err != nil:
+			return nil, err
+		default:
+			current = t.Components
+		}
+
+		wanted := append([]string{}, mt.Components...)
+		sort.Strings(wanted)
+		for _, comp := range wanted {
+			if containsStr(current, comp) {
+				continue
+			}
+			if !defined[comp] {
+				_, err = store.GetComponent(comp)
+				if err != nil {
+					return nil, fmt.Errorf("train %s: component %s is not in the manifest and %w", mt.Name, comp, err)
+				}
+			}
+			links = append(links, planOp{Action: "link", Train: mt.Name, Component: comp})
+		}
+		for _, comp := range current {
+			if !containsStr(wanted, comp) {
+				unlinks = append(unlinks, planOp{Action: "unlink", Train: mt.Name, Component: comp})
+			}
+		}
+	}
+	return append(append(ops, links...), unlinks...), nil
+}
+
+// String describes an operation, e.g. "+ link osd to Thundercat"
+func (op planOp) String() string {
+	switch op.Action {
+	case "create-component":
+		return "+ create component " + op.Component + " (owner " + op.Owner + ")"
+	case "update-component":
+		return "~ update component " + op.Component + " (owner " + op.Owner + ")"
+	case "create-train":
+		if op.Base != "" {
+			return "+ create train " + op.Train + " (base " + op.Base + ")"
+		}
+		return "+ create train " + op.Train
+	case "link":
+		return "+ link " + op.Component + " to " + op.Train
+	case "unlink":
+		return "- unlink " + op.Component + " from " + op.Train
+	}
+	return op.Action
+}
+
+// applyOp carries out one operation of a plan
+func applyOp(store Store, op planOp) error {
+	switch op.Action {
+	case "create-component":
+		return store.CreateComponent(&Component{Name: op.Component, Owner: op.Owner, Repo: op.Repo, Deps: op.Deps})
+	case "update-component":
+		return store.UpdateComponent(&Component{Name: op.Component, Owner: op.Owner, Repo: op.Repo, Deps: op.Deps})
+	case "create-train":
+		// membership comes from the manifest, the base only lends its
+		// latest release as with trains create --base
+		t := &Train{Name: op.Train, Base: op.Base}
+		if op.Base != "" {
+			base, err := store.GetTrain(op.Base)
+			if err != nil {
+				return err
+			}
+			t.LatestRelease = base.LatestRelease
+		}
+		return store.CreateTrain(t)
+	case "link":
+		return store.LinkComponent(op.Component, op.Train)
+	case "unlink":
+		return store.UnlinkComponent(op.Component, op.Train)
+	}
+	return errors.New("unknown operation " + op.Action)
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestLoadManifest(t *testing.T) {
+	tests := []struct {
+		desc string
+		text string
+		ok   bool
+	}{
+		{"yaml", "trains:\n  - name: Thundercat\n    components: [osd]\ncomponents:\n  - name: osd\n    owner: steve@bowerswilkins.com\n", true},
+		{"json", `{"trains": [{"name": "Thundercat", "components": ["osd"]}], "components": [{"name": "osd", "owner": "steve@bowerswilkins.com"}]}`, true},
+		{"empty", "", true},
+		{"unknown field", "trains:\n  - name: Thundercat\n    members: [osd]\n", false},
+		{"component without a name", "components:\n  - owner: steve@bowerswilkins.com\n", false},
+		{"component twice", "components:\n  - name: osd\n    owner: steve@bowerswilkins.com\n  - name: osd\n    owner: steve@bowerswilkins.com\n", false},
+		{"owner not an email", "components:\n  - name: osd\n    owner: steve\n", false},
+		{"train twice", "trains:\n  - name: Thundercat\n  - name: Thundercat\n", false},
+		{"not a manifest", "- osd\n", false},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(t.TempDir(), "manifest")
+		must(t, ioutil.WriteFile(path, []byte(tt.text), 0644))
+		_, err := loadManifest(path)
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: loadManifest error = %v, want ok %v", tt.desc, err, tt.ok)
+		}
+	}
+}
+
+func TestPlanManifest(t *testing.T) {
+	store := newTestStore(t)
+	addTrain(t, store, "Thundercat", "bash", "osd")
+	must(t, store.CloneRelease(&Release{Name: "Thundercat1A1", Train: "Thundercat"}))
+	m := &manifest{
+		Components: []manifestComponent{
+			{Name: "osd", Owner: "anna@bowerswilkins.com", Deps: []string{"zlib"}},
+			{Name: "zlib", Owner: "steve@bowerswilkins.com"},
+		},
+		Trains: []manifestTrain{
+			{Name: "Thundercat", Components: []string{"zlib", "osd"}},
+			{Name: "ThundercatDeuce", Base: "Thundercat", Components: []string{"osd"}},
+		},
+	}
+
+	ops, err := planManifest(store, m)
+	must(t, err)
+	want := []planOp{
+		{Action: "update-component", Component: "osd", Owner: "anna@bowerswilkins.com", Deps: []string{"zlib"}},
+		{Action: "create-component", Component: "zlib", Owner: "steve@bowerswilkins.com"},
+		{Action: "create-train", Train: "ThundercatDeuce", Base: "Thundercat"},
+		{Action: "link", Train: "Thundercat", Component: "zlib"},
+		{Action: "link", Train: "ThundercatDeuce", Component: "osd"},
+		{Action: "unlink", Train: "Thundercat", Component: "bash"},
+	}
+	if !reflect.DeepEqual(ops, want) {
+		t.Fatalf("planManifest = %v, want %v", ops, want)
+	}
+
+	for _, op := range ops {
+		must(t, applyOp(store, op))
+	}
+	deuce, err := store.GetTrain("ThundercatDeuce")
+	must(t, err)
+	if deuce.LatestRelease != "Thundercat1A1" || !reflect.DeepEqual(deuce.Components, []string{"osd"}) {
+		t.Errorf("ThundercatDeuce = %+v, want osd on top of Thundercat1A1", deuce)
+	}
+	ops, err = planManifest(store, m)
+	must(t, err)
+	if len(ops) != 0 {
+		t.Errorf("planManifest after applying it = %v, want nothing to do", ops)
+	}
+}
+
+func TestPlanManifestInvalid(t *testing.T) {
+	store := newTestStore(t)
+	addTrain(t, store, "Thundercat", "osd")
+	tests := []struct {
+		desc string
+		m    manifest
+	}{
+		{"unknown component", manifest{Trains: []manifestTrain{{Name: "Thundercat", Components: []string{"gone"}}}}},
+		{"dependency cycle", manifest{Components: []manifestComponent{
+			{Name: "osd", Owner: "steve@bowerswilkins.com", Deps: []string{"zlib"}},
+			{Name: "zlib", Owner: "steve@bowerswilkins.com", Deps: []string{"osd"}},
+		}}},
+		{"unknown dependency", manifest{Components: []manifestComponent{
+			{Name: "osd", Owner: "steve@bowerswilkins.com", Deps: []string{"gone"}},
+		}}},
+	}
+	for _, tt := range tests {
+		if ops, err := planManifest(store, &tt.m); err == nil {
+			t.Errorf("%s: planManifest = %v, want an error", tt.desc, ops)
+		}
+	}
+}
+
+func TestPlanApplyCommands(t *testing.T) {
+	store := newTestStore(t)
+	path := filepath.Join(t.TempDir(), "manifest.yaml")
+	must(t, ioutil.WriteFile(path, []byte("trains:\n  - name: Thundercat\n    components: [osd]\ncomponents:\n  - name: osd\n    owner: steve@bowerswilkins.com\n"), 0644))
+
+	must(t, runHolo(t, store, "plan", "-f", path))
+	if _, err := store.GetTrain("Thundercat"); err == nil {
+		t.Error("plan changed the store")
+	}
+	must(t, runHolo(t, store, "apply", "-m", path, "-f"))
+	tr, err := store.GetTrain("Thundercat")
+	must(t, err)
+	if !reflect.DeepEqual(tr.Components, []string{"osd"}) {
+		t.Errorf("Thundercat carries %q after apply, want osd", tr.Components)
+	}
+}
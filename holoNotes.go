@@ -0,0 +1,340 @@
+// file: holoNotes.go
+// purpose: release notes aggregated over a range of releases, grouped by
+//          component and by "New features:" / "Bugs fixed:" sections, and
+//          rendered as plain text, Markdown or HTML
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// issuePattern matches issue keys, e.g. VHAP-27
+var issuePattern = regexp.MustCompile(`\b[A-Z][A-Z0-9]+-[0-9]+\b`)
+
+type releaseNotes struct {
+	Release    string           `json:"release" yaml:"release"`
+	Since      string           `json:"since" yaml:"since"`
+	Components []componentNotes `json:"components" yaml:"components"`
+}
+
+type componentNotes struct {
+	Component   string   `json:"component" yaml:"component"`
+	From        string   `json:"from,omitempty" yaml:"from,omitempty"`
+	To          string   `json:"to" yaml:"to"`
+	Submissions []string `json:"submissions" yaml:"submissions"`
+	Summaries   []string `json:"summaries" yaml:"summaries"` // SUBMISSION: summary
+	Features    []string `json:"features" yaml:"features"`
+	Bugs        []string `json:"bugs" yaml:"bugs"`
+	Other       []string `json:"other" yaml:"other"`
+	Reverted    []string `json:"reverted" yaml:"reverted"`
+}
+
+// Notes are the notes of a submission: a one line summary followed by
+// "New features:" and "Bugs fixed:" sections; anything else is other
+type Notes struct {
+	Summary  string   `json:"summary" yaml:"summary"`
+	Features []string `json:"features" yaml:"features"`
+	Bugs     []string `json:"bugs" yaml:"bugs"`
+	Other    []string `json:"other" yaml:"other"`
+}
+
+// parseNotes splits free-form notes into their sections; lines before any
+// section header, other than the first summary line, count as other
+func parseNotes(lines []string) Notes {
+	ns := Notes{Features: []string{}, Bugs: []string{}, Other: []string{}}
+	section := &ns.Other
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		switch strings.ToLower(line) {
+		case "new features:":
+			section = &ns.Features
+			continue
+		case "bugs fixed:":
+			section = &ns.Bugs
+			continue
+		case "":
+			continue
+		}
+		if ns.Summary == "" && section == &ns.Other {
+			ns.Summary = line
+			continue
+		}
+		*section = append(*section, line)
+	}
+	return ns
+}
+
+// UnmarshalJSON also reads notes stored as raw lines by earlier versions
+func (ns *Notes) UnmarshalJSON(data []byte) error {
+	var lines []string
+	if json.Unmarshal(data, &lines) == nil {
+		*ns = parseNotes(lines)
+		return nil
+	}
+	type notes Notes
+	n := notes(parseNotes(nil))
+	err := json.Unmarshal(data, &n)
+	*ns = Notes(n)
+	return err
+}
+
+// lines renders notes back into the free-form text they are parsed from
+func (ns Notes) lines() []string {
+	lines := []string{}
+	if ns.Summary != "" {
+		lines = append(lines, ns.Summary)
+	}
+	lines = append(lines, ns.Other...)
+	for _, sec := range []struct {
+		header string
+		lines  []string
+	}{{"New features:", ns.Features}, {"Bugs fixed:", ns.Bugs}} {
+		if len(sec.lines) > 0 {
+			lines = append(lines, "", sec.header, "")
+			lines = append(lines, sec.lines...)
+		}
+	}
+	return lines
+}
+
+func (ns Notes) empty() bool {
+	return ns.Summary == "" && len(ns.Features) == 0 && len(ns.Bugs) == 0 && len(ns.Other) == 0
+}
+
+// collectNotes gathers the notes of every submission a release brings in
+// since another release (default: its base)
+func collectNotes(store Store, release, since string) (*releaseNotes, error) {
+	r, err := store.GetRelease(release)
+	if err != nil {
+		return nil, err
+	}
+	if since == "" {
+		since = r.Base
+	}
+	changes, err := releaseChanges(store, r, since)
+	if err != nil {
+		return nil, err
+	}
+
+	reverts, err := revertsSince(store, r, since)
+	if err != nil {
+		return nil, err
+	}
+
+	rn := &releaseNotes{Release: r.Name, Since: since, Components: []componentNotes{}}
+	for _, ch := range changes {
+		if ch.Change == "removed" {
+			continue
+		}
+		cn := componentNotes{
+			Component:   ch.Component,
+			From:        ch.From,
+			To:          ch.To,
+			Submissions: []string{},
+			Summaries:   []string{},
+			Features:    []string{},
+			Bugs:        []string{},
+			Other:       []string{},
+			Reverted:    []string{},
+		}
+		if ch.Change == "downgraded" {
+			for _, rv := range reverts {
+				comp, _, err := parseSubmissionName(rv.Submission)
+				if err == nil && comp == ch.Component {
+					cn.Reverted = append(cn.Reverted, rv.Submission+" "+describeRevert(rv))
+				}
+			}
+			if len(cn.Reverted) == 0 {
+				cn.Reverted = append(cn.Reverted, ch.To+" reverts "+ch.From)
+			}
+			rn.Components = append(rn.Components, cn)
+			continue
+		}
+		seen := map[string]bool{}
+		for _, sub := range ch.Submissions {
+			cn.Submissions = append(cn.Submissions, sub.Name)
+			ns := sub.Notes
+			if ns.Summary != "" {
+				cn.Summaries = append(cn.Summaries, sub.Name+": "+ns.Summary)
+			}
+			cn.Features = appendUnique(cn.Features, ns.Features, seen)
+			cn.Bugs = appendUnique(cn.Bugs, ns.Bugs, seen)
+			cn.Other = appendUnique(cn.Other, ns.Other, seen)
+		}
+		rn.Components = append(rn.Components, cn)
+	}
+	return rn, nil
+}
+
+// releaseChanges lists the component changes a release brings in since
+// another one; since "" means from the beginning of the train
+func releaseChanges(store Store, r *Release, since string) ([]componentChange, error) {
+	var changes []componentChange
+	if since == "" {
+		onTrain, err := trainMember(store, r.Train)
+		if err != nil {
+			return nil, err
+		}
+		// the first release of a train brings in everything it contains
+		for _, name := range r.Submissions {
+			comp, _, err := parseSubmissionName(name)
+			if err != nil {
+				continue
+			}
+			subs, err := submissionsBetween(store, comp, "", name, onTrain)
+			if err != nil {
+				return nil, err
+			}
+			changes = append(changes, componentChange{Component: comp, To: name, Change: "added", Submissions: subs})
+		}
+	} else {
+		d, err := diffReleases(store, since, r.Name)
+		if err != nil {
+			return nil, err
+		}
+		changes = d.Components
+	}
+
+	return changes, nil
+}
+
+// revertsSince collects the reverts recorded by a release and the releases
+// it is based on, back to since
+func revertsSince(store Store, r *Release, since string) ([]Revert, error) {
+	reverts := []Revert{}
+	for r.Name != since {
+		reverts = append(r.Reverts, reverts...)
+		if r.Base == "" || r.Base == since {
+			break
+		}
+		var err error
+		r, err = store.GetRelease(r.Base)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return reverts, nil
+}
+
+// appendUnique appends the lines not seen yet in the notes of a component:
+// a line naming issue keys repeats an earlier one naming the same keys,
+// e.g. a bug fix carried over from the previous submission; a line
+// without keys only repeats the same line
+func appendUnique(list, lines []string, seen map[string]bool) []string {
+	for _, line := range lines {
+		key := line
+		if keys := issuePattern.FindAllString(line, -1); len(keys) > 0 {
+			sort.Strings(keys)
+			key = "issues " + strings.Join(keys, " ")
+		}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		list = append(list, line)
+	}
+	return list
+}
+
+// writeNotes renders release notes as text, markdown or html
+func writeNotes(w io.Writer, rn *releaseNotes, format string) error {
+	switch format {
+	case "", "text":
+		fmt.Fprintf(w, "Release notes for %s (since %s)\n", rn.Release, orNone(rn.Since))
+		for _, cn := range rn.Components {
+			fmt.Fprintf(w, "\n%s: %s\n", cn.Component, versionRange(cn))
+			writeTextSection(w, "Summary", cn.Summaries)
+			writeTextSection(w, "New features", cn.Features)
+			writeTextSection(w, "Bugs fixed", cn.Bugs)
+			writeTextSection(w, "Other changes", cn.Other)
+			writeTextSection(w, "Reverted", cn.Reverted)
+		}
+		return nil
+	case "markdown":
+		fmt.Fprintf(w, "# Release notes for %s\n\nChanges since %s.\n", rn.Release, orNone(rn.Since))
+		for _, cn := range rn.Components {
+			fmt.Fprintf(w, "\n## %s\n\n%s\n", cn.Component, versionRange(cn))
+			writeMarkdownSection(w, "Summary", cn.Summaries)
+			writeMarkdownSection(w, "New features", cn.Features)
+			writeMarkdownSection(w, "Bugs fixed", cn.Bugs)
+			writeMarkdownSection(w, "Other changes", cn.Other)
+			writeMarkdownSection(w, "Reverted", cn.Reverted)
+		}
+		return nil
+	case "html":
+		return notesHTML.Execute(w, rn)
+	}
+	return errors.New("unknown notes format " + format + ", expected text, markdown or html")
+}
+
+func orNone(s string) string {
+	if s == "" {
+		return "the beginning of the train"
+	}
+	return s
+}
+
+// versionRange describes a component change, e.g. osd-37 -> osd-39
+func versionRange(cn componentNotes) string {
+	if cn.From == "" {
+		return cn.To
+	}
+	return cn.From + " -> " + cn.To
+}
+
+func writeTextSection(w io.Writer, title string, lines []string) {
+	if len(lines) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "\n  %s:\n\n", title)
+	for _, line := range lines {
+		fmt.Fprintf(w, "  %s\n", line)
+	}
+}
+
+func writeMarkdownSection(w io.Writer, title string, lines []string) {
+	if len(lines) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "\n### %s\n\n", title)
+	for _, line := range lines {
+		fmt.Fprintf(w, "- %s\n", line)
+	}
+}
+
+var notesHTML = template.Must(template.New("notes").Funcs(template.FuncMap{
+	"orNone":       orNone,
+	"versionRange": versionRange,
+}).Parse(`<h1>Release notes for {{.Release}}</h1>
+<p>Changes since {{orNone .Since}}.</p>
+{{range .Components}}<h2>{{.Component}}</h2>
+<p>{{versionRange .}}</p>
+{{if .Summaries}}<h3>Summary</h3>
+<ul>{{range .Summaries}}
+<li>{{.}}</li>{{end}}
+</ul>
+{{end}}{{if .Features}}<h3>New features</h3>
+<ul>{{range .Features}}
+<li>{{.}}</li>{{end}}
+</ul>
+{{end}}{{if .Bugs}}<h3>Bugs fixed</h3>
+<ul>{{range .Bugs}}
+<li>{{.}}</li>{{end}}
+</ul>
+{{end}}{{if .Other}}<h3>Other changes</h3>
+<ul>{{range .Other}}
+<li>{{.}}</li>{{end}}
+</ul>
+{{end}}{{if .Reverted}}<h3>Reverted</h3>
+<ul>{{range .Reverted}}
+<li>{{.}}</li>{{end}}
+</ul>
+{{end}}{{end}}`))
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseNotes(t *testing.T) {
+	tests := []struct {
+		text string
+		want Notes
+	}{
+		{"", Notes{Features: []string{}, Bugs: []string{}, Other: []string{}}},
+		{"Better tiling", Notes{Summary: "Better tiling", Features: []string{}, Bugs: []string{}, Other: []string{}}},
+		{
+			"Better tiling\nSee the wiki\n\nNew features:\n\nVHAP-27: Tiling\n\nBugs fixed:\nVHAP-47: Crash\n",
+			Notes{Summary: "Better tiling", Features: []string{"VHAP-27: Tiling"}, Bugs: []string{"VHAP-47: Crash"}, Other: []string{"See the wiki"}},
+		},
+		{
+			"BUGS FIXED:\nVHAP-47: Crash",
+			Notes{Features: []string{}, Bugs: []string{"VHAP-47: Crash"}, Other: []string{}},
+		},
+	}
+	for _, tt := range tests {
+		got := parseNotes(strings.Split(tt.text, "\n"))
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseNotes(%q) = %+v, want %+v", tt.text, got, tt.want)
+		}
+		if again := parseNotes(got.lines()); !reflect.DeepEqual(again, got) {
+			t.Errorf("parseNotes(lines()) = %+v, want %+v", again, got)
+		}
+	}
+}
+
+func TestAppendUnique(t *testing.T) {
+	seen := map[string]bool{}
+	got := appendUnique(nil, []string{
+		"VHAP-47 VHAP-48: Crashes",
+		"VHAP-48, VHAP-47: the same crashes",
+		"VHAP-47: Crash",
+		"VHAP-48: Crash",
+		"Polish",
+		"Polish",
+		"VHAP-47: Crash again",
+	}, seen)
+	want := []string{"VHAP-47 VHAP-48: Crashes", "VHAP-47: Crash", "VHAP-48: Crash", "Polish"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("appendUnique = %q, want %q", got, want)
+	}
+}
+
+func TestCollectNotes(t *testing.T) {
+	store := newTestStore(t)
+	addTrain(t, store, "T", "osd", "bash")
+	addTrain(t, store, "Other", "osd")
+	addSubmission(t, store, "osd-1", Notes{Summary: "First"}, "T")
+	addSubmission(t, store, "osd-2", Notes{
+		Summary:  "Better tiling",
+		Features: []string{"VHAP-27: Better tiling"},
+		Bugs:     []string{"VHAP-47 VHAP-48: Crashes"},
+		Other:    []string{"Polish"},
+	}, "T")
+	addSubmission(t, store, "osd-2.1", Notes{Summary: "Hotfix for Other", Bugs: []string{"VHAP-90: Other only"}}, "Other")
+	addSubmission(t, store, "osd-3", Notes{
+		Summary: "More tiling",
+		Bugs: []string{
+			"VHAP-47: Crash again",
+			"VHAP-47 VHAP-48: Crashes, carried over",
+			"VHAP-48 VHAP-47: Crashes, in another order",
+		},
+		Other: []string{"Polish"},
+	}, "T")
+	addSubmission(t, store, "bash-1", Notes{Bugs: []string{"VHAP-47: Also in bash"}}, "T")
+	must(t, store.CloneRelease(&Release{Name: "T1A1", Train: "T", Submissions: []string{"osd-1"}}))
+	must(t, store.CloneRelease(&Release{Name: "T1A2", Train: "T", Base: "T1A1", Submissions: []string{"bash-1", "osd-3"}}))
+
+	rn, err := collectNotes(store, "T1A2", "")
+	must(t, err)
+	if rn.Since != "T1A1" {
+		t.Errorf("since = %q, want the base T1A1", rn.Since)
+	}
+	want := []componentNotes{
+		{
+			Component:   "bash",
+			To:          "bash-1",
+			Submissions: []string{"bash-1"},
+			Summaries:   []string{},
+			Features:    []string{},
+			Bugs:        []string{"VHAP-47: Also in bash"},
+			Other:       []string{},
+			Reverted:    []string{},
+		},
+		{
+			Component:   "osd",
+			From:        "osd-1",
+			To:          "osd-3",
+			Submissions: []string{"osd-2", "osd-3"},
+			Summaries:   []string{"osd-2: Better tiling", "osd-3: More tiling"},
+			Features:    []string{"VHAP-27: Better tiling"},
+			Bugs:        []string{"VHAP-47 VHAP-48: Crashes", "VHAP-47: Crash again"},
+			Other:       []string{"Polish"},
+			Reverted:    []string{},
+		},
+	}
+	if !reflect.DeepEqual(rn.Components, want) {
+		t.Errorf("collectNotes = %+v\nwant %+v", rn.Components, want)
+	}
+
+	var b strings.Builder
+	must(t, writeNotes(&b, rn, "markdown"))
+	for _, line := range []string{"### Summary", "- osd-2: Better tiling", "- VHAP-47: Crash again"} {
+		if !strings.Contains(b.String(), line+"\n") {
+			t.Errorf("markdown notes lack %q:\n%s", line, b.String())
+		}
+	}
+}
+
+func TestCollectNotesRange(t *testing.T) {
+	store := newTestStore(t)
+	addTrain(t, store, "T", "osd")
+	for _, name := range []string{"osd-1", "osd-2", "osd-3"} {
+		addSubmission(t, store, name, Notes{Summary: "Summary of " + name}, "T")
+	}
+	must(t, store.CloneRelease(&Release{Name: "T1A1", Train: "T", Submissions: []string{"osd-1"}}))
+	must(t, store.CloneRelease(&Release{Name: "T1A2", Train: "T", Base: "T1A1", Submissions: []string{"osd-3"}}))
+	must(t, store.CloneRelease(&Release{Name: "T1A3", Train: "T", Base: "T1A2", Submissions: []string{"osd-2"}, Reverts: []Revert{
+		{Submission: "osd-2", Reverted: "osd-3", Train: "T", Reason: "osd-3 breaks pairing"},
+	}}))
+
+	tests := []struct {
+		release, since string
+		summaries      []string
+		reverted       bool
+	}{
+		{"T1A1", "", []string{"osd-1: Summary of osd-1"}, false},
+		{"T1A2", "", []string{"osd-2: Summary of osd-2", "osd-3: Summary of osd-3"}, false},
+		{"T1A3", "T1A1", []string{"osd-2: Summary of osd-2"}, false},
+		{"T1A3", "", []string{}, true},
+	}
+	for _, tt := range tests {
+		rn, err := collectNotes(store, tt.release, tt.since)
+		must(t, err)
+		if len(rn.Components) != 1 {
+			t.Errorf("collectNotes(%s, %q) = %+v, want osd", tt.release, tt.since, rn.Components)
+			continue
+		}
+		cn := rn.Components[0]
+		if !reflect.DeepEqual(cn.Summaries, tt.summaries) {
+			t.Errorf("collectNotes(%s, %q) summaries = %q, want %q", tt.release, tt.since, cn.Summaries, tt.summaries)
+		}
+		if reverted := len(cn.Reverted) == 1 && strings.HasPrefix(cn.Reverted[0], "osd-2 ") && strings.Contains(cn.Reverted[0], "osd-3 breaks pairing"); reverted != tt.reverted {
+			t.Errorf("collectNotes(%s, %q) reverted = %q, want the revert %v", tt.release, tt.since, cn.Reverted, tt.reverted)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+// file: holoOutput.go
+// purpose: machine-readable output for holo read commands
+//
+//      - usage: holo --output text|json|yaml|table|template [--template TEMPLATE] command ...
+//    - example: holo -o json trains show Thundercat
+//               holo -o table releases ls Thundercat
+//               holo -o template --template '{{.Name}} {{.Owner}}' components ls
+//
+//      - records are the types in holoStore.go; their field names (the json
+//        tags) are listed per command as "output fields" and do not change
+//      - table prints one row per record with the field names as header
+//      - template is a Go text/template executed once per record
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"text/tabwriter"
+	"text/template"
+	"time"
+
+	"github.com/urfave/cli"
+	"gopkg.in/yaml.v2"
+)
+
+// printOutput prints v in the format selected by --output, or calls text
+// for the default human readable output
+func printOutput(c *cli.Context, v interface{}, text func()) error {
+	err := formatOutput(c, v, text)
+	if err != nil {
+		return sendCmdErr(err)
+	}
+	return nil
+}
+
+func formatOutput(c *cli.Context, v interface{}, text func()) error {
+	switch setting(c, "output") {
+	case "", "text":
+		text()
+		return nil
+	case "json":
+		data, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+		return nil
+	case "yaml":
+		data, err := yaml.Marshal(v)
+		if err != nil {
+			return err
+		}
+		fmt.Print(string(data))
+		return nil
+	case "table":
+		return printTable(records(v))
+	case "template":
+		if c.GlobalString("template") == "" {
+			return errors.New("--output template requires --template")
+		}
+		tmpl, err := template.New("output").Parse(c.GlobalString("template"))
+		if err != nil {
+			return err
+		}
+		for _, r := range records(v) {
+			err = tmpl.Execute(os.Stdout, r.Interface())
+			if err != nil {
+				return err
+			}
+			fmt.Println()
+		}
+		return nil
+	}
+	return errors.New("unknown output format " + setting(c, "output") + ", expected json, yaml, table or template")
+}
+
+// records turns a record or a slice of records into a list of structs
+func records(v interface{}) []reflect.Value {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Slice {
+		return []reflect.Value{rv}
+	}
+	list := make([]reflect.Value, rv.Len())
+	for i := range list {
+		list[i] = reflect.Indirect(rv.Index(i))
+	}
+	return list
+}
+
+// printTable prints records as aligned columns named after their json tags
+func printTable(list []reflect.Value) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	if len(list) == 0 {
+		return w.Flush()
+	}
+	t := list[0].Type()
+	header := []string{}
+	for i := 0; i < t.NumField(); i++ {
+		header = append(header, strings.ToUpper(fieldName(t.Field(i))))
+	}
+	fmt.Fprintln(w, strings.Join(header, "\t"))
+	for _, r := range list {
+		row := []string{}
+		for i := 0; i < r.NumField(); i++ {
+			row = append(row, cell(r.Field(i)))
+		}
+		fmt.Fprintln(w, strings.Join(row, "\t"))
+	}
+	return w.Flush()
+}
+
+func fieldName(f reflect.StructField) string {
+	name := strings.Split(f.Tag.Get("json"), ",")[0]
+	if name == "" {
+		name = f.Name
+	}
+	return name
+}
+
+// cell formats a single table value; lists are comma separated
+func cell(v reflect.Value) string {
+	if t, ok := v.Interface().(time.Time); ok {
+		return t.Format(timeLayout)
+	}
+	switch v.Kind() {
+	case reflect.Slice:
+		items := []string{}
+		for i := 0; i < v.Len(); i++ {
+			items = append(items, cell(v.Index(i)))
+		}
+		return strings.Join(items, ",")
+	case reflect.Struct:
+		data, _ := json.Marshal(v.Interface())
+		return string(data)
+	}
+	s := fmt.Sprint(v.Interface())
+	if s == "" {
+		s = "-"
+	}
+	return s
+}
@@ -0,0 +1,106 @@
+// file: holoProgress.go
+// purpose: progress bar for holo artifact transfers
+//
+//   - example output: [============================>                          ] 50% (30MB/s; ETA: 1s)
+//
+//      - drawn on stderr only when it is a terminal, so scripts stay quiet
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+const progressWidth = 54
+
+// progress counts the bytes written through it and redraws the bar
+type progress struct {
+	out     io.Writer
+	total   int64
+	done    int64
+	resumed int64
+	start   time.Time
+	drawn   time.Time
+}
+
+// newProgress returns a progress bar for a transfer of total bytes, of which
+// resumed were already transferred earlier
+func newProgress(total, resumed int64) *progress {
+	p := &progress{total: total, done: resumed, resumed: resumed, start: time.Now()}
+	if isTerminal(os.Stderr) {
+		p.out = os.Stderr
+	}
+	return p
+}
+
+// isTerminal reports whether f is attached to a terminal; /dev/null is a
+// character device too, but not one
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return false
+	}
+	null, err := os.Stat(os.DevNull)
+	return err != nil || !os.SameFile(info, null)
+}
+
+func (p *progress) Write(b []byte) (int, error) {
+	p.done += int64(len(b))
+	if time.Since(p.drawn) > 200*time.Millisecond {
+		p.draw()
+	}
+	return len(b), nil
+}
+
+func (p *progress) draw() {
+	p.drawn = time.Now()
+	if p.out == nil {
+		return
+	}
+	ratio := 1.0
+	if p.total > 0 {
+		ratio = float64(p.done) / float64(p.total)
+	}
+	filled := int(ratio * progressWidth)
+	bar := strings.Repeat("=", filled)
+	if filled < progressWidth {
+		bar += ">" + strings.Repeat(" ", progressWidth-filled-1)
+	}
+
+	elapsed := time.Since(p.start).Seconds()
+	rate := 0.0
+	if elapsed > 0 {
+		rate = float64(p.done-p.resumed) / elapsed
+	}
+	eta := "-"
+	if rate > 0 {
+		eta = (time.Duration(float64(p.total-p.done)/rate) * time.Second).Round(time.Second).String()
+	}
+	fmt.Fprintf(p.out, "\r[%s] %d%% (%s/s; ETA: %s)", bar, int(ratio*100), humanBytes(int64(rate)), eta)
+}
+
+// finish draws the completed bar and ends its line
+func (p *progress) finish() {
+	p.draw()
+	if p.out != nil {
+		fmt.Fprintln(p.out)
+	}
+}
+
+// humanBytes formats a byte count, e.g. 30MB
+func humanBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%dB", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%d%cB", n/div, "KMGTPE"[exp])
+}
@@ -0,0 +1,133 @@
+// file: holoReleaseName.go
+// purpose: structured release names - TRAIN MAJOR LETTER BUILD, e.g.
+//          Thundercat1A176 is build 176 of milestone 1A of train Thundercat;
+//          releases order by major, then letter, then build number, so
+//          1A176 < 1A177 < 1B1 < 2A1
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var releaseVersionPattern = regexp.MustCompile(`^([1-9][0-9]*)([A-Z])([1-9][0-9]*)$`)
+
+type releaseName struct {
+	Train  string
+	Major  int
+	Letter byte
+	Build  int
+}
+
+// parseReleaseName splits a release name of a train into its parts
+func parseReleaseName(train, name string) (releaseName, error) {
+	rn := releaseName{Train: train}
+	if train == "" || !strings.HasPrefix(name, train) {
+		return rn, errors.New("release " + name + " does not belong to train " + train)
+	}
+	m := releaseVersionPattern.FindStringSubmatch(strings.TrimPrefix(name, train))
+	if m == nil {
+		return rn, errors.New("invalid release name " + name + ", expected " + train + "MAJOR LETTER BUILD, e.g. " + train + "1A1")
+	}
+	var err error
+	rn.Major, err = strconv.Atoi(m[1])
+	if err == nil {
+		rn.Build, err = strconv.Atoi(m[3])
+	}
+	if err != nil {
+		return rn, errors.New("invalid release name " + name + ": " + err.Error())
+	}
+	rn.Letter = m[2][0]
+	return rn, nil
+}
+
+func (rn releaseName) String() string {
+	return fmt.Sprintf("%s%d%c%d", rn.Train, rn.Major, rn.Letter, rn.Build)
+}
+
+// compare orders two release names of the same train
+func (rn releaseName) compare(other releaseName) int {
+	switch {
+	case rn.Major != other.Major:
+		return compareInts(rn.Major, other.Major)
+	case rn.Letter != other.Letter:
+		return compareInts(int(rn.Letter), int(other.Letter))
+	}
+	return compareInts(rn.Build, other.Build)
+}
+
+// next returns the release following rn; bump is "" for the next build,
+// "letter" for the next milestone (1A176 => 1B1) or "major" (1B4 => 2A1)
+func (rn releaseName) next(bump string) (releaseName, error) {
+	switch bump {
+	case "":
+		rn.Build++
+	case "letter":
+		if rn.Letter == 'Z' {
+			return rn, errors.New("release " + rn.String() + " is the last milestone of its major, use --bump major")
+		}
+		rn.Letter++
+		rn.Build = 1
+	case "major":
+		rn.Major++
+		rn.Letter = 'A'
+		rn.Build = 1
+	default:
+		return rn, errors.New("unknown bump " + bump + ", expected major or letter")
+	}
+	return rn, nil
+}
+
+// firstReleaseName is the name of the first release of a train
+func firstReleaseName(train string) releaseName {
+	return releaseName{Train: train, Major: 1, Letter: 'A', Build: 1}
+}
+
+// nextReleaseName computes the name of the release to cut after the
+// newest release of a train; a train cloned from another one carries on
+// with the numbering of the release it was cloned at
+func nextReleaseName(store Store, t *Train, bump string) (string, error) {
+	releases, err := store.ListReleases(t.Name)
+	if err != nil {
+		return "", err
+	}
+	var latest releaseName
+	switch {
+	case len(releases) > 0:
+		latest, err = parseReleaseName(t.Name, releases[0].Name)
+	case t.Base != "" && t.LatestRelease != "":
+		latest, err = parseReleaseName(t.Base, t.LatestRelease)
+		latest.Train = t.Name
+	default:
+		return firstReleaseName(t.Name).String(), nil
+	}
+	if err != nil {
+		return "", err
+	}
+	next, err := latest.next(bump)
+	if err != nil {
+		return "", err
+	}
+	return next.String(), nil
+}
+
+// sortReleases orders releases newest first; names that do not parse sort
+// after those that do, by creation time
+func sortReleases(releases []Release) {
+	sort.SliceStable(releases, func(i, j int) bool {
+		a, erra := parseReleaseName(releases[i].Train, releases[i].Name)
+		b, errb := parseReleaseName(releases[j].Train, releases[j].Name)
+		switch {
+		case erra == nil && errb == nil:
+			return a.compare(b) > 0
+		case erra == nil || errb == nil:
+			return erra == nil
+		}
+		return releases[i].Created.After(releases[j].Created)
+	})
+}
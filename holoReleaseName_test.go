@@ -0,0 +1,155 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseReleaseName(t *testing.T) {
+	tests := []struct {
+		train, name string
+		want        releaseName
+		ok          bool
+	}{
+		{"Thundercat", "Thundercat1A176", releaseName{"Thundercat", 1, 'A', 176}, true},
+		{"Thundercat", "Thundercat12Z1", releaseName{"Thundercat", 12, 'Z', 1}, true},
+		{"ThundercatDeuce", "ThundercatDeuce1A177", releaseName{"ThundercatDeuce", 1, 'A', 177}, true},
+		{"Thundercat", "ThundercatDeuce1A177", releaseName{}, false},
+		{"Thundercat", "Avalanche1A1", releaseName{}, false},
+		{"", "1A1", releaseName{}, false},
+		{"Thundercat", "Thundercat", releaseName{}, false},
+		{"Thundercat", "Thundercat1A", releaseName{}, false},
+		{"Thundercat", "Thundercat1a1", releaseName{}, false},
+		{"Thundercat", "Thundercat0A1", releaseName{}, false},
+		{"Thundercat", "Thundercat1A0", releaseName{}, false},
+		{"Thundercat", "Thundercat1A01", releaseName{}, false},
+		{"Thundercat", "Thundercat1AB1", releaseName{}, false},
+		{"Thundercat", "Thundercat1A1x", releaseName{}, false},
+		{"Thundercat", "Thundercat1A99999999999999999999", releaseName{}, false},
+		{"Thundercat", "Thundercat99999999999999999999A1", releaseName{}, false},
+	}
+	for _, tt := range tests {
+		got, err := parseReleaseName(tt.train, tt.name)
+		if (err == nil) != tt.ok {
+			t.Errorf("parseReleaseName(%q, %q) error = %v, want ok %v", tt.train, tt.name, err, tt.ok)
+			continue
+		}
+		if tt.ok && got != tt.want {
+			t.Errorf("parseReleaseName(%q, %q) = %+v, want %+v", tt.train, tt.name, got, tt.want)
+		}
+		if tt.ok && got.String() != tt.name {
+			t.Errorf("parseReleaseName(%q, %q).String() = %q", tt.train, tt.name, got.String())
+		}
+	}
+}
+
+func TestReleaseNameCompare(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"T1A176", "T1A177", -1},
+		{"T1A177", "T1A176", 1},
+		{"T1A176", "T1A176", 0},
+		{"T1A9", "T1A10", -1},
+		{"T1A999", "T1B1", -1},
+		{"T1Z999", "T2A1", -1},
+		{"T9A1", "T10A1", -1},
+		{"T2A1", "T1B4", 1},
+	}
+	for _, tt := range tests {
+		a, err := parseReleaseName("T", tt.a)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := parseReleaseName("T", tt.b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := a.compare(b); got != tt.want {
+			t.Errorf("compare(%s, %s) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestSortReleases(t *testing.T) {
+	now := time.Now()
+	releases := []Release{
+		{Name: "T1A9", Train: "T"},
+		{Name: "Tlegacy", Train: "T", Created: now.Add(-time.Hour)},
+		{Name: "T2A1", Train: "T"},
+		{Name: "T1A10", Train: "T"},
+		{Name: "Tnewer", Train: "T", Created: now},
+		{Name: "T1B1", Train: "T"},
+	}
+	sortReleases(releases)
+	want := []string{"T2A1", "T1B1", "T1A10", "T1A9", "Tnewer", "Tlegacy"}
+	for i, r := range releases {
+		if r.Name != want[i] {
+			t.Fatalf("sortReleases = %v, want %v", releaseNames(releases), want)
+		}
+	}
+}
+
+func releaseNames(releases []Release) []string {
+	names := []string{}
+	for _, r := range releases {
+		names = append(names, r.Name)
+	}
+	return names
+}
+
+func TestNextReleaseName(t *testing.T) {
+	tests := []struct {
+		desc     string
+		releases []string
+		base     string // train the test train was cloned from
+		latest   string // release it was cloned at
+		bump     string
+		want     string
+		ok       bool
+	}{
+		{"first release", nil, "", "", "", "T1A1", true},
+		{"first release ignores bump", nil, "", "", "letter", "T1A1", true},
+		{"next build", []string{"T1A176"}, "", "", "", "T1A177", true},
+		{"build rollover", []string{"T1A9"}, "", "", "", "T1A10", true},
+		{"newest of many", []string{"T1A9", "T1A10", "T1A2"}, "", "", "", "T1A11", true},
+		{"letter bump", []string{"T1A176"}, "", "", "letter", "T1B1", true},
+		{"letter bump after Z", []string{"T1Z4"}, "", "", "letter", "", false},
+		{"major bump", []string{"T1B4"}, "", "", "major", "T2A1", true},
+		{"major bump after Z", []string{"T1Z4"}, "", "", "major", "T2A1", true},
+		{"unknown bump", []string{"T1A1"}, "", "", "minor", "", false},
+		{"cloned train", nil, "S", "S1A177", "", "T1A178", true},
+		{"cloned train letter bump", nil, "S", "S1A177", "letter", "T1B1", true},
+		{"cloned train with releases", []string{"T1A178"}, "S", "S1A177", "", "T1A179", true},
+	}
+	for _, tt := range tests {
+		store, err := newFileStore(t.TempDir())
+		if err != nil {
+			t.Fatal(err)
+		}
+		train := &Train{Name: "T", Base: tt.base, LatestRelease: tt.latest}
+		err = store.CreateTrain(train)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, name := range tt.releases {
+			err = store.CloneRelease(&Release{Name: name, Train: "T"})
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		train, err = store.GetTrain("T")
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := nextReleaseName(store, train, tt.bump)
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: nextReleaseName error = %v, want ok %v", tt.desc, err, tt.ok)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s: nextReleaseName = %q, want %q", tt.desc, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+// file: holoReleaseState.go
+// purpose: release lifecycle - a release is cut, then building, candidate,
+//          qualified and finally shipped to customers; any release that
+//          is not withdrawn yet may be withdrawn
+//
+//      - a release moves one state forward at a time, so that a build
+//        cannot ship without having been qualified
+//      - a release needs artifacts to become a candidate
+//      - releases stored before states existed count as cut
+
+package main
+
+import (
+	"errors"
+)
+
+// releaseStates are the lifecycle states, in order
+var releaseStates = []string{"cut", "building", "candidate", "qualified", "shipped", "withdrawn"}
+
+// promoteStates are the states a release may be promoted to
+var promoteStates = releaseStates[1:]
+
+// releaseState returns the state of a release
+func releaseState(r *Release) string {
+	if r.State == "" {
+		return "cut"
+	}
+	return r.State
+}
+
+// checkTransition makes sure a release may move from one state to another
+func checkTransition(r *Release, to string) error {
+	from := releaseState(r)
+	i, j := indexOf(releaseStates, from), indexOf(releaseStates, to)
+	switch {
+	case j < 0:
+		return errors.New("unknown state " + to + ", expected " + orList(releaseStates))
+	case from == to:
+		return errors.New("release " + r.Name + " is already " + to)
+	case from == "withdrawn":
+		return errors.New("release " + r.Name + " is withdrawn")
+	case to == "withdrawn" || j == i+1:
+		return nil
+	case j < i:
+		return errors.New("release " + r.Name + " is " + from + ", it cannot go back to " + to)
+	}
+	return errors.New("release " + r.Name + " is " + from + ", it has to be " + releaseStates[j-1] + " before it can be " + to)
+}
+
+// checkCandidate makes sure a release has artifacts to test as a candidate
+func checkCandidate(name string, artifacts int) error {
+	if artifacts == 0 {
+		return errors.New("release " + name + " has no artifacts, it cannot be a candidate")
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestCheckTransition(t *testing.T) {
+	tests := []struct {
+		from, to string
+		ok       bool
+	}{
+		{"", "building", true},
+		{"cut", "building", true},
+		{"building", "candidate", true},
+		{"candidate", "qualified", true},
+		{"qualified", "shipped", true},
+		{"cut", "withdrawn", true},
+		{"shipped", "withdrawn", true},
+
+		{"cut", "candidate", false},
+		{"building", "shipped", false},
+		{"qualified", "building", false},
+		{"shipped", "cut", false},
+		{"building", "building", false},
+		{"withdrawn", "withdrawn", false},
+		{"withdrawn", "shipped", false},
+		{"cut", "released", false},
+		{"cut", "", false},
+	}
+	for _, tt := range tests {
+		err := checkTransition(&Release{Name: "T1A1", State: tt.from}, tt.to)
+		if (err == nil) != tt.ok {
+			t.Errorf("checkTransition(%q, %q) error = %v, want ok %v", tt.from, tt.to, err, tt.ok)
+		}
+	}
+}
+
+func TestPromoteRelease(t *testing.T) {
+	store := newTestStore(t)
+	addTrain(t, store, "T")
+	must(t, store.CloneRelease(&Release{Name: "T1A1", Train: "T"}))
+
+	r, err := store.PromoteRelease("T1A1", "building")
+	must(t, err)
+	if r.State != "building" {
+		t.Errorf("state after promoting = %s, want building", r.State)
+	}
+	if _, err := store.PromoteRelease("T1A1", "candidate"); err == nil {
+		t.Error("a release without artifacts became a candidate")
+	}
+	addArtifact(t, store, "T1A1", "T1A1_SDKRoot.txz", "sdk")
+	for _, state := range []string{"candidate", "qualified", "shipped", "withdrawn"} {
+		_, err := store.PromoteRelease("T1A1", state)
+		must(t, err)
+	}
+	if _, err := store.PromoteRelease("T1A1", "shipped"); err == nil {
+		t.Error("a withdrawn release was shipped again")
+	}
+	r, err = store.GetRelease("T1A1")
+	must(t, err)
+	if r.State != "withdrawn" {
+		t.Errorf("stored state = %s, want withdrawn", r.State)
+	}
+}
@@ -0,0 +1,421 @@
+// file: holoServer.go
+// purpose: reference implementation of the holo build-service API,
+//          backed by any Store (see holoAPI.go for the routes)
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type apiServer struct {
+	store  Store
+	tokens *tokenStore
+	users  passwords
+}
+
+func newAPIHandler(store Store, tokens *tokenStore, users passwords) http.Handler {
+	s := &apiServer{store: store, tokens: tokens, users: users}
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("POST "+apiPrefix+"/login", s.login)
+	mux.HandleFunc("POST "+apiPrefix+"/refresh", s.refresh)
+	mux.HandleFunc("POST "+apiPrefix+"/logout", s.logout)
+	mux.HandleFunc("GET "+apiPrefix+"/whoami", s.whoami)
+
+	mux.HandleFunc("GET "+apiPrefix+"/trains", s.listTrains)
+	mux.HandleFunc("POST "+apiPrefix+"/trains", s.createTrain)
+	mux.HandleFunc("GET "+apiPrefix+"/trains/{train}", s.getTrain)
+	mux.HandleFunc("GET "+apiPrefix+"/trains/{train}/releases", s.listReleases)
+	mux.HandleFunc("POST "+apiPrefix+"/trains/{train}/releases", s.cutRelease)
+
+	mux.HandleFunc("POST "+apiPrefix+"/releases", s.cloneRelease)
+	mux.HandleFunc("GET "+apiPrefix+"/releases/{release}", s.getRelease)
+	mux.HandleFunc("DELETE "+apiPrefix+"/releases/{release}", s.deleteRelease)
+	mux.HandleFunc("POST "+apiPrefix+"/releases/{release}/state", s.promoteRelease)
+	mux.HandleFunc("GET "+apiPrefix+"/releases/{release}/artifacts", s.listArtifacts)
+	mux.HandleFunc("POST "+apiPrefix+"/releases/{release}/artifacts", s.linkArtifact)
+	mux.HandleFunc("GET "+apiPrefix+"/releases/{release}/artifacts/{artifact}", s.getArtifact)
+	mux.HandleFunc("GET "+apiPrefix+"/releases/{release}/artifacts/{artifact}/data", s.getArtifactData)
+	mux.HandleFunc("GET "+apiPrefix+"/releases/{release}/uploads/{artifact}", s.uploadOffset)
+	mux.HandleFunc("PATCH "+apiPrefix+"/releases/{release}/uploads/{artifact}", s.uploadChunk)
+	mux.HandleFunc("POST "+apiPrefix+"/releases/{release}/uploads/{artifact}/commit", s.commitUpload)
+
+	mux.HandleFunc("GET "+apiPrefix+"/components", s.listComponents)
+	mux.HandleFunc("POST "+apiPrefix+"/components", s.createComponent)
+	mux.HandleFunc("GET "+apiPrefix+"/components/{component}", s.getComponent)
+	mux.HandleFunc("PUT "+apiPrefix+"/components/{component}", s.updateComponent)
+	mux.HandleFunc("POST "+apiPrefix+"/components/{component}/trains", s.linkComponent)
+	mux.HandleFunc("DELETE "+apiPrefix+"/components/{component}/trains/{train}", s.unlinkComponent)
+
+	mux.HandleFunc("GET "+apiPrefix+"/submissions", s.listSubmissions)
+	mux.HandleFunc("POST "+apiPrefix+"/submissions", s.createSubmission)
+	mux.HandleFunc("GET "+apiPrefix+"/submissions/{submission}", s.getSubmission)
+	mux.HandleFunc("DELETE "+apiPrefix+"/submissions/{submission}", s.deleteSubmission)
+	mux.HandleFunc("POST "+apiPrefix+"/submissions/{submission}/trains", s.queueSubmission)
+	mux.HandleFunc("PUT "+apiPrefix+"/submissions/{submission}/sources", s.putSources)
+	mux.HandleFunc("GET "+apiPrefix+"/submissions/{submission}/sources", s.getSources)
+
+	mux.HandleFunc("POST "+apiPrefix+"/reverts", s.revertSubmission)
+
+	mux.HandleFunc("GET "+apiPrefix+"/audit", s.listAudit)
+	mux.HandleFunc("POST "+apiPrefix+"/audit", s.appendAudit)
+
+	return tokens.requireAuth(mux)
+}
+
+// reply writes either the error or v as the JSON response
+func reply(w http.ResponseWriter, r *http.Request, v interface{}, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	if err != nil {
+		log.Printf("%s %s: %v", r.Method, r.URL.Path, err)
+		w.WriteHeader(statusOf(err))
+		json.NewEncoder(w).Encode(&errorResponse{Error: err.Error()})
+		return
+	}
+	if v == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	json.NewEncoder(w).Encode(v)
+}
+
+// decode reads a JSON request body into v
+func decode(r *http.Request, v interface{}) error {
+	return json.NewDecoder(r.Body).Decode(v)
+}
+
+// Authentication
+
+func (s *apiServer) login(w http.ResponseWriter, r *http.Request) {
+	var req loginRequest
+	err := decode(r, &req)
+	if err != nil {
+		reply(w, r, nil, err)
+		return
+	}
+	if !s.users.check(req.Email, req.Password) {
+		reply(w, r, nil, authError("invalid email address or password"))
+		return
+	}
+	cred, err := s.tokens.issue(req.Email)
+	reply(w, r, cred, err)
+}
+
+func (s *apiServer) refresh(w http.ResponseWriter, r *http.Request) {
+	if identity(r) == "" {
+		reply(w, r, nil, authError("login required, run holo login"))
+		return
+	}
+	cred, err := s.tokens.issue(identity(r))
+	if err == nil {
+		err = s.tokens.revoke(strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "))
+	}
+	reply(w, r, cred, err)
+}
+
+func (s *apiServer) logout(w http.ResponseWriter, r *http.Request) {
+	err := s.tokens.revoke(strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "))
+	reply(w, r, nil, err)
+}
+
+func (s *apiServer) whoami(w http.ResponseWriter, r *http.Request) {
+	if identity(r) == "" {
+		reply(w, r, nil, authError("not logged in"))
+		return
+	}
+	cred, err := s.tokens.lookup(strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "))
+	if err == nil {
+		cred = &credentials{Email: cred.Email, Expires: cred.Expires}
+	}
+	reply(w, r, cred, err)
+}
+
+// Trains
+
+func (s *apiServer) listTrains(w http.ResponseWriter, r *http.Request) {
+	trains, err := s.store.ListTrains()
+	reply(w, r, trains, err)
+}
+
+func (s *apiServer) createTrain(w http.ResponseWriter, r *http.Request) {
+	var t Train
+	err := decode(r, &t)
+	if err == nil {
+		err = s.store.CreateTrain(&t)
+	}
+	reply(w, r, &t, err)
+}
+
+func (s *apiServer) getTrain(w http.ResponseWriter, r *http.Request) {
+	t, err := s.store.GetTrain(r.PathValue("train"))
+	reply(w, r, t, err)
+}
+
+func (s *apiServer) revertSubmission(w http.ResponseWriter, r *http.Request) {
+	var reverts []*Revert
+	err := decode(r, &reverts)
+	if err == nil {
+		for _, rv := range reverts {
+			rv.RevertedBy = identity(r)
+		}
+		err = s.store.RevertSubmission(reverts)
+	}
+	reply(w, r, reverts, err)
+}
+
+func (s *apiServer) listReleases(w http.ResponseWriter, r *http.Request) {
+	releases, err := s.store.ListReleases(r.PathValue("train"))
+	reply(w, r, releases, err)
+}
+
+// Releases
+
+func (s *apiServer) cloneRelease(w http.ResponseWriter, r *http.Request) {
+	var rel Release
+	err := decode(r, &rel)
+	if err == nil {
+		rel.CutBy = identity(r)
+		err = s.store.CloneRelease(&rel)
+	}
+	reply(w, r, &rel, err)
+}
+
+func (s *apiServer) deleteRelease(w http.ResponseWriter, r *http.Request) {
+	err := s.store.DeleteRelease(r.PathValue("release"))
+	reply(w, r, nil, err)
+}
+
+func (s *apiServer) promoteRelease(w http.ResponseWriter, r *http.Request) {
+	var req stateRequest
+	err := decode(r, &req)
+	if err != nil {
+		reply(w, r, nil, err)
+		return
+	}
+	rel, err := s.store.PromoteRelease(r.PathValue("release"), req.State)
+	reply(w, r, rel, err)
+}
+
+func (s *apiServer) cutRelease(w http.ResponseWriter, r *http.Request) {
+	var rel Release
+	err := decode(r, &rel)
+	if err == nil {
+		rel.Train = r.PathValue("train")
+		rel.CutBy = identity(r)
+		err = s.store.CutRelease(&rel)
+	}
+	reply(w, r, &rel, err)
+}
+
+func (s *apiServer) getRelease(w http.ResponseWriter, r *http.Request) {
+	rel, err := s.store.GetRelease(r.PathValue("release"))
+	reply(w, r, rel, err)
+}
+
+// Components
+
+func (s *apiServer) listComponents(w http.ResponseWriter, r *http.Request) {
+	comps, err := s.store.ListComponents()
+	reply(w, r, comps, err)
+}
+
+func (s *apiServer) createComponent(w http.ResponseWriter, r *http.Request) {
+	var comp Component
+	err := decode(r, &comp)
+	if err == nil {
+		err = s.store.CreateComponent(&comp)
+	}
+	reply(w, r, &comp, err)
+}
+
+func (s *apiServer) updateComponent(w http.ResponseWriter, r *http.Request) {
+	var comp Component
+	err := decode(r, &comp)
+	if err == nil {
+		comp.Name = r.PathValue("component")
+		err = s.store.UpdateComponent(&comp)
+	}
+	reply(w, r, &comp, err)
+}
+
+func (s *apiServer) getComponent(w http.ResponseWriter, r *http.Request) {
+	comp, err := s.store.GetComponent(r.PathValue("component"))
+	reply(w, r, comp, err)
+}
+
+func (s *apiServer) linkComponent(w http.ResponseWriter, r *http.Request) {
+	var req linkRequest
+	err := decode(r, &req)
+	if err == nil {
+		err = s.store.LinkComponent(r.PathValue("component"), req.Train)
+	}
+	reply(w, r, nil, err)
+}
+
+func (s *apiServer) unlinkComponent(w http.ResponseWriter, r *http.Request) {
+	err := s.store.UnlinkComponent(r.PathValue("component"), r.PathValue("train"))
+	reply(w, r, nil, err)
+}
+
+// Submissions
+
+func (s *apiServer) listSubmissions(w http.ResponseWriter, r *http.Request) {
+	subs, err := s.store.ListSubmissions(r.URL.Query().Get("component"))
+	reply(w, r, subs, err)
+}
+
+func (s *apiServer) createSubmission(w http.ResponseWriter, r *http.Request) {
+	var sub Submission
+	err := decode(r, &sub)
+	if err == nil {
+		sub.Submitter = identity(r)
+		err = s.store.CreateSubmission(&sub)
+	}
+	reply(w, r, &sub, err)
+}
+
+func (s *apiServer) getSubmission(w http.ResponseWriter, r *http.Request) {
+	sub, err := s.store.GetSubmission(r.PathValue("submission"))
+	reply(w, r, sub, err)
+}
+
+func (s *apiServer) deleteSubmission(w http.ResponseWriter, r *http.Request) {
+	err := s.store.DeleteSubmission(r.PathValue("submission"))
+	reply(w, r, nil, err)
+}
+
+func (s *apiServer) queueSubmission(w http.ResponseWriter, r *http.Request) {
+	var req queueRequest
+	err := decode(r, &req)
+	if err == nil {
+		err = s.store.QueueSubmission(r.PathValue("submission"), req.Trains)
+	}
+	reply(w, r, nil, err)
+}
+
+func (s *apiServer) putSources(w http.ResponseWriter, r *http.Request) {
+	err := s.store.PutSources(r.PathValue("submission"), r.Body)
+	reply(w, r, nil, err)
+}
+
+func (s *apiServer) getSources(w http.ResponseWriter, r *http.Request) {
+	rc, err := s.store.GetSources(r.PathValue("submission"))
+	if err != nil {
+		reply(w, r, nil, err)
+		return
+	}
+	defer rc.Close()
+	w.Header().Set("Content-Type", "application/gzip")
+	_, err = io.Copy(w, rc)
+	if err != nil {
+		log.Printf("%s %s: %v", r.Method, r.URL.Path, err)
+	}
+}
+
+// Artifacts
+
+func (s *apiServer) listArtifacts(w http.ResponseWriter, r *http.Request) {
+	artifacts, err := s.store.ListArtifacts(r.PathValue("release"))
+	reply(w, r, artifacts, err)
+}
+
+func (s *apiServer) linkArtifact(w http.ResponseWriter, r *http.Request) {
+	var a Artifact
+	err := decode(r, &a)
+	if err == nil {
+		a.Release = r.PathValue("release")
+		err = s.store.LinkArtifact(&a)
+	}
+	reply(w, r, &a, err)
+}
+
+func (s *apiServer) getArtifact(w http.ResponseWriter, r *http.Request) {
+	a, err := s.store.StatArtifact(r.PathValue("release"), r.PathValue("artifact"))
+	reply(w, r, a, err)
+}
+
+func (s *apiServer) getArtifactData(w http.ResponseWriter, r *http.Request) {
+	var offset int64
+	if rng := r.Header.Get("Range"); rng != "" {
+		_, err := fmt.Sscanf(rng, "bytes=%d-", &offset)
+		if err != nil {
+			reply(w, r, nil, errors.New("unsupported range "+rng))
+			return
+		}
+	}
+	rc, err := s.store.OpenArtifact(r.PathValue("release"), r.PathValue("artifact"), offset)
+	if err != nil {
+		reply(w, r, nil, err)
+		return
+	}
+	defer rc.Close()
+	w.Header().Set("Content-Type", "application/octet-stream")
+	if offset > 0 {
+		w.WriteHeader(http.StatusPartialContent)
+	}
+	_, err = io.Copy(w, rc)
+	if err != nil {
+		log.Printf("%s %s: %v", r.Method, r.URL.Path, err)
+	}
+}
+
+func (s *apiServer) uploadOffset(w http.ResponseWriter, r *http.Request) {
+	offset, err := s.store.UploadOffset(r.PathValue("release"), r.PathValue("artifact"))
+	reply(w, r, &uploadStatus{Offset: offset}, err)
+}
+
+func (s *apiServer) uploadChunk(w http.ResponseWriter, r *http.Request) {
+	offset, err := strconv.ParseInt(r.URL.Query().Get("offset"), 10, 64)
+	if err == nil {
+		offset, err = s.store.UploadChunk(r.PathValue("release"), r.PathValue("artifact"), offset, r.Body)
+	}
+	reply(w, r, &uploadStatus{Offset: offset}, err)
+}
+
+func (s *apiServer) commitUpload(w http.ResponseWriter, r *http.Request) {
+	var req commitRequest
+	err := decode(r, &req)
+	if err == nil {
+		req.Artifact.Name = r.PathValue("artifact")
+		req.Artifact.Release = r.PathValue("release")
+		err = s.store.CommitUpload(&req.Artifact, req.Force)
+	}
+	reply(w, r, &req.Artifact, err)
+}
+
+// Audit log
+
+func (s *apiServer) appendAudit(w http.ResponseWriter, r *http.Request) {
+	var e AuditEntry
+	err := decode(r, &e)
+	if err == nil {
+		// who and when are the server's to say
+		e.Actor = identity(r)
+		e.Time = time.Now()
+		err = s.store.AppendAudit(&e)
+	}
+	reply(w, r, &e, err)
+}
+
+func (s *apiServer) listAudit(w http.ResponseWriter, r *http.Request) {
+	q := auditQuery{Train: r.URL.Query().Get("train"), Component: r.URL.Query().Get("component")}
+	var err error
+	if since := r.URL.Query().Get("since"); since != "" {
+		q.Since, err = time.Parse(time.RFC3339, since)
+		if err != nil {
+			reply(w, r, nil, errors.New("invalid since "+since+", expected an RFC 3339 time"))
+			return
+		}
+	}
+	entries, err := s.store.ListAudit(q)
+	reply(w, r, entries, err)
+}
@@ -0,0 +1,290 @@
+// file: holoStore.go
+// purpose: storage layer for holo cli
+//          trains, releases, components, submissions and artifacts are
+//          first-class types; every subCMD goes through the Store interface
+//          so the backend can be swapped without touching the actions
+
+package main
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/urfave/cli"
+)
+
+// timeLayout is the format used to print Created dates, e.g. 2015-03-09 09:00AM PST
+const timeLayout = "2006-01-02 03:04PM MST"
+
+var (
+	errNotFound = errors.New("not found")
+	errExists   = errors.New("already exists")
+	errLocked   = errors.New("locked")
+	errNoRange  = errors.New("the server cannot resume the download")
+)
+
+// Train is a build train, e.g. Thundercat
+type Train struct {
+	Name          string    `json:"name" yaml:"name"`
+	Created       time.Time `json:"created" yaml:"created"`
+	Base          string    `json:"base,omitempty" yaml:"base,omitempty"`
+	LatestRelease string    `json:"latestRelease,omitempty" yaml:"latestRelease,omitempty"`
+	Components    []string  `json:"components" yaml:"components"`
+	Pending       []string  `json:"pending" yaml:"pending"`
+	Reverts       []Revert  `json:"reverts,omitempty" yaml:"reverts,omitempty"` // pending reverts
+}
+
+// Release is a snapshot of submissions within a train, e.g. Thundercat1A176
+type Release struct {
+	Name        string    `json:"name" yaml:"name"`
+	Train       string    `json:"train" yaml:"train"`
+	Created     time.Time `json:"created" yaml:"created"`
+	Base        string    `json:"base,omitempty" yaml:"base,omitempty"`
+	CutBy       string    `json:"cutBy,omitempty" yaml:"cutBy,omitempty"`
+	State       string    `json:"state" yaml:"state"` // see holoReleaseState.go
+	Submissions []string  `json:"submissions" yaml:"submissions"`
+	Reverts     []Revert  `json:"reverts,omitempty" yaml:"reverts,omitempty"`
+}
+
+// Revert records that a train went back to an older submission, e.g.
+// osd-39 reverted to osd-37; it stays pending on the train until the next
+// release is cut, which then carries it
+type Revert struct {
+	Submission string    `json:"submission" yaml:"submission"`
+	Reverted   string    `json:"reverted" yaml:"reverted"`
+	Train      string    `json:"train" yaml:"train"`
+	Created    time.Time `json:"created" yaml:"created"`
+	RevertedBy string    `json:"revertedBy,omitempty" yaml:"revertedBy,omitempty"`
+	Reason     string    `json:"reason,omitempty" yaml:"reason,omitempty"`
+}
+
+// Component is a unit of source that is submitted to trains, e.g. osd
+type Component struct {
+	Name    string    `json:"name" yaml:"name"`
+	Created time.Time `json:"created" yaml:"created"`
+	Owner   string    `json:"owner" yaml:"owner"`
+	Repo    string    `json:"repo,omitempty" yaml:"repo,omitempty"`
+	Deps    []string  `json:"deps,omitempty" yaml:"deps,omitempty"` // components it depends on
+	Trains  []string  `json:"trains" yaml:"trains"`
+}
+
+// Submission is a version of a component, e.g. osd-38
+type Submission struct {
+	Name      string    `json:"name" yaml:"name"`
+	Component string    `json:"component" yaml:"component"`
+	Version   string    `json:"version" yaml:"version"`
+	Created   time.Time `json:"created" yaml:"created"`
+	Submitter string    `json:"submitter,omitempty" yaml:"submitter,omitempty"`
+	Notes     Notes     `json:"notes" yaml:"notes"`
+	Issues    []string  `json:"issues,omitempty" yaml:"issues,omitempty"` // issue keys, see holoIssues.go
+	Trains    []string  `json:"trains" yaml:"trains"`
+	Commit    string    `json:"commit,omitempty" yaml:"commit,omitempty"`
+	SHA256    string    `json:"sha256,omitempty" yaml:"sha256,omitempty"` // checksum of the source archive
+}
+
+// Artifact is a file produced for a release, e.g. Thundercat1A176_SDKRoot.txz
+type Artifact struct {
+	Name    string    `json:"name" yaml:"name"`
+	Release string    `json:"release" yaml:"release"`
+	Created time.Time `json:"created" yaml:"created"`
+	Size    int64     `json:"size" yaml:"size"`
+	SHA256  string    `json:"sha256" yaml:"sha256"`
+	// Source is set on artifacts that refer to the data of an artifact of
+	// another release, as RELEASE/FILENAME
+	Source string `json:"source,omitempty" yaml:"source,omitempty"`
+	// taken from the name, see parseArtifactName
+	Device  string `json:"device,omitempty" yaml:"device,omitempty"`
+	Board   string `json:"board,omitempty" yaml:"board,omitempty"`
+	Variant string `json:"variant,omitempty" yaml:"variant,omitempty"`
+	Kind    string `json:"kind,omitempty" yaml:"kind,omitempty"`
+}
+
+// AuditEntry records one attempt at changing shared state, see holoAudit.go
+type AuditEntry struct {
+	Time       time.Time `json:"time" yaml:"time"`
+	Actor      string    `json:"actor" yaml:"actor"`
+	Command    string    `json:"command" yaml:"command"` // e.g. holo submit osd-38 .
+	Trains     []string  `json:"trains" yaml:"trains"`
+	Releases   []string  `json:"releases" yaml:"releases"`
+	Components []string  `json:"components" yaml:"components"`
+	Outcome    string    `json:"outcome" yaml:"outcome"` // ok, failed or aborted
+	Error      string    `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+// auditQuery selects audit entries; empty fields match everything
+type auditQuery struct {
+	Train     string
+	Component string
+	Since     time.Time
+}
+
+// Store is implemented by every holo backend
+type Store interface {
+	ListTrains() ([]Train, error)
+	GetTrain(name string) (*Train, error)
+	CreateTrain(t *Train) error
+	// QueueSubmission adds a submission to the pending list of every train
+	// given, replacing any pending submission of the same component; when
+	// one of the trains fails, none of them is changed
+	QueueSubmission(submission string, trains []string) error
+	// RevertSubmission queues the older submission of each revert on its
+	// train and keeps the revert record pending there; when one of the
+	// trains fails, none of them is changed
+	RevertSubmission(reverts []*Revert) error
+
+	ListReleases(train string) ([]Release, error)
+	GetRelease(name string) (*Release, error)
+	// CloneRelease stores a new release as given, e.g. a copy of a release
+	// of another train, leaving the pending submissions and reverts of its
+	// train alone; it only becomes the latest release when it is newer
+	CloneRelease(r *Release) error
+	// DeleteRelease removes a release and its artifacts; used to roll back
+	// a failed clone. It fails while other releases link to its artifacts.
+	DeleteRelease(name string) error
+	// CutRelease snapshots the pending submissions of a train on top of
+	// r.Base (default: the latest release) into a new release, holding the
+	// train lock; r.Submissions, when set, must match the snapshot so that
+	// what was confirmed is exactly what gets cut
+	CutRelease(r *Release) error
+	// PromoteRelease moves a release to another lifecycle state, checking
+	// that the transition is allowed
+	PromoteRelease(name, state string) (*Release, error)
+
+	ListComponents() ([]Component, error)
+	GetComponent(name string) (*Component, error)
+	CreateComponent(comp *Component) error
+	// UpdateComponent changes the owner, repo and dependencies of a
+	// component
+	UpdateComponent(comp *Component) error
+	LinkComponent(component, train string) error
+	UnlinkComponent(component, train string) error
+
+	ListSubmissions(component string) ([]Submission, error)
+	GetSubmission(name string) (*Submission, error)
+	CreateSubmission(s *Submission) error
+	// DeleteSubmission removes a submission, its sources and any pending
+	// reference to it; used to roll back a failed submit. It fails once a
+	// release ships the submission.
+	DeleteSubmission(name string) error
+	PutSources(submission string, r io.Reader) error
+	GetSources(submission string) (io.ReadCloser, error)
+
+	ListArtifacts(release string) ([]Artifact, error)
+	StatArtifact(release, name string) (*Artifact, error)
+	// OpenArtifact reads the contents of an artifact starting at offset, or
+	// fails with errNoRange when it can only start from the beginning
+	OpenArtifact(release, name string, offset int64) (io.ReadCloser, error)
+	// UploadOffset returns how many bytes of an interrupted upload were kept
+	UploadOffset(release, name string) (int64, error)
+	// UploadChunk appends to an upload at offset and returns the new offset
+	UploadChunk(release, name string, offset int64, r io.Reader) (int64, error)
+	// CommitUpload verifies the size and SHA-256 of a finished upload and
+	// publishes it; an existing artifact is only replaced with force, and
+	// never while other releases link to it
+	CommitUpload(a *Artifact, force bool) error
+	// LinkArtifact adds an artifact to a release that refers to the data of
+	// the artifact named by a.Source instead of holding a copy
+	LinkArtifact(a *Artifact) error
+
+	// AppendAudit adds an entry to the append-only audit log
+	AppendAudit(e *AuditEntry) error
+	// ListAudit returns the entries matching q, oldest first
+	ListAudit(q auditQuery) ([]AuditEntry, error)
+}
+
+// openStore returns the backend selected by the global options: the build
+// service given by --server, or else the local store
+func openStore(c *cli.Context) (Store, error) {
+	if setting(c, "server") != "" {
+		return newAuthAPIClient(c)
+	}
+	dir, err := localStoreDir(c)
+	if err != nil {
+		return nil, err
+	}
+	return newFileStore(dir)
+}
+
+// localStoreDir returns the directory of the local store
+func localStoreDir(c *cli.Context) (string, error) {
+	if setting(c, "store") != "" {
+		return setting(c, "store"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".holo"), nil
+}
+
+// containsStr reports whether list contains str
+func containsStr(list []string, str string) bool {
+	for _, s := range list {
+		if s == str {
+			return true
+		}
+	}
+	return false
+}
+
+// overlaySubmissions applies submissions on top of a base set, replacing
+// older versions of the same component, e.g. osd-37 + osd-38 => osd-38
+func overlaySubmissions(base []string, submissions []string) []string {
+	byComponent := map[string]string{}
+	for _, list := range [][]string{base, submissions} {
+		for _, sub := range list {
+			comp, _, err := parseSubmissionName(sub)
+			if err != nil {
+				comp = sub
+			}
+			byComponent[comp] = sub
+		}
+	}
+	out := make([]string, 0, len(byComponent))
+	for _, sub := range byComponent {
+		out = append(out, sub)
+	}
+	sortSubmissions(out)
+	return out
+}
+
+// trainSnapshot is what a release of t cut on top of base carries: the
+// pending submissions over the base, without the components that were
+// unlinked from t since
+func trainSnapshot(t *Train, base []string) []string {
+	snapshot := []string{}
+	for _, sub := range overlaySubmissions(base, t.Pending) {
+		comp, _, err := parseSubmissionName(sub)
+		if err == nil && containsStr(t.Components, comp) {
+			snapshot = append(snapshot, sub)
+		}
+	}
+	return snapshot
+}
+
+// sameStrs reports whether two lists hold the same strings in the same order
+func sameStrs(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// removeStr returns list without any occurrence of str
+func removeStr(list []string, str string) []string {
+	out := make([]string, 0, len(list))
+	for _, s := range list {
+		if s != str {
+			out = append(out, s)
+		}
+	}
+	return out
+}
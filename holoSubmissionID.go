@@ -0,0 +1,127 @@
+// file: holoSubmissionID.go
+// purpose: submission identifiers - COMPONENT-VERSION with an optional
+//          hotfix suffix, e.g. osd-38 or osd-37.1 (the first hotfix on top
+//          of osd-37); submissions order by version, then hotfix, so
+//          osd-37 < osd-37.1 < osd-37.2 < osd-38 < osd-100
+
+package main
+
+import (
+	"errors"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// the component part follows namePattern
+var submissionIDPattern = regexp.MustCompile(`^([A-Za-z0-9][A-Za-z0-9_.+-]*)-([0-9]+)((?:\.[0-9]+)*)$`)
+
+type submissionID struct {
+	Component string
+	Version   int
+	// Hotfix holds the hotfix numbers, e.g. [1] for osd-37.1
+	Hotfix []int
+}
+
+// parseSubmissionID takes a submission name apart
+func parseSubmissionID(name string) (submissionID, error) {
+	var id submissionID
+	m := submissionIDPattern.FindStringSubmatch(name)
+	if m == nil {
+		return id, errors.New("invalid submission name " + name + ", expected COMPONENT-VERSION or COMPONENT-VERSION.HOTFIX")
+	}
+	id.Component = m[1]
+	var err error
+	id.Version, err = strconv.Atoi(m[2])
+	if err != nil {
+		return submissionID{}, errors.New("invalid version in submission name " + name + ": " + err.Error())
+	}
+	for _, n := range strings.Split(strings.TrimPrefix(m[3], "."), ".") {
+		if n == "" {
+			continue
+		}
+		h, err := strconv.Atoi(n)
+		if err != nil {
+			return submissionID{}, errors.New("invalid hotfix in submission name " + name + ": " + err.Error())
+		}
+		id.Hotfix = append(id.Hotfix, h)
+	}
+	return id, nil
+}
+
+func (id submissionID) String() string {
+	return id.Component + "-" + id.version()
+}
+
+// version is the version part of the name, e.g. 37.1
+func (id submissionID) version() string {
+	v := strconv.Itoa(id.Version)
+	for _, h := range id.Hotfix {
+		v += "." + strconv.Itoa(h)
+	}
+	return v
+}
+
+func (id submissionID) isHotfix() bool {
+	return len(id.Hotfix) > 0
+}
+
+// compare orders two submissions of the same component
+func (id submissionID) compare(other submissionID) int {
+	if id.Version != other.Version {
+		return compareInts(id.Version, other.Version)
+	}
+	for i := 0; i < len(id.Hotfix) && i < len(other.Hotfix); i++ {
+		if id.Hotfix[i] != other.Hotfix[i] {
+			return compareInts(id.Hotfix[i], other.Hotfix[i])
+		}
+	}
+	return compareInts(len(id.Hotfix), len(other.Hotfix))
+}
+
+// compareInts returns -1, 0 or 1; subtracting could overflow
+func compareInts(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// compareSubmissions orders two submissions of the same component by
+// version, e.g. osd-37 < osd-37.1 < osd-38
+func compareSubmissions(a, b string) int {
+	ia, _ := parseSubmissionID(a)
+	ib, _ := parseSubmissionID(b)
+	return ia.compare(ib)
+}
+
+// lessSubmission orders submissions by component, then by version
+func lessSubmission(a, b string) bool {
+	ia, erra := parseSubmissionID(a)
+	ib, errb := parseSubmissionID(b)
+	if erra != nil || errb != nil || ia.Component != ib.Component {
+		ca, cb := ia.Component, ib.Component
+		if erra != nil {
+			ca = a
+		}
+		if errb != nil {
+			cb = b
+		}
+		if ca != cb {
+			return ca < cb
+		}
+		return a < b
+	}
+	return ia.compare(ib) < 0
+}
+
+// sortSubmissions sorts submission names by component, then by version
+func sortSubmissions(names []string) {
+	sort.SliceStable(names, func(i, j int) bool {
+		return lessSubmission(names[i], names[j])
+	})
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseSubmissionID(t *testing.T) {
+	tests := []struct {
+		name string
+		want submissionID
+		ok   bool
+	}{
+		{"osd-38", submissionID{Component: "osd", Version: 38}, true},
+		{"osd-37.1", submissionID{Component: "osd", Version: 37, Hotfix: []int{1}}, true},
+		{"osd-37.1.2", submissionID{Component: "osd", Version: 37, Hotfix: []int{1, 2}}, true},
+		{"osd-0", submissionID{Component: "osd", Version: 0}, true},
+		{"sdk-root-4", submissionID{Component: "sdk-root", Version: 4}, true},
+		{"sdk-2-7.3", submissionID{Component: "sdk-2", Version: 7, Hotfix: []int{3}}, true},
+
+		{"", submissionID{}, false},
+		{"osd", submissionID{}, false},
+		{"osd-", submissionID{}, false},
+		{"-38", submissionID{}, false},
+		{"-osd-38", submissionID{}, false},
+		{"o d-38", submissionID{}, false},
+		{"osd-38.", submissionID{}, false},
+		{"osd-38..1", submissionID{}, false},
+		{"osd-.1", submissionID{}, false},
+		{"osd-v38", submissionID{}, false},
+		{"osd-38a", submissionID{}, false},
+		{"osd-99999999999999999999", submissionID{}, false},
+		{"osd-37.99999999999999999999", submissionID{}, false},
+	}
+	for _, tt := range tests {
+		got, err := parseSubmissionID(tt.name)
+		if (err == nil) != tt.ok {
+			t.Errorf("parseSubmissionID(%q) error = %v, want ok %v", tt.name, err, tt.ok)
+			continue
+		}
+		if !tt.ok {
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseSubmissionID(%q) = %+v, want %+v", tt.name, got, tt.want)
+		}
+		if got.String() != tt.name {
+			t.Errorf("parseSubmissionID(%q).String() = %q", tt.name, got.String())
+		}
+		if got.isHotfix() != (len(tt.want.Hotfix) > 0) {
+			t.Errorf("parseSubmissionID(%q).isHotfix() = %v", tt.name, got.isHotfix())
+		}
+	}
+}
+
+func TestCompareSubmissions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"osd-37", "osd-37.1", -1},
+		{"osd-37.1", "osd-38", -1},
+		{"osd-37", "osd-38", -1},
+		{"osd-37.1", "osd-37.2", -1},
+		{"osd-37.2", "osd-37.10", -1},
+		{"osd-37.1", "osd-37.1.1", -1},
+		{"osd-37.1.1", "osd-37.2", -1},
+		{"osd-38", "osd-100", -1},
+		{"osd-38", "osd-37.9", 1},
+		{"osd-37.1", "osd-37.1", 0},
+		{"osd-9223372036854775807", "osd-1", 1},
+	}
+	for _, tt := range tests {
+		if got := compareSubmissions(tt.a, tt.b); got != tt.want {
+			t.Errorf("compareSubmissions(%s, %s) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+		if got := compareSubmissions(tt.b, tt.a); got != -tt.want {
+			t.Errorf("compareSubmissions(%s, %s) = %d, want %d", tt.b, tt.a, got, -tt.want)
+		}
+	}
+}
+
+func TestSortSubmissions(t *testing.T) {
+	names := []string{"osd-38", "bash-2", "osd-37.1", "not a submission", "osd-100", "osd-37", "bash-10", "osd-37.1.1"}
+	sortSubmissions(names)
+	want := []string{"bash-2", "bash-10", "not a submission", "osd-37", "osd-37.1", "osd-37.1.1", "osd-38", "osd-100"}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("sortSubmissions = %v, want %v", names, want)
+	}
+}
@@ -0,0 +1,18 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+// runHolo runs a holo command against a local store, without a config, a
+// server or settings from the environment
+func runHolo(t *testing.T, store *fileStore, args ...string) error {
+	t.Helper()
+	for _, key := range settingKeys {
+		t.Setenv(settingEnv(key), "")
+	}
+	t.Setenv("HOLO_CACHE_DIR", t.TempDir())
+	config := filepath.Join(t.TempDir(), "config")
+	return newApp().Run(append([]string{"holo", "--config", config, "--store", store.root}, args...))
+}